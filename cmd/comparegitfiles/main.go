@@ -0,0 +1,13 @@
+// Command comparegitfiles compares or fetches the files listed in a config
+// against a GitHub, GitLab or Bitbucket repository.
+package main
+
+import (
+	"os"
+
+	"gitcompare/pkg/comparegitfiles"
+)
+
+func main() {
+	comparegitfiles.Main(os.Args[1:])
+}
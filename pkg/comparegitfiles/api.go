@@ -0,0 +1,175 @@
+// Package comparegitfiles compares local files with their copies in a
+// GitHub, GitLab or Bitbucket repository and fetches them, either through
+// Client or through the command line in Main.
+package comparegitfiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// CompareResult is the outcome of comparing one file. Status is one of
+// identical, changed, missing_local or, with WithRenameDetection, renamed.
+type CompareResult = DiffResult
+
+// FetchOptions changes how Fetch writes the remote files.
+type FetchOptions struct {
+	// Sync only downloads the files whose content differs from the remote.
+	Sync bool
+	// CopyPermissions applies the remote file mode to downloaded files.
+	CopyPermissions bool
+	// Path fetches only this path instead of every entry of Config.Files.
+	Path string
+}
+
+// Client compares and fetches the files of a Config. The package keeps the
+// state of a run in package variables, so the runs of all clients are
+// serialized.
+type Client struct {
+	token       string
+	httpClient  *http.Client
+	concurrency int
+	fetch       FetchOptions
+	differ      Differ
+	renames     bool
+	// renameSimilarity is the minimum similarity of WithRenameDetection.
+	renameSimilarity float64
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sends the api requests through httpClient instead of the
+// default client with pooled connections.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithConcurrency limits the number of files fetched or compared at the
+// same time, maxParallel by default.
+func WithConcurrency(n int) Option {
+	return func(c *Client) {
+		c.concurrency = n
+	}
+}
+
+// WithFetchOptions sets the options Fetch uses.
+func WithFetchOptions(fetch FetchOptions) Option {
+	return func(c *Client) {
+		c.fetch = fetch
+	}
+}
+
+// WithDiffer computes the diffs of Compare with d instead of LineDiffer.
+func WithDiffer(d Differ) Option {
+	return func(c *Client) {
+		c.differ = d
+	}
+}
+
+// WithRenameDetection makes Compare report files missing locally as renamed
+// to the most similar local file the remote does not list, when their
+// similarity ratio is at least minSimilarity, between 0.0 and 1.0.
+func WithRenameDetection(minSimilarity float64) Option {
+	return func(c *Client) {
+		c.renames = true
+		c.renameSimilarity = minSimilarity
+	}
+}
+
+// NewClient returns a Client that authenticates with token, which is sent
+// the way the provider of each Config expects it.
+func NewClient(token string, opts ...Option) *Client {
+	c := &Client{
+		token:       token,
+		httpClient:  NewHTTPClient(defaultHTTPClientConfig()),
+		concurrency: maxParallel,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+var runMu sync.Mutex
+
+// Compare compares the files of cfg in the current directory with the
+// remote and returns one result per file, ordered by path.
+func (c *Client) Compare(ctx context.Context, cfg *Config) ([]CompareResult, error) {
+	opts := c.options()
+	opts.Compare = true
+	return c.run(ctx, cfg, opts)
+}
+
+// Fetch downloads the files of cfg into the current directory.
+func (c *Client) Fetch(ctx context.Context, cfg *Config) error {
+	opts := c.options()
+	opts.Sync = c.fetch.Sync
+	opts.CopyPermissions = c.fetch.CopyPermissions
+	opts.Path = c.fetch.Path
+	_, err := c.run(ctx, cfg, opts)
+	return err
+}
+
+func (c *Client) options() *Options {
+	return &Options{
+		Token:            c.token,
+		ParallelStrategy: "depth-first",
+		StreamThreshold:  10 * 1024 * 1024,
+		Concurrency:      c.concurrency,
+		Format:           "pretty",
+		MaxSimilarity:    100,
+		DetectRenames:    c.renames,
+		RenameSimilarity: c.renameSimilarity,
+		Differ:           c.differ,
+	}
+}
+
+// run processes cfg with opts and returns the results it collected, which
+// are copied before runMu is released and the next run resets them.
+func (c *Client) run(ctx context.Context, cfg *Config, opts *Options) ([]CompareResult, error) {
+	if c.concurrency < 1 {
+		return nil, fmt.Errorf("invalid concurrency %d", c.concurrency)
+	}
+	if c.renameSimilarity < 0 || c.renameSimilarity > 1 {
+		return nil, fmt.Errorf("invalid rename similarity %v, must be between 0.0 and 1.0", c.renameSimilarity)
+	}
+	for _, err := range ValidateConfig(cfg) {
+		if errors.Is(err, errNoFiles) && opts.Path != "" {
+			continue
+		}
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	runMu.Lock()
+	defer runMu.Unlock()
+	resetRunState()
+	rules, err := buildIgnoreRules(cfg.Ignore, nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	ignoreRules = rules
+	defer func(hc *http.Client, s *semaphore.Weighted) { client, sem = hc, s }(client, sem)
+	client = c.httpClient
+	sem = semaphore.NewWeighted(int64(c.concurrency))
+	defer func(out io.Writer) { diffOut = out }(diffOut)
+	diffOut = io.Discard
+
+	if err := updateDependencies(ctx, opts, cfg); err != nil {
+		return nil, fmt.Errorf("failed to update dependencies: %w", err)
+	}
+	if opts.Compare && opts.DetectRenames {
+		if err := detectRenames(ctx, opts, cfg); err != nil {
+			return nil, fmt.Errorf("failed to detect renames: %w", err)
+		}
+	}
+	return compareResults.all(), nil
+}
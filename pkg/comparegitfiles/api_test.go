@@ -0,0 +1,391 @@
+package comparegitfiles_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"gitcompare/pkg/comparegitfiles"
+)
+
+func gitBlobSha(content string) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("blob %d\x00%s", len(content), content)))
+	return hex.EncodeToString(sum[:])
+}
+
+// remoteRepos serves the contents and blob endpoints of the github api for
+// repositories keyed by owner/name, each mapping paths to file contents.
+type remoteRepos map[string]map[string]string
+
+func (repos remoteRepos) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 5)
+	if r.Header.Get("Authorization") != "token secret" {
+		http.Error(w, "bad credentials", http.StatusUnauthorized)
+		return
+	}
+	if len(parts) < 4 || parts[0] != "repos" {
+		http.NotFound(w, r)
+		return
+	}
+	files, ok := repos[parts[1]+"/"+parts[2]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	rest := strings.Join(parts[3:], "/")
+	entry := func(name, content string) comparegitfiles.GithubContent {
+		return comparegitfiles.GithubContent{
+			Name:        path.Base(name),
+			Path:        name,
+			Type:        "file",
+			Sha:         gitBlobSha(content),
+			Size:        int64(len(content)),
+			DownloadURL: "https://raw.githubusercontent.com/" + parts[1] + "/" + parts[2] + "/HEAD/" + name,
+		}
+	}
+	switch {
+	case strings.HasPrefix(rest, "contents/"):
+		dir := strings.Trim(strings.TrimPrefix(rest, "contents/"), "/")
+		if content, ok := files[dir]; ok {
+			json.NewEncoder(w).Encode(entry(dir, content))
+			return
+		}
+		var entries []comparegitfiles.GithubContent
+		for name, content := range files {
+			if path.Dir(name) == dir {
+				entries = append(entries, entry(name, content))
+			}
+		}
+		if entries == nil {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(entries)
+	case strings.HasPrefix(rest, "git/blobs/"):
+		for _, content := range files {
+			if gitBlobSha(content) == strings.TrimPrefix(rest, "git/blobs/") {
+				json.NewEncoder(w).Encode(map[string]string{
+					"content":  base64.StdEncoding.EncodeToString([]byte(content)),
+					"encoding": "base64",
+				})
+				return
+			}
+		}
+		http.NotFound(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// rawContent serves raw.githubusercontent.com paths of repos.
+func (repos remoteRepos) rawContent(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 4)
+	if len(parts) == 4 {
+		if content, ok := repos[parts[0]+"/"+parts[1]][parts[3]]; ok {
+			fmt.Fprint(w, content)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// rewriteHost sends every request to the test server, whatever host the
+// client addresses.
+type rewriteHost struct {
+	target *url.URL
+	next   http.RoundTripper
+}
+
+func (t rewriteHost) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if req.URL.Host == "raw.githubusercontent.com" {
+		req.URL.Path = "/raw" + req.URL.Path
+	}
+	req.URL.Scheme, req.URL.Host = t.target.Scheme, t.target.Host
+	return t.next.RoundTrip(req)
+}
+
+// newTestClient returns a Client for token secret whose requests reach a
+// fake github api serving repos.
+func newTestClient(t *testing.T, repos remoteRepos, opts ...comparegitfiles.Option) *comparegitfiles.Client {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle("/repos/", repos)
+	mux.Handle("/raw/", http.StripPrefix("/raw", http.HandlerFunc(repos.rawContent)))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpClient := &http.Client{Transport: rewriteHost{target: target, next: http.DefaultTransport}}
+	return comparegitfiles.NewClient("secret", append([]comparegitfiles.Option{comparegitfiles.WithHTTPClient(httpClient)}, opts...)...)
+}
+
+// inLocalRepo writes files into a new git repository, adds them to the
+// index, where Compare reads local content from, and changes into it.
+func inLocalRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{{"init", "-q"}, {"add", "-A"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+type fileStatus struct {
+	status         string
+	added, removed int
+}
+
+func resultStatuses(results []comparegitfiles.CompareResult) map[string]fileStatus {
+	statuses := make(map[string]fileStatus)
+	for _, result := range results {
+		statuses[result.Path] = fileStatus{result.Status, result.Added, result.Removed}
+	}
+	return statuses
+}
+
+func TestClientCompare(t *testing.T) {
+	repos := remoteRepos{"owner/repo": {
+		"conf/app.yaml":  "a: 1\nb: 2\n",
+		"conf/new.yaml":  "new: true\n",
+		"conf/same.yaml": "same\n",
+		"Makefile":       "all:\n",
+	}}
+	local := map[string]string{
+		"conf/app.yaml":  "a: 1\nb: 3\nc: 4\n",
+		"conf/same.yaml": "same\n",
+		"Makefile":       "all:\n",
+	}
+	tests := []struct {
+		name    string
+		cfg     *comparegitfiles.Config
+		want    map[string]fileStatus
+		wantErr string
+	}{
+		{
+			name: "directory",
+			cfg:  &comparegitfiles.Config{Name: "owner/repo", Files: []string{"conf"}},
+			want: map[string]fileStatus{
+				"conf/app.yaml":  {"changed", 1, 2},
+				"conf/new.yaml":  {"missing_local", 0, 0},
+				"conf/same.yaml": {"identical", 0, 0},
+			},
+		},
+		{
+			name: "ignore",
+			cfg:  &comparegitfiles.Config{Name: "owner/repo", Files: []string{"conf", "Makefile"}, Ignore: []string{"conf/new.yaml"}},
+			want: map[string]fileStatus{
+				"conf/app.yaml":  {"changed", 1, 2},
+				"conf/same.yaml": {"identical", 0, 0},
+				"Makefile":       {"identical", 0, 0},
+			},
+		},
+		{name: "invalid config", cfg: &comparegitfiles.Config{Name: "owner/repo"}, wantErr: "invalid config"},
+		{name: "missing repository", cfg: &comparegitfiles.Config{Name: "owner/missing", Files: []string{"conf"}}, wantErr: "404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inLocalRepo(t, local)
+			results, err := newTestClient(t, repos).Compare(context.Background(), tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Compare error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 1; i < len(results); i++ {
+				if results[i-1].Path > results[i].Path {
+					t.Errorf("results not ordered by path: %s before %s", results[i-1].Path, results[i].Path)
+				}
+			}
+			if got := resultStatuses(results); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compare = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientCompareConcurrent(t *testing.T) {
+	repos := remoteRepos{
+		"owner/changed": {"conf/app.yaml": "a: 1\nb: 2\n"},
+		"owner/same":    {"conf/app.yaml": "a: 1\n", "conf/more.yaml": "more\n"},
+	}
+	inLocalRepo(t, map[string]string{"conf/app.yaml": "a: 1\n", "conf/more.yaml": "more\n"})
+	client := newTestClient(t, repos)
+	want := map[string]map[string]fileStatus{
+		"owner/changed": {"conf/app.yaml": {"changed", 1, 0}},
+		"owner/same":    {"conf/app.yaml": {"identical", 0, 0}, "conf/more.yaml": {"identical", 0, 0}},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		name := "owner/changed"
+		if i%2 == 1 {
+			name = "owner/same"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results, err := client.Compare(context.Background(), &comparegitfiles.Config{Name: name, Files: []string{"conf"}})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got := resultStatuses(results); !reflect.DeepEqual(got, want[name]) {
+				t.Errorf("Compare(%s) = %v, want %v", name, got, want[name])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestClientFetch(t *testing.T) {
+	repos := remoteRepos{"owner/repo": {
+		"conf/app.yaml":  "a: 1\nb: 2\n",
+		"conf/new.yaml":  "new: true\n",
+		"conf/same.yaml": "same\n",
+		"Makefile":       "all:\n",
+	}}
+	tests := []struct {
+		name  string
+		fetch comparegitfiles.FetchOptions
+		want  map[string]string
+	}{
+		{
+			name: "all files",
+			want: map[string]string{"conf/app.yaml": "a: 1\nb: 2\n", "conf/new.yaml": "new: true\n", "conf/same.yaml": "same\n", "Makefile": ""},
+		},
+		{
+			name:  "path",
+			fetch: comparegitfiles.FetchOptions{Path: "Makefile"},
+			want:  map[string]string{"conf/app.yaml": "a: 1\nb: 3\n", "conf/new.yaml": "", "Makefile": "all:\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := inLocalRepo(t, map[string]string{"conf/app.yaml": "a: 1\nb: 3\n", "conf/same.yaml": "same\n"})
+			client := newTestClient(t, repos, comparegitfiles.WithFetchOptions(tt.fetch))
+			if err := client.Fetch(context.Background(), &comparegitfiles.Config{Name: "owner/repo", Files: []string{"conf"}}); err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.want {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				if want == "" {
+					if err == nil {
+						t.Errorf("%s was fetched", name)
+					}
+					continue
+				}
+				if err != nil || string(data) != want {
+					t.Errorf("%s = %q, %v, want %q", name, data, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewClientInvalidConcurrency(t *testing.T) {
+	client := comparegitfiles.NewClient("secret", comparegitfiles.WithConcurrency(0))
+	_, err := client.Compare(context.Background(), &comparegitfiles.Config{Name: "owner/repo", Files: []string{"conf"}})
+	if err == nil || !strings.Contains(err.Error(), "invalid concurrency 0") {
+		t.Errorf("Compare error = %v, want invalid concurrency", err)
+	}
+}
+
+func TestClientCompareRenames(t *testing.T) {
+	repos := remoteRepos{"owner/repo": {
+		"conf/app.yaml": "a: 1\n",
+		"conf/old.yaml": "a: 1\nb: 2\nc: 3\nd: 4\ne: 5\n",
+	}}
+	local := map[string]string{
+		"conf/app.yaml": "a: 1\n",
+		"conf/new.yaml": "a: 1\nb: 2\nc: 3\nd: 4\ne: 50\n",
+	}
+	tests := []struct {
+		name    string
+		opts    []comparegitfiles.Option
+		want    map[string]fileStatus
+		renamed string
+		wantErr string
+	}{
+		{
+			name: "without detection",
+			want: map[string]fileStatus{"conf/app.yaml": {"identical", 0, 0}, "conf/old.yaml": {"missing_local", 0, 0}},
+		},
+		{
+			name:    "with detection",
+			opts:    []comparegitfiles.Option{comparegitfiles.WithRenameDetection(0.8)},
+			want:    map[string]fileStatus{"conf/app.yaml": {"identical", 0, 0}, "conf/old.yaml": {"renamed", 1, 1}},
+			renamed: "conf/new.yaml",
+		},
+		{
+			name: "below the threshold",
+			opts: []comparegitfiles.Option{comparegitfiles.WithRenameDetection(0.9)},
+			want: map[string]fileStatus{"conf/app.yaml": {"identical", 0, 0}, "conf/old.yaml": {"missing_local", 0, 0}},
+		},
+		{name: "invalid threshold", opts: []comparegitfiles.Option{comparegitfiles.WithRenameDetection(80)}, wantErr: "invalid rename similarity"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inLocalRepo(t, local)
+			results, err := newTestClient(t, repos, tt.opts...).Compare(context.Background(), &comparegitfiles.Config{Name: "owner/repo", Files: []string{"conf"}})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Compare error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := resultStatuses(results); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compare = %v, want %v", got, tt.want)
+			}
+			for _, result := range results {
+				if result.Path == "conf/old.yaml" && result.RenamedTo != tt.renamed {
+					t.Errorf("conf/old.yaml renamed to %q, want %q", result.RenamedTo, tt.renamed)
+				}
+			}
+		})
+	}
+}
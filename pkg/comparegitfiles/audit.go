@@ -0,0 +1,70 @@
+package comparegitfiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// audit records every downloadFile call when -audit-log is set.
+var audit *auditLog
+
+type auditRecord struct {
+	Time      time.Time `json:"time"`
+	Op        string    `json:"op"`
+	Path      string    `json:"path"`
+	LocalSha  string    `json:"local_sha"`
+	RemoteSha string    `json:"remote_sha"`
+	Changed   bool      `json:"changed"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// auditLog appends one JSON record per line. The file is opened in append
+// mode and never truncated, and the mutex keeps concurrent records whole.
+type auditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func openAuditLog(path string) (*auditLog, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &auditLog{file: file}, nil
+}
+
+// record logs the operation on filePath. localSha is the sha of the local
+// file before the operation, empty when it did not exist.
+func (a *auditLog) record(op, filePath, localSha, remoteSha string, opErr error) error {
+	entry := auditRecord{
+		Time:      time.Now().UTC(),
+		Op:        op,
+		Path:      filepath.ToSlash(filepath.Clean(filePath)),
+		LocalSha:  localSha,
+		RemoteSha: remoteSha,
+		Changed:   localSha != remoteSha,
+	}
+	if opErr != nil {
+		entry.Error = opErr.Error()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	data = append(data, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+func (a *auditLog) Close() error {
+	return a.file.Close()
+}
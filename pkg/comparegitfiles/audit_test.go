@@ -0,0 +1,163 @@
+package comparegitfiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// auditRecords reads the records of the audit log at path.
+func auditRecords(t *testing.T, path string) []auditRecord {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []auditRecord
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		var record auditRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("audit line is not a record: %v\n%s", err, line)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestAuditLogRecord(t *testing.T) {
+	tests := []struct {
+		name                string
+		op, path            string
+		localSha, remoteSha string
+		err                 error
+		want                auditRecord
+	}{
+		{
+			name: "changed", op: "compare", path: "conf/app.yaml", localSha: "aaa", remoteSha: "bbb",
+			want: auditRecord{Op: "compare", Path: "conf/app.yaml", LocalSha: "aaa", RemoteSha: "bbb", Changed: true},
+		},
+		{
+			name: "identical", op: "compare", path: "./conf/app.yaml", localSha: "aaa", remoteSha: "aaa",
+			want: auditRecord{Op: "compare", Path: "conf/app.yaml", LocalSha: "aaa", RemoteSha: "aaa"},
+		},
+		{
+			name: "new file", op: "download", path: "conf/new.yaml", remoteSha: "bbb",
+			want: auditRecord{Op: "download", Path: "conf/new.yaml", RemoteSha: "bbb", Changed: true},
+		},
+		{
+			name: "failure", op: "download", path: "conf/app.yaml", localSha: "aaa", remoteSha: "bbb", err: errors.New("unexpected status code: 500"),
+			want: auditRecord{Op: "download", Path: "conf/app.yaml", LocalSha: "aaa", RemoteSha: "bbb", Changed: true, Error: "unexpected status code: 500"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.log")
+			log, err := openAuditLog(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := log.record(tt.op, tt.path, tt.localSha, tt.remoteSha, tt.err); err != nil {
+				t.Fatal(err)
+			}
+			log.Close()
+
+			records := auditRecords(t, path)
+			if len(records) != 1 {
+				t.Fatalf("got %d records, want 1", len(records))
+			}
+			got := records[0]
+			if got.Time.IsZero() {
+				t.Error("record has no time")
+			}
+			got.Time = tt.want.Time
+			if got != tt.want {
+				t.Errorf("record = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditLogConcurrentAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeFile(t, path, `{"op":"compare","path":"earlier"}`+"\n")
+	log, err := openAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := log.record("compare", fmt.Sprintf("conf/%d.yaml", i), "aaa", "bbb", nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	log.Close()
+
+	records := auditRecords(t, path)
+	if len(records) != 51 || records[0].Path != "earlier" {
+		t.Errorf("got %d records starting with %q, want the earlier record and 50 appended", len(records), records[0].Path)
+	}
+}
+
+func TestAuditLogRun(t *testing.T) {
+	remote := map[string]string{
+		"conf/app.yaml":  "a: 1\nb: 2\n",
+		"conf/new.yaml":  "new: true\n",
+		"conf/same.yaml": "same\n",
+	}
+	local := map[string]string{
+		"conf/app.yaml":  "a: 1\nb: 3\n",
+		"conf/same.yaml": "same\n",
+	}
+	tests := []struct {
+		args []string
+		op   string
+	}{
+		{args: []string{"compare", "-no-state"}, op: "compare"},
+		{args: []string{"fetch"}, op: "download"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.op, func(t *testing.T) {
+			gh, dir := newCompareRepo(t, remote, local, "conf")
+			path := filepath.Join(t.TempDir(), "audit.log")
+			for run := 1; run <= 2; run++ {
+				if _, stderr, code := runMain(t, dir, gh.URL, append(tt.args, "-audit-log", path)...); code != 0 {
+					t.Fatalf("exit code %d\n%s", code, stderr)
+				}
+			}
+
+			records := auditRecords(t, path)
+			if len(records) != 2*len(remote) {
+				t.Fatalf("got %d records after two runs, want %d", len(records), 2*len(remote))
+			}
+			firstRun := map[string]auditRecord{}
+			for _, record := range records[:len(remote)] {
+				firstRun[record.Path] = record
+			}
+			for path, content := range remote {
+				record, ok := firstRun[path]
+				if !ok {
+					t.Errorf("no record for %s", path)
+					continue
+				}
+				want := auditRecord{Time: record.Time, Op: tt.op, Path: path, RemoteSha: blobSha([]byte(content))}
+				if content, ok := local[path]; ok {
+					want.LocalSha = blobSha([]byte(content))
+				}
+				want.Changed = want.LocalSha != want.RemoteSha
+				if record != want {
+					t.Errorf("record = %+v, want %+v", record, want)
+				}
+			}
+		})
+	}
+}
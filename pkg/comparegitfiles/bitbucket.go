@@ -0,0 +1,190 @@
+package comparegitfiles
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+)
+
+var bitbucketAPI = "https://api.bitbucket.org/2.0"
+
+// blobCache holds the content of files whose blob id resolveBlobSha derived
+// by downloading them, so that the comparison does not fetch them twice. It
+// is cleared by resetRunState.
+var blobCache sync.Map
+
+type BitbucketProvider struct {
+	Name     string
+	Ref      string
+	Username string
+	Token    string
+}
+
+type bitbucketPage struct {
+	Values []bitbucketEntry `json:"values"`
+	Next   string           `json:"next"`
+}
+
+type bitbucketEntry struct {
+	Type string `json:"type"`
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+func (p *BitbucketProvider) ref() string {
+	if p.Ref == "" {
+		return "HEAD"
+	}
+	return url.PathEscape(p.Ref)
+}
+
+// ListPath asks for the metadata of path, since src/<ref>/<path> alone
+// answers with the raw content when path is a file. DecodeList follows a
+// directory to its listing.
+func (p *BitbucketProvider) ListPath(path string) string {
+	return fmt.Sprintf("%s/repositories/%s/src/%s/%s?format=meta", bitbucketAPI, p.Name, p.ref(), path)
+}
+
+func (p *BitbucketProvider) listDir(path string) string {
+	dir := strings.Trim(path, "/")
+	if dir != "" {
+		dir += "/"
+	}
+	return fmt.Sprintf("%s/repositories/%s/src/%s/%s?pagelen=%d", bitbucketAPI, p.Name, p.ref(), dir, apiPageSize)
+}
+
+func (p *BitbucketProvider) BlobURL(sha string) string {
+	return ""
+}
+
+func (p *BitbucketProvider) RawURL(path string) string {
+	return fmt.Sprintf("%s/repositories/%s/src/%s/%s", bitbucketAPI, p.Name, p.ref(), path)
+}
+
+func (p *BitbucketProvider) TagURL(tag string) string {
+	return fmt.Sprintf("%s/repositories/%s/refs/tags/%s", bitbucketAPI, p.Name, url.PathEscape(tag))
+}
+
+func (p *BitbucketProvider) LastCommitURL(path string) string {
+	query := url.Values{}
+	query.Set("path", path)
+	query.Set("pagelen", "1")
+	if p.Ref == "" {
+		return fmt.Sprintf("%s/repositories/%s/commits?%s", bitbucketAPI, p.Name, query.Encode())
+	}
+	return fmt.Sprintf("%s/repositories/%s/commits/%s?%s", bitbucketAPI, p.Name, p.ref(), query.Encode())
+}
+
+func (p *BitbucketProvider) Authorize(req *http.Request) {
+	if p.Token != "" {
+		req.SetBasicAuth(p.Username, p.Token)
+	}
+}
+
+func (p *BitbucketProvider) DecodeList(resp *http.Response, data []byte) ([]GithubContent, string, error) {
+	if resp.Request.URL.Query().Get("format") == "meta" {
+		var entry bitbucketEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, "", fmt.Errorf("failed to decode response: %w", err)
+		}
+		switch entry.Type {
+		case "commit_directory":
+			return nil, p.listDir(entry.Path), nil
+		case "commit_file":
+			return []GithubContent{p.content(entry)}, "", nil
+		default:
+			return nil, "", fmt.Errorf("unexpected entry type %q for %s", entry.Type, entry.Path)
+		}
+	}
+	var page bitbucketPage
+	if err := json.Unmarshal(data, &page); err != nil {
+		return nil, "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	contents := make([]GithubContent, 0, len(page.Values))
+	for _, entry := range page.Values {
+		contents = append(contents, p.content(entry))
+	}
+	return contents, page.Next, nil
+}
+
+func (p *BitbucketProvider) content(entry bitbucketEntry) GithubContent {
+	content := GithubContent{
+		Name: path.Base(entry.Path),
+		Path: entry.Path,
+		Size: entry.Size,
+	}
+	switch entry.Type {
+	case "commit_directory":
+		content.Type = "dir"
+	case "commit_file":
+		content.Type = "file"
+		content.DownloadURL = p.RawURL(entry.Path)
+	default:
+		content.Type = entry.Type
+	}
+	return content
+}
+
+func (p *BitbucketProvider) DecodeLastCommit(data []byte) (string, error) {
+	var page struct {
+		Values []struct {
+			Hash string `json:"hash"`
+		} `json:"values"`
+	}
+	if err := json.Unmarshal(data, &page); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(page.Values) == 0 {
+		return "", errNoCommit
+	}
+	return page.Values[0].Hash, nil
+}
+
+// resolveBlobSha downloads a file whose provider does not expose git blob
+// ids and derives the id from its content, caching the content so the
+// comparison does not fetch it twice.
+func resolveBlobSha(ctx context.Context, content *GithubContent, opts *Options, pkgdef *Config) error {
+	provider, err := newContentProvider(pkgdef, opts.Token)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", content.DownloadURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	provider.Authorize(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d -> %s", resp.StatusCode, content.Path)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	content.Sha = blobSha(data)
+	blobCache.Store(content.Sha, string(data))
+	return nil
+}
+
+// blobSha is the git blob id of data.
+func blobSha(data []byte) string {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "blob %d\x00", len(data))
+	hash.Write(data)
+	return hex.EncodeToString(hash.Sum(nil))
+}
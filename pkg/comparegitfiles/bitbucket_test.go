@@ -0,0 +1,221 @@
+package comparegitfiles
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeBitbucket serves the src endpoint of the bitbucket repository
+// team/repo holding files at main: the raw content of files, the metadata of
+// any path with ?format=meta and directory listings of one entry per page.
+func fakeBitbucket(t *testing.T, files map[string]string) {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "alice" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		const src = "/repositories/team/repo/src/main/"
+		if !strings.HasPrefix(r.URL.Path, src) {
+			http.NotFound(w, r)
+			return
+		}
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, src), "/")
+		meta := r.URL.Query().Get("format") == "meta"
+		if content, ok := files[path]; ok {
+			if meta {
+				json.NewEncoder(w).Encode(bitbucketEntry{Type: "commit_file", Path: path, Size: int64(len(content))})
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(content))
+			return
+		}
+
+		var entries []bitbucketEntry
+		seen := make(map[string]bool)
+		for name := range files {
+			rest, ok := strings.CutPrefix(name, path+"/")
+			if !ok {
+				continue
+			}
+			child, _, isDir := strings.Cut(rest, "/")
+			child = path + "/" + child
+			if seen[child] {
+				continue
+			}
+			seen[child] = true
+			if isDir {
+				entries = append(entries, bitbucketEntry{Type: "commit_directory", Path: child})
+			} else {
+				entries = append(entries, bitbucketEntry{Type: "commit_file", Path: child, Size: int64(len(files[child]))})
+			}
+		}
+		if len(entries) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		if meta {
+			json.NewEncoder(w).Encode(bitbucketEntry{Type: "commit_directory", Path: path})
+			return
+		}
+		if r.URL.Query().Get("pagelen") == "" && r.URL.Query().Get("page") == "" {
+			http.Error(w, "listing without pagelen", http.StatusBadRequest)
+			return
+		}
+		slices.SortFunc(entries, func(a, b bitbucketEntry) int { return cmp.Compare(a.Path, b.Path) })
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		resp := bitbucketPage{Values: entries[page : page+1]}
+		if page+1 < len(entries) {
+			resp.Next = srv.URL + r.URL.Path + "?page=" + strconv.Itoa(page+1)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+
+	saved := bitbucketAPI
+	bitbucketAPI = srv.URL
+	t.Cleanup(func() { bitbucketAPI = saved })
+	t.Setenv("BITBUCKET_USERNAME", "alice")
+}
+
+func TestBitbucketListContents(t *testing.T) {
+	fakeBitbucket(t, map[string]string{
+		"docs/a.md":     "a\n",
+		"docs/b.md":     "b\n",
+		"docs/sub/c.md": "c\n",
+		"README.md":     "readme\n",
+		"values.json":   `{"values": [], "next": ""}`,
+	})
+	pkg := &Config{Name: "team/repo", Provider: "bitbucket", Branch: "main"}
+	opts := &Options{Token: "secret"}
+	raw := func(path string) string {
+		return bitbucketAPI + "/repositories/team/repo/src/main/" + path
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    []GithubContent
+		wantErr string
+	}{
+		{
+			name: "directory following next",
+			path: "docs",
+			want: []GithubContent{
+				{Name: "a.md", Path: "docs/a.md", Type: "file", Size: 2, DownloadURL: raw("docs/a.md")},
+				{Name: "b.md", Path: "docs/b.md", Type: "file", Size: 2, DownloadURL: raw("docs/b.md")},
+				{Name: "sub", Path: "docs/sub", Type: "dir"},
+			},
+		},
+		{
+			name: "file",
+			path: "README.md",
+			want: []GithubContent{
+				{Name: "README.md", Path: "README.md", Type: "file", Size: 7, DownloadURL: raw("README.md")},
+			},
+		},
+		{
+			name: "json file shaped like a listing",
+			path: "values.json",
+			want: []GithubContent{
+				{Name: "values.json", Path: "values.json", Type: "file", Size: 26, DownloadURL: raw("values.json")},
+			},
+		},
+		{
+			name:    "missing path",
+			path:    "nope",
+			wantErr: "unexpected status code: 404",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := listContents(context.Background(), tt.path, opts, pkg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("listContents error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("listContents = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBitbucketFetch(t *testing.T) {
+	files := map[string]string{
+		"docs/a.md":     "a\n",
+		"docs/sub/c.md": "c\n",
+		"README.md":     "readme\n",
+	}
+	fakeBitbucket(t, files)
+	chdir(t, t.TempDir())
+
+	cfg := &Config{Name: "team/repo", Provider: "bitbucket", Branch: "main", Files: []string{"docs", "README.md"}}
+	if err := NewClient("secret").Fetch(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range files {
+		got, err := os.ReadFile(filepath.FromSlash(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestBitbucketCompare(t *testing.T) {
+	remote := map[string]string{
+		"docs/a.md": "a\n",
+		"docs/b.md": "b\n",
+	}
+	fakeBitbucket(t, remote)
+	localRepo(t, map[string]string{"docs/a.md": "a\n", "docs/b.md": "B\n"})
+
+	cfg := &Config{Name: "team/repo", Provider: "bitbucket", Branch: "main", Files: []string{"docs"}}
+	client := NewClient("secret")
+	statuses := func() map[string]string {
+		t.Helper()
+		results, err := client.Compare(context.Background(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]string)
+		for _, result := range results {
+			got[result.Path] = result.Status
+		}
+		return got
+	}
+	if got, want := statuses(), map[string]string{"docs/a.md": statusIdentical, "docs/b.md": statusChanged}; !reflect.DeepEqual(got, want) {
+		t.Errorf("first run = %v, want %v", got, want)
+	}
+	remote["docs/b.md"] = "B\n"
+	if got, want := statuses(), map[string]string{"docs/a.md": statusIdentical, "docs/b.md": statusIdentical}; !reflect.DeepEqual(got, want) {
+		t.Errorf("second run = %v, want %v", got, want)
+	}
+	// the content downloaded to derive blob ids does not outlive its run
+	count := 0
+	blobCache.Range(func(any, any) bool { count++; return true })
+	if count != 2 {
+		t.Errorf("blobCache holds %d blobs, want the 2 of the last run", count)
+	}
+}
@@ -0,0 +1,138 @@
+package comparegitfiles
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ResourceType string
+
+const (
+	ResourceDirs  ResourceType = "dirs"
+	ResourceBlobs ResourceType = "blobs"
+	ResourceFiles ResourceType = "files"
+)
+
+// resourceTypeOf classifies a request by the API it calls: directory
+// listings, blob lookups by SHA, or raw file downloads.
+func resourceTypeOf(req *http.Request) ResourceType {
+	path := req.URL.Path
+	switch {
+	case strings.Contains(path, "/git/blobs/"), strings.Contains(path, "/repository/blobs/"):
+		return ResourceBlobs
+	case strings.Contains(path, "/git/trees/"), strings.Contains(path, "/contents"),
+		strings.Contains(path, "/repository/tree"):
+		return ResourceDirs
+	case strings.Contains(path, "/src/") && req.URL.Query().Has("pagelen"):
+		// bitbucket serves listings and raw files from the same endpoint
+		return ResourceDirs
+	}
+	return ResourceFiles
+}
+
+// parseRefreshIntervals parses "dirs=1m,blobs=10m" into per-type TTLs.
+func parseRefreshIntervals(value string) (map[ResourceType]time.Duration, error) {
+	intervals := make(map[ResourceType]time.Duration)
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, raw, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid refresh interval %q: expected type=duration", field)
+		}
+		resource := ResourceType(strings.TrimSpace(key))
+		switch resource {
+		case ResourceDirs, ResourceBlobs, ResourceFiles:
+		default:
+			return nil, fmt.Errorf("invalid refresh interval %q: type must be dirs, blobs or files", field)
+		}
+		ttl, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("invalid refresh interval %q: %w", field, err)
+		}
+		intervals[resource] = ttl
+	}
+	return intervals, nil
+}
+
+type cachedResponse struct {
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// cacheTransport keeps successful GET responses in memory by URL, so that
+// -watch iterations only hit the API once the entry's TTL has passed. The TTL
+// comes from -refresh-interval for the request's resource type and falls
+// back to -cache-ttl; a zero TTL disables caching for that type.
+type cacheTransport struct {
+	next      http.RoundTripper
+	ttl       time.Duration
+	intervals map[ResourceType]time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cachedResponse
+}
+
+func (t *cacheTransport) ttlFor(req *http.Request) time.Duration {
+	if ttl, ok := t.intervals[resourceTypeOf(req)]; ok {
+		return ttl
+	}
+	return t.ttl
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ttl := t.ttlFor(req)
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" || ttl <= 0 {
+		return t.next.RoundTrip(req)
+	}
+	key := req.URL.String()
+
+	t.mu.Lock()
+	entry, ok := t.entries[key]
+	t.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", entry.status, http.StatusText(entry.status)),
+			StatusCode:    entry.status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        entry.header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(entry.body)),
+			ContentLength: int64(len(entry.body)),
+			Request:       req,
+		}, nil
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	if t.entries == nil {
+		t.entries = make(map[string]cachedResponse)
+	}
+	t.entries[key] = cachedResponse{
+		status:  resp.StatusCode,
+		header:  resp.Header.Clone(),
+		body:    body,
+		expires: time.Now().Add(ttl),
+	}
+	t.mu.Unlock()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
@@ -0,0 +1,117 @@
+package comparegitfiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var errRangeUnsupported = errors.New("server does not support range requests")
+
+// parseByteSize parses sizes such as 512, 64KB or 10MB using powers of 1024.
+func parseByteSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %q", value)
+	}
+	return n * multiplier, nil
+}
+
+// downloadChunked fetches url as opts.Concurrency parallel range requests,
+// reassembles the parts in memory and renames the result into place. Servers
+// that answer with the whole body fall back to a regular download.
+func downloadChunked(ctx context.Context, url, filePath string, size int64, opts *Options, pkgdef *Config) error {
+	provider, err := newContentProvider(pkgdef, opts.Token)
+	if err != nil {
+		return err
+	}
+	chunks := int64(opts.Concurrency)
+	chunkSize := (size + chunks - 1) / chunks
+	data := make([]byte, size)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, chunks)
+	for start := int64(0); start < size; start += chunkSize {
+		end := min(start+chunkSize, size) - 1
+		wg.Add(1)
+		go func(start, end int64) {
+			defer wg.Done()
+			if err := sem.Acquire(ctx, 1); err != nil {
+				errs <- fmt.Errorf("failed to acquire semaphore: %w", err)
+				return
+			}
+			defer sem.Release(1)
+
+			req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+			if err != nil {
+				errs <- fmt.Errorf("failed to create request: %w", err)
+				return
+			}
+			provider.Authorize(req)
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+			resp, err := client.Do(req)
+			if err != nil {
+				errs <- fmt.Errorf("failed to download file: %w", err)
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusPartialContent {
+				errs <- errRangeUnsupported
+				return
+			}
+			if _, err := io.ReadFull(resp.Body, data[start:end+1]); err != nil {
+				errs <- fmt.Errorf("failed to download bytes %d-%d: %w", start, end, err)
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	close(errs)
+	if err, ok := <-errs; ok {
+		if errors.Is(err, errRangeUnsupported) {
+			return downloadFile(ctx, url, filePath, opts, "", pkgdef)
+		}
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,424 @@
+package comparegitfiles
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// cliFlags holds everything parsed from the command line. Options receives
+// the values that are threaded through the run; the rest only affect setup.
+type cliFlags struct {
+	opts                   Options
+	command                string
+	config                 string
+	schemaValidate         bool
+	commit                 string
+	tag                    string
+	ignoreFileList         string
+	ignorePatterns         stringList
+	versionCommentPatterns stringList
+	retryOnStatus          string
+	backoff                backoff
+	cacheTTL               time.Duration
+	refreshInterval        string
+	followRedirects        bool
+	apiPageSize            int
+	failIfChangedGt        int
+	failIfFilesChangedGt   int
+	failOnRedirect         bool
+	noState                bool
+	resetState             bool
+	stateFile              string
+	notifyEmail            string
+	watch                  bool
+	serve                  string
+	logFormat              string
+	logLevel               string
+	watchOnChange          string
+	watchInterval          time.Duration
+	watchJitter            *time.Duration
+	smtp                   smtpConfig
+	chunkThreshold         string
+	convertTo              string
+	convertOutput          string
+	action                 string
+	hookPath               string
+	hookTemplate           string
+	token                  string
+	tokenFile              string
+	tokenEnv               string
+	authType               string
+	httpDumpBodies         bool
+	auditLog               string
+	proxy                  string
+	requestTimeout         time.Duration
+	totalTimeout           time.Duration
+	noProxy                string
+	showVersion            bool
+	logout                 bool
+	legacyCompare          bool
+	formatSet              bool
+	diffAlgorithm          string
+	parallelFileTypes      string
+}
+
+// stringList collects a flag that may be given several times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+type command struct {
+	summary string
+	flags   func(fs *flag.FlagSet, f *cliFlags)
+	// standalone commands do not talk to the remote and skip the common flags.
+	standalone bool
+	// actions lists the positional argument a command expects, if any.
+	actions []string
+}
+
+var commands = map[string]command{
+	"compare":    {summary: "diff local files against the remote without downloading them", flags: registerCompareFlags},
+	"fetch":      {summary: "download every file listed in the config", flags: registerFetchFlags},
+	"sync":       {summary: "download only the files whose content differs from the remote", flags: registerFetchFlags},
+	"status":     {summary: "list each file as unchanged, changed or missing without fetching content", flags: registerStatusFlags},
+	"convert":    {summary: "print the config file in another format", flags: registerConvertFlags, standalone: true},
+	"hooks":      {summary: "install or uninstall a git pre-commit hook that blocks commits when files drift", flags: registerHooksFlags, standalone: true, actions: []string{"install", "uninstall"}},
+	"auth":       {summary: "store the github token in the os keychain or print the stored one", flags: registerAuthFlags, standalone: true, actions: []string{"set", "get"}},
+	"completion": {summary: "print a shell completion script", standalone: true, actions: completionShells},
+}
+
+func newCLIFlags() *cliFlags {
+	return &cliFlags{
+		opts: Options{
+			ParallelStrategy:    "depth-first",
+			StreamThreshold:     10 * 1024 * 1024,
+			Concurrency:         maxParallel,
+			VersionCommentLines: 10,
+			Format:              "pretty",
+			MaxSimilarity:       100,
+			RenameSimilarity:    0.8,
+		},
+		retryOnStatus:        defaultRetryStatuses,
+		backoff:              defaultBackoff,
+		apiPageSize:          maxAPIPageSize,
+		failIfChangedGt:      -1,
+		failIfFilesChangedGt: -1,
+		stateFile:            defaultStateFile,
+		logFormat:            "text",
+		watchInterval:        5 * time.Minute,
+		convertTo:            "yaml",
+		chunkThreshold:       "10MB",
+		smtp:                 smtpConfig{Port: 587},
+		requestTimeout:       30 * time.Second,
+		authType:             "token",
+		diffAlgorithm:        "line",
+	}
+}
+
+// Flags are registered with their current value as default so that a global
+// flag given before the subcommand survives the subcommand's registration.
+func registerCommonFlags(fs *flag.FlagSet, f *cliFlags) {
+	fs.StringVar(&f.config, "config", f.config, "path to the config file, by default diffs.json or .comparegitfiles.json is searched for in the current and parent directories")
+	fs.BoolVar(&f.schemaValidate, "config-schema-validate", f.schemaValidate, "validate the config file against the bundled JSON Schema before parsing it")
+	fs.BoolVar(&f.opts.Verbose, "verbose", f.opts.Verbose, "verbose")
+	fs.StringVar(&f.logFormat, "log-format", f.logFormat, "log output format: text|json")
+	fs.StringVar(&f.logLevel, "log-level", f.logLevel, "minimum log level: debug|info|warn|error, debug with -verbose and warn otherwise")
+	fs.BoolVar(&f.opts.StreamOutput, "stream-output", f.opts.StreamOutput, "print each file's result as soon as it is done instead of in path order at the end")
+	fs.BoolVar(&f.opts.NoColor, "no-color", f.opts.NoColor, "print diffs as plain +/- lines without ANSI colors")
+	fs.StringVar(&f.opts.Path, "path", f.opts.Path, "path")
+	fs.StringVar(&f.opts.ParallelStrategy, "parallel-strategy", f.opts.ParallelStrategy, "directory traversal order: depth-first|breadth-first")
+	fs.IntVar(&f.opts.Concurrency, "concurrency", f.opts.Concurrency, "maximum number of files fetched or compared at the same time")
+	fs.StringVar(&f.commit, "commit", f.commit, "compare against this commit SHA instead of the branch tip")
+	fs.StringVar(&f.tag, "tag", f.tag, "compare against this tag instead of the branch tip")
+	fs.StringVar(&f.token, "token", f.token, "api token, takes precedence over the environment variables and the os keychain")
+	fs.StringVar(&f.tokenFile, "token-file", f.tokenFile, "read the api token from this file")
+	fs.StringVar(&f.tokenEnv, "token-env", f.tokenEnv, "read the api token from this environment variable instead of the provider's default, e.g. GHE_TOKEN")
+	fs.StringVar(&f.authType, "auth-type", f.authType, "github Authorization scheme: token|bearer|basic|none, basic expects the token as username:password")
+	fs.StringVar(&f.opts.VersionsFile, "write-versions-file", f.opts.VersionsFile, "write a JSON object mapping every file to the remote commit that last touched it")
+	fs.StringVar(&f.auditLog, "audit-log", f.auditLog, "append a JSON record of every compared or downloaded file to this file")
+	fs.DurationVar(&f.requestTimeout, "request-timeout", f.requestTimeout, "maximum duration of a single HTTP request including its body, 0 disables it")
+	fs.DurationVar(&f.totalTimeout, "total-timeout", f.totalTimeout, "maximum duration of fetching or comparing all files, 0 disables it")
+	fs.StringVar(&f.proxy, "proxy", f.proxy, "proxy url for all requests, overrides HTTP_PROXY and HTTPS_PROXY")
+	fs.StringVar(&f.noProxy, "no-proxy", f.noProxy, "comma-separated hosts to reach without the proxy in addition to NO_PROXY, e.g. *.internal.example.com")
+	fs.StringVar(&f.opts.TLSCACert, "tls-ca-cert", f.opts.TLSCACert, "PEM CA bundle to trust in addition to the system roots, e.g. for a TLS-intercepting proxy")
+	fs.StringVar(&f.opts.TLSCert, "tls-cert", f.opts.TLSCert, "PEM client certificate for mutual TLS, requires -tls-key")
+	fs.StringVar(&f.opts.TLSKey, "tls-key", f.opts.TLSKey, "PEM private key of -tls-cert")
+	fs.BoolVar(&f.opts.TLSSkipVerify, "tls-skip-verify", f.opts.TLSSkipVerify, "disable TLS certificate verification, insecure")
+	fs.StringVar(&f.opts.HTTPDebug, "http-debug", f.opts.HTTPDebug, "log raw HTTP requests and responses to this file, and method, url, status and latency at -log-level debug")
+	fs.BoolVar(&f.httpDumpBodies, "http-dump-bodies", f.httpDumpBodies, "with -http-debug, also log the first 1KB of request and response bodies at -log-level debug")
+	fs.StringVar(&f.ignoreFileList, "ignore-file-list", f.ignoreFileList, "file with additional ignore patterns, one per line")
+	fs.Var(&f.ignorePatterns, "ignore-pattern", "regular expression of paths to skip, can be repeated")
+	fs.Var((*stringList)(&f.opts.Exclude), "exclude", "path or regular expression to skip in addition to ignore, can be repeated")
+	fs.Var((*stringList)(&f.opts.Include), "include", "path or regular expression to compare even if ignore excludes it, can be repeated")
+	fs.BoolVar(&f.opts.StrictGlobs, "strict-globs", f.opts.StrictGlobs, "fail when a glob in files matches no remote file")
+	fs.BoolVar(&f.opts.UseTreesAPI, "use-trees-api", f.opts.UseTreesAPI, "list github directories with the git trees api")
+	fs.BoolVar(&f.watch, "watch", f.watch, "repeat the run every -watch-interval until interrupted")
+	fs.DurationVar(&f.watchInterval, "watch-interval", f.watchInterval, "time between runs in -watch mode")
+	fs.StringVar(&f.watchOnChange, "watch-on-change", f.watchOnChange, "shell command to run after a compare -watch run that found differences")
+	fs.Func("watch-jitter", "random delay of up to this duration added to each -watch-interval (default 10% of -watch-interval)", func(value string) error {
+		jitter, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		f.watchJitter = &jitter
+		return nil
+	})
+	fs.StringVar(&f.retryOnStatus, "retry-on-status", f.retryOnStatus, "comma-separated HTTP status codes that trigger a retry")
+	fs.Float64Var(&f.backoff.factor, "rate-limit-backoff-factor", f.backoff.factor, "multiply the delay between retries by this factor after each attempt")
+	fs.DurationVar(&f.backoff.maxDelay, "rate-limit-max-delay", f.backoff.maxDelay, "upper bound for the delay between retries")
+	fs.Float64Var(&f.backoff.jitter, "rate-limit-jitter-factor", f.backoff.jitter, "add up to this fraction of the delay at random to each retry")
+	fs.IntVar(&f.apiPageSize, "api-page-size", f.apiPageSize, "entries per directory listing page on gitlab and bitbucket, at most 100; github lists a directory in one response")
+	fs.BoolVar(&f.followRedirects, "follow-api-redirects", f.followRedirects, "log a warning for every redirect, e.g. of a renamed or transferred repository")
+	fs.BoolVar(&f.failOnRedirect, "fail-on-redirect", f.failOnRedirect, "fail instead of following redirects")
+	fs.DurationVar(&f.cacheTTL, "cache-ttl", f.cacheTTL, "keep API responses in memory for this long, mainly useful with -watch; 0 disables")
+	fs.StringVar(&f.refreshInterval, "refresh-interval", f.refreshInterval, "per resource type cache TTLs overriding -cache-ttl, e.g. dirs=1m,blobs=10m")
+}
+
+func registerOutputFlags(fs *flag.FlagSet, f *cliFlags) {
+	fs.StringVar(&f.opts.Output, "output", f.opts.Output, "write diffs or the json report to this file instead of stdout")
+	fs.StringVar(&f.opts.Format, "format", f.opts.Format, "output format: pretty|json|csv|html|patch|sarif|junit|github-actions, github-actions by default when GITHUB_ACTIONS=true")
+}
+
+func registerCompareFlags(fs *flag.FlagSet, f *cliFlags) {
+	registerOutputFlags(fs, f)
+	fs.StringVar(&f.serve, "serve", f.serve, "serve Prometheus metrics of the last run on /metrics at this address, e.g. :9090")
+	fs.StringVar(&f.opts.RebaseOnto, "rebase-onto", f.opts.RebaseOnto, "apply the upstream changes to this local branch with git apply --index and commit them")
+	fs.StringVar(&f.opts.DiffContextSeparator, "diff-context-separator", f.opts.DiffContextSeparator, "replace the @@ hunk headers of -format patch with this line, e.g. '--- snip ---'")
+	fs.StringVar(&f.opts.DiffSQLite, "write-diff-sqlite", f.opts.DiffSQLite, "append the results of every run to this SQLite database")
+	fs.StringVar(&f.opts.ComplianceReport, "write-compliance-report", f.opts.ComplianceReport, "write a dated PASSED/FAILED attestation of every checked file, as PDF for .pdf and HTML otherwise")
+	fs.IntVar(&f.failIfChangedGt, "fail-if-changed-gt", f.failIfChangedGt, "exit 2 when more than this many lines changed in total, -1 disables")
+	fs.IntVar(&f.failIfFilesChangedGt, "fail-if-files-changed-gt", f.failIfFilesChangedGt, "exit 2 when more than this many files changed, -1 disables")
+	fs.Int64Var(&f.opts.StreamThreshold, "stream-threshold", f.opts.StreamThreshold, "file size in bytes above which verbose diffs are streamed to disk")
+	fs.BoolVar(&f.opts.SideBySide, "side-by-side", f.opts.SideBySide, "show local and remote lines in two columns")
+	fs.BoolVar(&f.opts.SideBySide, "y", f.opts.SideBySide, "shorthand for -side-by-side")
+	fs.BoolVar(&f.opts.LineNumbers, "line-numbers", f.opts.LineNumbers, "prefix diff lines with their line number, e.g. L42: -old line")
+	fs.IntVar(&f.opts.Width, "width", f.opts.Width, "terminal width for -side-by-side, detected when 0")
+	fs.IntVar(&f.opts.SplitLargeDiffs, "split-large-diffs", f.opts.SplitLargeDiffs, "show verbose diffs in pages of this many changed lines")
+	fs.BoolVar(&f.opts.DiffOnlyChangedDirs, "diff-only-changed-dirs", f.opts.DiffOnlyChangedDirs, "skip directories whose local tree sha matches the remote one")
+	fs.BoolVar(&f.opts.ReportSymlinkTargets, "report-symlink-targets", f.opts.ReportSymlinkTargets, "compare symlinks by their target path instead of the content they point to")
+	fs.BoolVar(&f.opts.ValidateUTF8, "validate-utf8", f.opts.ValidateUTF8, "report files that are not valid UTF-8 instead of diffing them")
+	fs.BoolVar(&f.opts.ComparePermissions, "compare-include-permissions", f.opts.ComparePermissions, "report files whose executable bit differs from the remote mode")
+	fs.Float64Var(&f.opts.MinSimilarity, "min-similarity", f.opts.MinSimilarity, "hide the diffs of files less similar than this percentage")
+	fs.Float64Var(&f.opts.MaxSimilarity, "max-similarity", f.opts.MaxSimilarity, "hide the diffs of files more similar than this percentage")
+	fs.BoolVar(&f.opts.DetectRenames, "detect-renames", f.opts.DetectRenames, "match files missing locally with similar local files the remote does not list")
+	fs.Float64Var(&f.opts.RenameSimilarity, "rename-similarity", f.opts.RenameSimilarity, "minimum similarity ratio, 0.0 to 1.0, of a rename found by -detect-renames")
+	fs.BoolVar(&f.opts.NormalizeWhitespace, "compare-normalize-whitespace", f.opts.NormalizeWhitespace, "collapse runs of spaces and tabs within a line before comparing")
+	fs.StringVar(&f.diffAlgorithm, "diff-algorithm", f.diffAlgorithm, "line compares lines at the same position, myers finds inserted and removed lines, word diffs words")
+	fs.BoolVar(&f.opts.IgnoreLeadingSpaces, "ignore-leading-spaces", f.opts.IgnoreLeadingSpaces, "treat lines that only differ in indentation as equal but show them marked [indent]")
+	fs.BoolVar(&f.opts.CompareNullTolerant, "compare-null-tolerant", f.opts.CompareNullTolerant, "treat null and missing members of .json files as equal")
+	fs.BoolVar(&f.opts.CompareTerraform, "compare-terraform", f.opts.CompareTerraform, "compare .tf files semantically, ignoring comments and formatting")
+	fs.BoolVar(&f.opts.CompareAsTemplate, "compare-as-template", f.opts.CompareAsTemplate, "parse .tmpl, .gotmpl and .tpl files with text/template and compare the parse trees, ignoring comments and whitespace")
+	fs.BoolVar(&f.opts.CompareProto, "compare-proto", f.opts.CompareProto, "report schema-level changes for .proto files instead of a text diff")
+	fs.BoolVar(&f.opts.CompareOpenAPI, "compare-openapi", f.opts.CompareOpenAPI, "report endpoint, schema and authentication changes for OpenAPI/Swagger files")
+	fs.BoolVar(&f.opts.CompareKubernetes, "compare-kubernetes", f.opts.CompareKubernetes, "report label, replica, image and spec changes for Kubernetes manifests instead of a text diff")
+	fs.BoolVar(&f.opts.CompareDocker, "compare-docker", f.opts.CompareDocker, "report added, removed and changed instructions for Dockerfiles instead of a text diff")
+	fs.BoolVar(&f.opts.IgnoreVersionComments, "compare-ignore-version-comments", f.opts.IgnoreVersionComments, "skip copyright, version and generated-code comments at the top of files")
+	fs.Var(&f.versionCommentPatterns, "version-comment-pattern", "regular expression of header comments to skip, replaces the defaults, can be repeated")
+	fs.IntVar(&f.opts.VersionCommentLines, "version-comment-lines", f.opts.VersionCommentLines, "number of lines at the top of a file checked for version comments")
+	fs.StringVar(&f.opts.GraphvizOutput, "output-graphviz", f.opts.GraphvizOutput, "write the compared directory structure as a DOT graph to this file")
+	fs.BoolVar(&f.opts.AutoConfigureIgnore, "auto-configure-ignore", f.opts.AutoConfigureIgnore, "compare and suggest ignore patterns for files that only produce diff noise")
+	fs.StringVar(&f.opts.PRDescription, "write-pr-description", f.opts.PRDescription, "compare and write a markdown pull request description to this file")
+	fs.StringVar(&f.opts.HTMLFragment, "write-diff-html-fragment", f.opts.HTMLFragment, "write the diffs as HTML tables without a page wrapper to this file")
+	fs.BoolVar(&f.opts.IncludeStyles, "include-styles", f.opts.IncludeStyles, "add a <style> block to -write-diff-html-fragment")
+	fs.BoolVar(&f.noState, "no-state", f.noState, "do not read or write the comparison state file")
+	fs.BoolVar(&f.resetState, "reset-state", f.resetState, "delete the comparison state file before running")
+	fs.StringVar(&f.stateFile, "state-file", f.stateFile, "path of the comparison state file")
+	fs.StringVar(&f.notifyEmail, "notify-email", f.notifyEmail, "email the report to this address when files differ")
+	fs.StringVar(&f.smtp.Host, "smtp-host", f.smtp.Host, "SMTP server used by -notify-email")
+	fs.IntVar(&f.smtp.Port, "smtp-port", f.smtp.Port, "SMTP server port")
+	fs.StringVar(&f.smtp.User, "smtp-user", f.smtp.User, "SMTP username, also used as sender when it is an address")
+	fs.StringVar(&f.smtp.Pass, "smtp-pass", f.smtp.Pass, "SMTP password, defaults to COMPAREGITFILES_SMTP_PASS")
+	fs.BoolVar(&f.smtp.TLS, "smtp-tls", f.smtp.TLS, "connect to the SMTP server over TLS instead of STARTTLS")
+}
+
+// reportsAllResults reports whether the run writes a report that must list
+// every differing file, which rules out skipping files via the state file.
+func (f *cliFlags) reportsAllResults() bool {
+	return f.opts.AutoConfigureIgnore || f.opts.PRDescription != "" || f.opts.HTMLFragment != "" || f.notifyEmail != "" || f.opts.DiffSQLite != "" || f.opts.ComplianceReport != "" || f.serve != "" || f.opts.RebaseOnto != "" || f.opts.VersionsFile != "" || f.opts.Format != "pretty"
+}
+
+func registerFetchFlags(fs *flag.FlagSet, f *cliFlags) {
+	fs.BoolVar(&f.opts.CreateDirsOnly, "create-dirs-only", f.opts.CreateDirsOnly, "create the remote directory structure locally without downloading files")
+	fs.BoolVar(&f.opts.ParallelDownload, "parallel-download", f.opts.ParallelDownload, "download large files as parallel HTTP range requests")
+	fs.StringVar(&f.parallelFileTypes, "parallel-file-types", f.parallelFileTypes, "comma-separated extensions downloaded in parallel, e.g. go,yaml,json, other files are downloaded one at a time")
+	fs.StringVar(&f.chunkThreshold, "chunk-threshold", f.chunkThreshold, "file size above which -parallel-download splits the download, e.g. 10MB")
+	fs.BoolVar(&f.opts.CopyPermissions, "copy-permissions", f.opts.CopyPermissions, "apply the remote file mode to downloaded files")
+	fs.BoolVar(&f.opts.DedupIdentical, "dedup-identical", f.opts.DedupIdentical, "hard-link files whose content was already downloaded instead of fetching them again")
+	fs.StringVar(&f.opts.TerraformBackend, "write-terraform-backend", f.opts.TerraformBackend, "write a terraform http backend block pointing at the synced .tfstate file")
+	fs.StringVar(&f.opts.ShellScript, "write-shell-script", f.opts.ShellScript, "write a curl/wget shell script that downloads the synced files again")
+	fs.StringVar(&f.opts.DependabotConfig, "write-dependabot-config", f.opts.DependabotConfig, "write a dependabot.yml with an update entry per ecosystem of the synced manifests, e.g. .github/dependabot.yml")
+	fs.StringVar(&f.opts.NginxInclude, "write-nginx-include", f.opts.NginxInclude, "write an nginx file with an include directive for every synced .conf file")
+	fs.StringVar(&f.opts.GoEmbed, "write-go-embed", f.opts.GoEmbed, "write synced_files.go with //go:embed directives for the synced files into this package directory")
+}
+
+func registerConvertFlags(fs *flag.FlagSet, f *cliFlags) {
+	fs.StringVar(&f.config, "config", f.config, "path to the config file to convert")
+	fs.StringVar(&f.convertTo, "to", f.convertTo, "output format: yaml|toml")
+	fs.StringVar(&f.convertOutput, "o", f.convertOutput, "write the converted config to this file instead of stdout")
+}
+
+func registerHooksFlags(fs *flag.FlagSet, f *cliFlags) {
+	fs.StringVar(&f.config, "config", f.config, "config file the hook passes to comparegitfiles")
+	fs.StringVar(&f.hookPath, "hook-path", f.hookPath, "hooks directory to use instead of the one git reports, e.g. for core.hooksPath")
+	fs.StringVar(&f.hookTemplate, "hook-template", f.hookTemplate, "text/template file to render the hook from instead of the bundled one")
+}
+
+func registerAuthFlags(fs *flag.FlagSet, f *cliFlags) {
+	fs.StringVar(&f.token, "token", f.token, "token to store with auth set, read from stdin when empty")
+}
+
+func registerStatusFlags(fs *flag.FlagSet, f *cliFlags) {
+	registerOutputFlags(fs, f)
+	fs.StringVar(&f.opts.GraphvizOutput, "output-graphviz", f.opts.GraphvizOutput, "write the compared directory structure as a DOT graph to this file")
+}
+
+// newGlobalFlagSet registers the flags accepted before the command.
+func newGlobalFlagSet(f *cliFlags) *flag.FlagSet {
+	global := flag.NewFlagSet("comparegitfiles", flag.ExitOnError)
+	registerCommonFlags(global, f)
+	registerCompareFlags(global, f)
+	registerFetchFlags(global, f)
+	global.BoolVar(&f.legacyCompare, "compare", false, "deprecated: use the compare command")
+	global.BoolVar(&f.showVersion, "version", false, "print version information and exit")
+	global.BoolVar(&f.logout, "logout", false, "delete the cached github token and exit")
+	global.Usage = func() { printUsage(global) }
+	return global
+}
+
+// parseArgs accepts either `[global flags] <command> [command flags]` or the
+// pre-subcommand flag set, where -compare selects compare and its absence
+// selects fetch.
+func parseArgs(args []string) (*cliFlags, error) {
+	f := newCLIFlags()
+	global := newGlobalFlagSet(f)
+	global.Parse(args)
+
+	rest := global.Args()
+	if len(rest) == 0 {
+		f.command = "fetch"
+		if f.legacyCompare {
+			f.command = "compare"
+		}
+		if !f.showVersion && !f.logout {
+			fmt.Fprintf(os.Stderr, "Running without a command is deprecated; use `comparegitfiles %s`\n", f.command)
+		}
+		f.formatSet = isFlagSet(global, "format")
+		return f.finish(), nil
+	}
+	if f.legacyCompare {
+		return nil, fmt.Errorf("-compare cannot be combined with the %s command", rest[0])
+	}
+
+	cmd, ok := commands[rest[0]]
+	if !ok {
+		printUsage(global)
+		return nil, fmt.Errorf("unknown command: %s", rest[0])
+	}
+	f.command = rest[0]
+	fs := flag.NewFlagSet(f.command, flag.ExitOnError)
+	if !cmd.standalone {
+		registerCommonFlags(fs, f)
+	}
+	if cmd.flags != nil {
+		cmd.flags(fs, f)
+	}
+	fs.Parse(rest[1:])
+	f.formatSet = isFlagSet(global, "format") || isFlagSet(fs, "format")
+	if len(cmd.actions) > 0 {
+		if fs.NArg() == 0 || !slices.Contains(cmd.actions, fs.Arg(0)) {
+			return nil, fmt.Errorf("usage: comparegitfiles %s %s [flags]", f.command, strings.Join(cmd.actions, "|"))
+		}
+		f.action = fs.Arg(0)
+		// flags may follow the action as well
+		fs.Parse(fs.Args()[1:])
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	return f.finish(), nil
+}
+
+func (f *cliFlags) finish() *cliFlags {
+	switch f.command {
+	case "compare":
+		f.opts.Compare = true
+	case "sync":
+		f.opts.Sync = true
+	case "status":
+		f.opts.Compare = true
+		f.opts.Status = true
+	}
+	if f.opts.Compare && !f.formatSet && inGitHubActions() {
+		f.opts.Format = "github-actions"
+	}
+	return f
+}
+
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(fl *flag.Flag) {
+		if fl.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+const exitCodeHelp = `
+Exit codes with -fail-if-changed-gt or -fail-if-files-changed-gt:
+  0  no differences
+  1  differences within the thresholds
+  2  a threshold was exceeded, or the run failed
+Without them a run exits 0, or 1 when it fails.
+`
+
+// usesThresholds reports whether -fail-if-changed-gt or
+// -fail-if-files-changed-gt is set.
+func (f *cliFlags) usesThresholds() bool {
+	return f.failIfChangedGt >= 0 || f.failIfFilesChangedGt >= 0
+}
+
+// thresholdExitCode returns the exit code for a finished comparison as
+// described in exitCodeHelp.
+func (f *cliFlags) thresholdExitCode(results []DiffResult) int {
+	if !f.usesThresholds() || len(results) == 0 {
+		return 0
+	}
+	changed := 0
+	for _, result := range results {
+		changed += result.Changes
+	}
+	if f.failIfChangedGt >= 0 && changed > f.failIfChangedGt {
+		fmt.Fprintf(os.Stderr, "%d changed lines exceed -fail-if-changed-gt %d\n", changed, f.failIfChangedGt)
+		return 2
+	}
+	if f.failIfFilesChangedGt >= 0 && len(results) > f.failIfFilesChangedGt {
+		fmt.Fprintf(os.Stderr, "%d changed files exceed -fail-if-files-changed-gt %d\n", len(results), f.failIfFilesChangedGt)
+		return 2
+	}
+	return 1
+}
+
+func printUsage(global *flag.FlagSet) {
+	out := global.Output()
+	fmt.Fprintln(out, "Usage: comparegitfiles [--config file] <command> [flags]")
+	fmt.Fprintln(out, "\nCommands:")
+	for _, name := range sortedKeys(commands) {
+		fmt.Fprintf(out, "  %-10s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprint(out, exitCodeHelp)
+	fmt.Fprintln(out, "\nRun `comparegitfiles <command> -h` for the flags of a command.")
+	fmt.Fprintln(out, "\nGlobal flags:")
+	global.PrintDefaults()
+}
@@ -0,0 +1,66 @@
+package comparegitfiles
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExitCodes(t *testing.T) {
+	remote := map[string]string{
+		"conf/app.yaml":   "a: 1\nb: 2\n",
+		"conf/db.yaml":    "host: db\n",
+		"conf/same.yaml":  "same\n",
+		"other/same.yaml": "same\n",
+	}
+	drifted := map[string]string{
+		"conf/app.yaml":   "a: 1\nb: 3\n",
+		"conf/db.yaml":    "host: localhost\n",
+		"conf/same.yaml":  "same\n",
+		"other/same.yaml": "same\n",
+	}
+	tests := []struct {
+		name   string
+		local  map[string]string
+		args   []string
+		code   int
+		stderr string
+	}{
+		{name: "differences without thresholds", local: drifted, code: 0},
+		{name: "within changed lines", local: drifted, args: []string{"-fail-if-changed-gt", "4"}, code: 1},
+		{name: "above changed lines", local: drifted, args: []string{"-fail-if-changed-gt", "3"}, code: 2, stderr: "4 changed lines exceed -fail-if-changed-gt 3"},
+		{name: "within changed files", local: drifted, args: []string{"-fail-if-files-changed-gt", "2"}, code: 1},
+		{name: "above changed files", local: drifted, args: []string{"-fail-if-files-changed-gt", "1"}, code: 2, stderr: "2 changed files exceed -fail-if-files-changed-gt 1"},
+		{name: "no differences", local: remote, args: []string{"-fail-if-changed-gt", "0"}, code: 0},
+		{name: "identical path", local: drifted, args: []string{"-fail-if-changed-gt", "0", "-path", "other"}, code: 0},
+		{name: "failed run", local: drifted, args: []string{"-path", "missing"}, code: 1},
+		{name: "failed run with thresholds", local: drifted, args: []string{"-fail-if-changed-gt", "10", "-path", "missing"}, code: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gh, dir := newCompareRepo(t, remote, tt.local, "conf")
+			_, stderr, code := runMain(t, dir, gh.URL, append([]string{"compare", "-no-state"}, tt.args...)...)
+			if code != tt.code {
+				t.Errorf("exit code = %d, want %d\n%s", code, tt.code, stderr)
+			}
+			if !strings.Contains(stderr, tt.stderr) {
+				t.Errorf("stderr does not contain %q:\n%s", tt.stderr, stderr)
+			}
+		})
+	}
+}
+
+func TestExitCodesWithStateFile(t *testing.T) {
+	gh, dir := newCompareRepo(t,
+		map[string]string{"conf/app.yaml": "a: 1\nb: 2\n", "conf/same.yaml": "  same\n"},
+		map[string]string{"conf/app.yaml": "a: 1\nb: 3\n", "conf/same.yaml": "same\n"},
+		"conf")
+	for run := 1; run <= 3; run++ {
+		_, stderr, code := runMain(t, dir, gh.URL, "compare", "-fail-if-files-changed-gt", "0")
+		if code != 2 {
+			t.Fatalf("run %d: exit code = %d, want 2\n%s", run, code, stderr)
+		}
+	}
+	if blobs := gh.requested("/repos/owner/repo/git/blobs/" + blobSha([]byte("  same\n"))); len(blobs) != 1 {
+		t.Errorf("fetched the blob of conf/same.yaml %d times, want once and then skipped through the state file", len(blobs))
+	}
+}
@@ -0,0 +1,306 @@
+package comparegitfiles
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// completionShells lists the shells generateCompletion writes scripts for.
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// completionValues lists the values completed after flags that only accept
+// a fixed set of them.
+var completionValues = map[string][]string{
+	"parallel-strategy": {"depth-first", "breadth-first"},
+	"format":            {"pretty", "json", "csv", "html", "patch", "sarif", "junit", "github-actions"},
+	"diff-algorithm":    {"line", "myers", "word"},
+	"auth-type":         {"token", "bearer", "basic", "none"},
+	"log-format":        {"text", "json"},
+	"log-level":         {"debug", "info", "warn", "error"},
+	"to":                {"yaml", "toml"},
+}
+
+// completionFileFlags are completed with local paths.
+var completionFileFlags = map[string]bool{
+	"config":                   true,
+	"path":                     true,
+	"o":                        true,
+	"output":                   true,
+	"http-debug":               true,
+	"ignore-file-list":         true,
+	"state-file":               true,
+	"audit-log":                true,
+	"token-file":               true,
+	"tls-ca-cert":              true,
+	"tls-cert":                 true,
+	"tls-key":                  true,
+	"hook-path":                true,
+	"hook-template":            true,
+	"output-graphviz":          true,
+	"write-pr-description":     true,
+	"write-diff-html-fragment": true,
+	"write-diff-sqlite":        true,
+	"write-compliance-report":  true,
+	"write-terraform-backend":  true,
+	"write-shell-script":       true,
+	"write-dependabot-config":  true,
+	"write-nginx-include":      true,
+	"write-go-embed":           true,
+	"write-versions-file":      true,
+}
+
+// completionSpec describes what may follow the command name. The flags
+// accepted before a command are stored under "".
+type completionSpec struct {
+	commands []string
+	flags    map[string][]*flag.Flag
+}
+
+func newCompletionSpec() completionSpec {
+	spec := completionSpec{
+		commands: sortedKeys(commands),
+		flags:    map[string][]*flag.Flag{"": visitFlags(newGlobalFlagSet(newCLIFlags()))},
+	}
+	for name, cmd := range commands {
+		f := newCLIFlags()
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		if !cmd.standalone {
+			registerCommonFlags(fs, f)
+		}
+		if cmd.flags != nil {
+			cmd.flags(fs, f)
+		}
+		spec.flags[name] = visitFlags(fs)
+	}
+	return spec
+}
+
+func visitFlags(fs *flag.FlagSet) []*flag.Flag {
+	var flags []*flag.Flag
+	fs.VisitAll(func(fl *flag.Flag) {
+		flags = append(flags, fl)
+	})
+	return flags
+}
+
+func isBoolFlag(fl *flag.Flag) bool {
+	b, ok := fl.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// words returns the completions after command, or after the program name
+// when command is empty.
+func (s completionSpec) words(command string) []string {
+	words := slices.Clone(commands[command].actions)
+	if command == "" {
+		words = append(words, s.commands...)
+	}
+	for _, fl := range s.flags[command] {
+		words = append(words, "-"+fl.Name)
+	}
+	return words
+}
+
+// valueFlags returns the flags that take a value which is neither a path nor
+// one of completionValues, so nothing is completed after them.
+func (s completionSpec) valueFlags() []string {
+	seen := make(map[string]bool)
+	for _, flags := range s.flags {
+		for _, fl := range flags {
+			if !isBoolFlag(fl) && !completionFileFlags[fl.Name] && completionValues[fl.Name] == nil {
+				seen[fl.Name] = true
+			}
+		}
+	}
+	return sortedKeys(seen)
+}
+
+// generateCompletion writes a completion script for shell to w. The script
+// completes command names, flag names and the values of flags that take a
+// path or one of a fixed set of values.
+func generateCompletion(shell string, w io.Writer) error {
+	spec := newCompletionSpec()
+	switch shell {
+	case "bash":
+		writeBashCompletion(w, spec)
+	case "zsh":
+		writeZshCompletion(w, spec)
+	case "fish":
+		writeFishCompletion(w, spec)
+	case "powershell":
+		writePowerShellCompletion(w, spec)
+	default:
+		return fmt.Errorf("unsupported shell %q, expected one of %s", shell, strings.Join(completionShells, ", "))
+	}
+	return nil
+}
+
+func writeBashCompletion(w io.Writer, spec completionSpec) {
+	fmt.Fprintln(w, "# bash completion for comparegitfiles. Load it in the current shell with")
+	fmt.Fprintln(w, "#   source <(comparegitfiles completion bash)")
+	fmt.Fprintln(w, "# or install it for every session with")
+	fmt.Fprintln(w, "#   comparegitfiles completion bash > ~/.local/share/bash-completion/completions/comparegitfiles")
+	fmt.Fprintln(w, "_comparegitfiles() {")
+	fmt.Fprintln(w, "\tlocal cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]} cmd= words i")
+	fmt.Fprintln(w, "\tfor ((i = 1; i < COMP_CWORD; i++)); do")
+	fmt.Fprintln(w, "\t\tcase ${COMP_WORDS[i]} in")
+	fmt.Fprintf(w, "\t\t%s) cmd=${COMP_WORDS[i]}; break ;;\n", strings.Join(spec.commands, "|"))
+	fmt.Fprintln(w, "\t\tesac")
+	fmt.Fprintln(w, "\tdone")
+	fmt.Fprintln(w, "\tprev=${prev#-}")
+	fmt.Fprintln(w, "\tprev=${prev#-}")
+	fmt.Fprintln(w, "\tcase $prev in")
+	fmt.Fprintf(w, "\t%s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", strings.Join(sortedKeys(completionFileFlags), "|"))
+	for _, name := range sortedKeys(completionValues) {
+		fmt.Fprintf(w, "\t%s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")); return ;;\n", name, strings.Join(completionValues[name], " "))
+	}
+	if valueFlags := spec.valueFlags(); len(valueFlags) > 0 {
+		fmt.Fprintf(w, "\t%s) return ;;\n", strings.Join(valueFlags, "|"))
+	}
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "\tcase $cmd in")
+	for _, name := range spec.commands {
+		fmt.Fprintf(w, "\t%s) words=\"%s\" ;;\n", name, strings.Join(spec.words(name), " "))
+	}
+	fmt.Fprintf(w, "\t*) words=\"%s\" ;;\n", strings.Join(spec.words(""), " "))
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "\tCOMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -o filenames -F _comparegitfiles comparegitfiles")
+}
+
+func writeZshCompletion(w io.Writer, spec completionSpec) {
+	fmt.Fprintln(w, "#compdef comparegitfiles")
+	fmt.Fprintln(w, "# zsh completion for comparegitfiles. Load it in the current shell with")
+	fmt.Fprintln(w, "#   source <(comparegitfiles completion zsh)")
+	fmt.Fprintln(w, "# or install it for every session with")
+	fmt.Fprintln(w, "#   comparegitfiles completion zsh > \"${fpath[1]}/_comparegitfiles\"")
+	fmt.Fprintln(w, "_comparegitfiles() {")
+	fmt.Fprintln(w, "\tlocal cmd= word prev=${${words[CURRENT-1]#-}#-}")
+	fmt.Fprintln(w, "\tfor word in ${words[2,CURRENT-1]}; do")
+	fmt.Fprintln(w, "\t\tcase $word in")
+	fmt.Fprintf(w, "\t\t%s) cmd=$word; break ;;\n", strings.Join(spec.commands, "|"))
+	fmt.Fprintln(w, "\t\tesac")
+	fmt.Fprintln(w, "\tdone")
+	fmt.Fprintln(w, "\tcase $prev in")
+	fmt.Fprintf(w, "\t%s) _files; return ;;\n", strings.Join(sortedKeys(completionFileFlags), "|"))
+	for _, name := range sortedKeys(completionValues) {
+		fmt.Fprintf(w, "\t%s) compadd -- %s; return ;;\n", name, strings.Join(completionValues[name], " "))
+	}
+	if valueFlags := spec.valueFlags(); len(valueFlags) > 0 {
+		fmt.Fprintf(w, "\t%s) return 1 ;;\n", strings.Join(valueFlags, "|"))
+	}
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "\tcase $cmd in")
+	for _, name := range spec.commands {
+		fmt.Fprintf(w, "\t%s) compadd -- %s ;;\n", name, strings.Join(spec.words(name), " "))
+	}
+	fmt.Fprintf(w, "\t*) compadd -- %s ;;\n", strings.Join(spec.words(""), " "))
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "if [ \"$funcstack[1]\" = _comparegitfiles ]; then")
+	fmt.Fprintln(w, "\t_comparegitfiles \"$@\"")
+	fmt.Fprintln(w, "else")
+	fmt.Fprintln(w, "\tcompdef _comparegitfiles comparegitfiles")
+	fmt.Fprintln(w, "fi")
+}
+
+func writeFishCompletion(w io.Writer, spec completionSpec) {
+	fmt.Fprintln(w, "# fish completion for comparegitfiles. Load it in the current shell with")
+	fmt.Fprintln(w, "#   comparegitfiles completion fish | source")
+	fmt.Fprintln(w, "# or install it for every session with")
+	fmt.Fprintln(w, "#   comparegitfiles completion fish > ~/.config/fish/completions/comparegitfiles.fish")
+	fmt.Fprintln(w, "complete -c comparegitfiles -f")
+	for _, name := range spec.commands {
+		fmt.Fprintf(w, "complete -c comparegitfiles -n __fish_use_subcommand -a %s -d %s\n", name, fishQuote(commands[name].summary))
+		if actions := commands[name].actions; len(actions) > 0 {
+			fmt.Fprintf(w, "complete -c comparegitfiles -n '__fish_seen_subcommand_from %s' -a %s\n", name, fishQuote(strings.Join(actions, " ")))
+		}
+	}
+	for _, command := range append([]string{""}, spec.commands...) {
+		condition := "__fish_use_subcommand"
+		if command != "" {
+			condition = fishQuote("__fish_seen_subcommand_from " + command)
+		}
+		for _, fl := range spec.flags[command] {
+			fmt.Fprintf(w, "complete -c comparegitfiles -n %s -o %s", condition, fl.Name)
+			switch {
+			case isBoolFlag(fl):
+			case completionFileFlags[fl.Name]:
+				fmt.Fprint(w, " -r -F")
+			case completionValues[fl.Name] != nil:
+				fmt.Fprintf(w, " -x -a %s", fishQuote(strings.Join(completionValues[fl.Name], " ")))
+			default:
+				fmt.Fprint(w, " -x")
+			}
+			fmt.Fprintf(w, " -d %s\n", fishQuote(fl.Usage))
+		}
+	}
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+func writePowerShellCompletion(w io.Writer, spec completionSpec) {
+	fmt.Fprintln(w, "# PowerShell completion for comparegitfiles. Load it in the current session with")
+	fmt.Fprintln(w, "#   comparegitfiles completion powershell | Out-String | Invoke-Expression")
+	fmt.Fprintln(w, "# and add that line to $PROFILE to load it in every session.")
+	fmt.Fprintln(w, "Register-ArgumentCompleter -Native -CommandName comparegitfiles -ScriptBlock {")
+	fmt.Fprintln(w, "\tparam($wordToComplete, $commandAst, $cursorPosition)")
+	fmt.Fprintf(w, "\t$commands = %s\n", psArray(spec.commands))
+	fmt.Fprintln(w, "\t$words = @{")
+	fmt.Fprintf(w, "\t\t'' = %s\n", psArray(spec.words("")))
+	for _, name := range spec.commands {
+		fmt.Fprintf(w, "\t\t%s = %s\n", psQuote(name), psArray(spec.words(name)))
+	}
+	fmt.Fprintln(w, "\t}")
+	fmt.Fprintln(w, "\t$values = @{")
+	for _, name := range sortedKeys(completionValues) {
+		fmt.Fprintf(w, "\t\t%s = %s\n", psQuote(name), psArray(completionValues[name]))
+	}
+	fmt.Fprintln(w, "\t}")
+	fmt.Fprintf(w, "\t$valueFlags = %s\n", psArray(append(sortedKeys(completionFileFlags), spec.valueFlags()...)))
+	fmt.Fprintln(w, "\t$elements = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })")
+	fmt.Fprintln(w, "\tif ($wordToComplete -ne '') {")
+	fmt.Fprintln(w, "\t\t$elements = @($elements | Select-Object -SkipLast 1)")
+	fmt.Fprintln(w, "\t}")
+	fmt.Fprintln(w, "\t$command = ''")
+	fmt.Fprintln(w, "\tforeach ($element in $elements) {")
+	fmt.Fprintln(w, "\t\tif ($commands -contains $element) {")
+	fmt.Fprintln(w, "\t\t\t$command = $element")
+	fmt.Fprintln(w, "\t\t\tbreak")
+	fmt.Fprintln(w, "\t\t}")
+	fmt.Fprintln(w, "\t}")
+	fmt.Fprintln(w, "\t$prev = ''")
+	fmt.Fprintln(w, "\tif ($elements.Count -gt 0) {")
+	fmt.Fprintln(w, "\t\t$prev = $elements[-1] -replace '^--?', ''")
+	fmt.Fprintln(w, "\t}")
+	fmt.Fprintln(w, "\tif ($values.ContainsKey($prev)) {")
+	fmt.Fprintln(w, "\t\t$candidates = $values[$prev]")
+	fmt.Fprintln(w, "\t} elseif ($valueFlags -contains $prev) {")
+	fmt.Fprintln(w, "\t\t# no results fall back to completing paths")
+	fmt.Fprintln(w, "\t\treturn")
+	fmt.Fprintln(w, "\t} else {")
+	fmt.Fprintln(w, "\t\t$candidates = $words[$command]")
+	fmt.Fprintln(w, "\t}")
+	fmt.Fprintln(w, "\t$candidates | Where-Object { $_ -like \"$wordToComplete*\" } | ForEach-Object {")
+	fmt.Fprintln(w, "\t\t[System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)")
+	fmt.Fprintln(w, "\t}")
+	fmt.Fprintln(w, "}")
+}
+
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func psArray(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = psQuote(item)
+	}
+	return "@(" + strings.Join(quoted, ", ") + ")"
+}
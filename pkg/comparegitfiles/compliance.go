@@ -0,0 +1,162 @@
+package comparegitfiles
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//go:embed compliance.html
+var complianceReportSource string
+
+var complianceReportTemplate = template.Must(template.New("compliance.html").Parse(complianceReportSource))
+
+type complianceReport struct {
+	Repository string
+	Ref        string
+	Timestamp  string
+	Operator   string
+	Tool       string
+	Passed     bool
+	Status     string
+	Files      []DiffResult
+}
+
+func newComplianceReport(pkgdef *Config, results []DiffResult) complianceReport {
+	ref := resolveRef(pkgdef)
+	if ref == "" {
+		ref = "HEAD"
+	}
+	report := complianceReport{
+		Repository: pkgdef.Name,
+		Ref:        ref,
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		Operator:   gitOperator(),
+		Tool:       versionString(),
+		Passed:     true,
+		Status:     "PASSED",
+		Files:      results,
+	}
+	for _, result := range results {
+		if result.Status != statusIdentical {
+			report.Passed = false
+			report.Status = "FAILED"
+			break
+		}
+	}
+	return report
+}
+
+// gitOperator returns "name <email>" from the git config of the current
+// directory, or "unknown" when git has neither.
+func gitOperator() string {
+	config := func(key string) string {
+		output, err := exec.Command("git", "config", key).Output()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(output))
+	}
+	name, email := config("user.name"), config("user.email")
+	switch {
+	case name != "" && email != "":
+		return fmt.Sprintf("%s <%s>", name, email)
+	case name != "":
+		return name
+	case email != "":
+		return email
+	default:
+		return "unknown"
+	}
+}
+
+// writeComplianceReport writes a dated attestation of every checked file,
+// as a PDF when path ends in .pdf and as HTML otherwise.
+func writeComplianceReport(path string, pkgdef *Config, results []DiffResult) error {
+	report := newComplianceReport(pkgdef, results)
+	if strings.EqualFold(filepath.Ext(path), ".pdf") {
+		return writeCompliancePDF(path, report)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create compliance report: %w", err)
+	}
+	defer file.Close()
+	if err := complianceReportTemplate.Execute(file, report); err != nil {
+		return fmt.Errorf("failed to write compliance report: %w", err)
+	}
+	return nil
+}
+
+func writeCompliancePDF(path string, report complianceReport) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	// The core fonts only cover cp1252.
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetTitle("Compliance report: "+report.Repository, true)
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.Cell(0, 10, "Compliance report")
+	pdf.Ln(12)
+	pdf.SetFont("Helvetica", "B", 14)
+	if report.Passed {
+		pdf.SetTextColor(26, 127, 55)
+	} else {
+		pdf.SetTextColor(207, 34, 46)
+	}
+	pdf.Cell(0, 8, report.Status)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.Ln(12)
+
+	pdf.SetFont("Helvetica", "", 10)
+	for _, row := range [][2]string{
+		{"Repository", report.Repository},
+		{"Ref", report.Ref},
+		{"Run at", report.Timestamp},
+		{"Operator", report.Operator},
+		{"Tool", report.Tool},
+	} {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(30, 6, row[0], "", 0, "", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(0, 6, tr(row[1]), "", 1, "", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.Cell(0, 8, "Checked files")
+	pdf.Ln(10)
+	widths := []float64{62, 20, 54, 54}
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(246, 248, 250)
+	for i, header := range []string{"File", "Status", "Local sha", "Remote sha"} {
+		pdf.CellFormat(widths[i], 6, header, "1", 0, "", true, 0, "")
+	}
+	pdf.Ln(-1)
+	for _, file := range report.Files {
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.CellFormat(widths[0], 6, tr(file.Path), "1", 0, "", false, 0, "")
+		pdf.CellFormat(widths[1], 6, file.Status, "1", 0, "", false, 0, "")
+		pdf.SetFont("Courier", "", 6)
+		pdf.CellFormat(widths[2], 6, file.LocalSha, "1", 0, "", false, 0, "")
+		pdf.CellFormat(widths[3], 6, file.RemoteSha, "1", 1, "", false, 0, "")
+	}
+
+	pdf.Ln(25)
+	pdf.Line(pdf.GetX(), pdf.GetY(), pdf.GetX()+80, pdf.GetY())
+	pdf.Ln(2)
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.Cell(0, 6, "Signature, name and date")
+
+	if err := pdf.OutputFileAndClose(path); err != nil {
+		return fmt.Errorf("failed to write compliance report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package comparegitfiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+var configFileNames = []string{"diffs.json", ".comparegitfiles.json", ".comparegitfiles.yaml", ".comparegitfiles.yml", ".comparegitfiles.toml"}
+
+// findConfigFile walks up from start until a directory contains one of
+// configFileNames, the way git looks for .git.
+func findConfigFile(start string) (string, error) {
+	root, err := filepath.Abs(start)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", start, err)
+	}
+	dir := root
+	for {
+		for _, name := range configFileNames {
+			candidate := filepath.Join(dir, name)
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+				return candidate, nil
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no diffs.json or .comparegitfiles.{json,yaml,toml} found in %s or any parent directory, use -config to point at one", root)
+		}
+		dir = parent
+	}
+}
+
+// resolveConfigPath returns path when it was given explicitly and otherwise
+// the config discovered from the working directory.
+func resolveConfigPath(path string) (string, error) {
+	if path == "" {
+		return findConfigFile(".")
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", fmt.Errorf("config file %q passed to -config does not exist", path)
+	}
+	return path, nil
+}
+
+func configFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	}
+	return "json"
+}
+
+// parseConfig decodes a config file, choosing YAML, TOML or JSON from its
+// extension.
+func parseConfig(data []byte, filename string) (*Config, error) {
+	var pkg *Config
+	var err error
+	switch configFormat(filename) {
+	case "yaml":
+		err = yaml.Unmarshal(data, &pkg)
+	case "toml":
+		pkg = &Config{}
+		err = toml.Unmarshal(data, pkg)
+	default:
+		err = json.Unmarshal(data, &pkg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	return pkg, nil
+}
+
+func convertConfig(path, format, output string) error {
+	path, err := resolveConfigPath(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	pkg, err := parseConfig(data, path)
+	if err != nil {
+		return err
+	}
+	if pkg == nil {
+		pkg = &Config{}
+	}
+
+	var converted []byte
+	switch format {
+	case "yaml":
+		converted, err = yaml.Marshal(pkg)
+	case "toml":
+		converted, err = toml.Marshal(pkg)
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to convert %s: %w", path, err)
+	}
+	if output == "" {
+		_, err = os.Stdout.Write(converted)
+		return err
+	}
+	return os.WriteFile(output, converted, 0644)
+}
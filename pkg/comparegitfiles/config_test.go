@@ -0,0 +1,68 @@
+package comparegitfiles
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseConfigTOML(t *testing.T) {
+	data := []byte(`
+name = "owner/repo" # the repository
+branch = "main"
+provider = "github"
+files = [
+  "conf",   # shared settings
+  "Makefile",
+]
+ignore = ["conf/local.yaml", "!conf/local.yaml.example"]
+`)
+	want := &Config{
+		Name:     "owner/repo",
+		Branch:   "main",
+		Provider: "github",
+		Files:    []string{"conf", "Makefile"},
+		Ignore:   []string{"conf/local.yaml", "!conf/local.yaml.example"},
+	}
+	got, err := parseConfig(data, ".comparegitfiles.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseConfig = %+v, want %+v", got, want)
+	}
+}
+
+func TestConvertConfigRoundTrip(t *testing.T) {
+	configs := []*Config{
+		{Name: "owner/repo", Files: []string{"conf"}},
+		{Name: "owner/repo", Branch: "main", Files: []string{"conf", "src/**/*.go"}, Ignore: []string{"conf/secret.yaml"}},
+		{Name: "group/sub/project", Provider: "gitlab", Tag: "v1.2.3", Files: []string{"ci"}},
+		{Name: "owner/repo", Commit: "0123abc", Files: []string{"a", "b"}, Ignore: []string{"a/x", "!a/x/keep"}},
+	}
+	for _, format := range []string{"toml", "yaml"} {
+		for _, cfg := range configs {
+			t.Run(format+" "+cfg.Name, func(t *testing.T) {
+				dir := t.TempDir()
+				src := filepath.Join(dir, "diffs.json")
+				writeFile(t, src, mustJSON(t, cfg))
+				dst := filepath.Join(dir, "converted."+format)
+				if err := convertConfig(src, format, dst); err != nil {
+					t.Fatal(err)
+				}
+				data, err := os.ReadFile(dst)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := parseConfig(data, dst)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, cfg) {
+					t.Errorf("round trip through %s = %+v, want %+v\n%s", format, got, cfg, data)
+				}
+			})
+		}
+	}
+}
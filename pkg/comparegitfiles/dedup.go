@@ -0,0 +1,60 @@
+package comparegitfiles
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var downloadedSHAs sync.Map
+
+type downloadedBlob struct {
+	done chan struct{}
+	path string
+	err  error
+}
+
+func fetchDeduplicated(ctx context.Context, content GithubContent, filePath string, opts *Options, pkgdef *Config) error {
+	blob := &downloadedBlob{done: make(chan struct{}), path: filePath}
+	existing, loaded := downloadedSHAs.LoadOrStore(content.Sha, blob)
+	if !loaded {
+		blob.err = downloadFile(ctx, content.DownloadURL, filePath, opts, content.Sha, pkgdef)
+		close(blob.done)
+		return blob.err
+	}
+
+	first := existing.(*downloadedBlob)
+	<-first.done
+	if first.err != nil {
+		return downloadFile(ctx, content.DownloadURL, filePath, opts, content.Sha, pkgdef)
+	}
+	return linkOrCopy(first.path, filePath)
+}
+
+func linkOrCopy(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace %s: %w", dst, err)
+	}
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer out.Close()
+	_, err = io.Copy(out, in)
+	return err
+}
@@ -0,0 +1,100 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// dependabotManifests maps the manifest files dependabot understands to
+// their package ecosystem.
+var dependabotManifests = map[string]string{
+	"go.mod":           "gomod",
+	"package.json":     "npm",
+	"requirements.txt": "pip",
+	"pyproject.toml":   "pip",
+	"Pipfile":          "pip",
+	"Gemfile":          "bundler",
+	"Cargo.toml":       "cargo",
+	"composer.json":    "composer",
+	"pom.xml":          "maven",
+	"build.gradle":     "gradle",
+	"build.gradle.kts": "gradle",
+	"Dockerfile":       "docker",
+}
+
+type dependabotUpdate struct {
+	ecosystem string
+	directory string
+}
+
+// dependabotEcosystem returns the ecosystem and the directory dependabot
+// scans for a synced file, or false when dependabot cannot update it.
+func dependabotEcosystem(file string) (dependabotUpdate, bool) {
+	file = filepath.ToSlash(filepath.Clean(file))
+	dir := path.Join("/", path.Dir(file))
+	if strings.Contains("/"+file, "/.github/workflows/") {
+		// workflows are always looked up below .github/workflows of the
+		// configured directory
+		return dependabotUpdate{"github-actions", path.Join("/", strings.TrimSuffix(dir, ".github/workflows"))}, true
+	}
+	if ecosystem, ok := dependabotManifests[path.Base(file)]; ok {
+		return dependabotUpdate{ecosystem, dir}, true
+	}
+	if strings.HasSuffix(file, ".tf") {
+		return dependabotUpdate{"terraform", dir}, true
+	}
+	return dependabotUpdate{}, false
+}
+
+// writeDependabotConfig writes a dependabot.yml with one update entry per
+// ecosystem and directory of the synced manifests. Without any, a
+// github-actions entry keeps the workflow that runs comparegitfiles up to
+// date.
+func writeDependabotConfig(output string, pkgdef *Config, files []string) error {
+	seen := make(map[dependabotUpdate]bool)
+	var updates []dependabotUpdate
+	for _, file := range files {
+		update, ok := dependabotEcosystem(file)
+		if ok && !seen[update] {
+			seen[update] = true
+			updates = append(updates, update)
+		}
+	}
+	if len(updates) == 0 {
+		updates = append(updates, dependabotUpdate{"github-actions", "/"})
+	}
+	sort.Slice(updates, func(i, j int) bool {
+		if updates[i].ecosystem != updates[j].ecosystem {
+			return updates[i].ecosystem < updates[j].ecosystem
+		}
+		return updates[i].directory < updates[j].directory
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by comparegitfiles -write-dependabot-config for the files synced from %s.\n", pkgdef.Name)
+	b.WriteString("version: 2\n")
+	b.WriteString("updates:\n")
+	for _, update := range updates {
+		fmt.Fprintf(&b, "  - package-ecosystem: %s\n", strconv.Quote(update.ecosystem))
+		fmt.Fprintf(&b, "    directory: %s\n", strconv.Quote(update.directory))
+		b.WriteString("    schedule:\n")
+		b.WriteString("      interval: \"weekly\"\n")
+	}
+
+	if dir := filepath.Dir(output); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to write dependabot config: %w", err)
+		}
+	}
+	if err := os.WriteFile(output, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write dependabot config: %w", err)
+	}
+	slog.Info("dependabot config written", "path", output)
+	return nil
+}
@@ -0,0 +1,160 @@
+package comparegitfiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	githubDeviceCodeURL  = "https://github.com/login/device/code"
+	githubAccessTokenURL = "https://github.com/login/oauth/access_token"
+	deviceGrantType      = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+type deviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+type deviceToken struct {
+	AccessToken string `json:"access_token"`
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func cachedTokenPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "comparegitfiles", "token"), nil
+}
+
+func readCachedToken() (string, error) {
+	path, err := cachedTokenPath()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func saveCachedToken(token string) error {
+	path, err := cachedTokenPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to cache token: %w", err)
+	}
+	return nil
+}
+
+func deleteCachedToken() error {
+	path, err := cachedTokenPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete cached token: %w", err)
+	}
+	return nil
+}
+
+// githubTokenFallback is used when GITHUB_TOKEN is not set: it returns the
+// cached device-flow token or, on an interactive terminal, starts the flow.
+func githubTokenFallback(missing error) (string, error) {
+	if token, err := readCachedToken(); err == nil && token != "" {
+		return token, nil
+	}
+	clientID, isSet := os.LookupEnv("GITHUB_CLIENT_ID")
+	if !isSet || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", missing
+	}
+	token, err := deviceFlowToken(clientID)
+	if err != nil {
+		return "", err
+	}
+	if err := saveCachedToken(token); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func postForm(endpoint string, form url.Values, out interface{}) error {
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d -> %s", resp.StatusCode, endpoint)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+func deviceFlowToken(clientID string) (string, error) {
+	var code deviceCode
+	if err := postForm(githubDeviceCodeURL, url.Values{"client_id": {clientID}, "scope": {"repo"}}, &code); err != nil {
+		return "", err
+	}
+
+	fmt.Printf("Open %s and enter the code: %s\n", code.VerificationURI, code.UserCode)
+
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+	form := url.Values{
+		"client_id":   {clientID},
+		"device_code": {code.DeviceCode},
+		"grant_type":  {deviceGrantType},
+	}
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+		var token deviceToken
+		if err := postForm(githubAccessTokenURL, form, &token); err != nil {
+			return "", err
+		}
+		switch token.Error {
+		case "":
+			return token.AccessToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			return "", fmt.Errorf("device authorization failed: %s %s", token.Error, token.Description)
+		}
+	}
+	return "", errors.New("device authorization failed: code expired")
+}
@@ -0,0 +1,170 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Differ computes the changes between two versions of a file. compareFile
+// uses Options.Differ when it is set and LineDiffer otherwise.
+type Differ interface {
+	Diff(before, after string) DiffOutput
+}
+
+// Hunk is a run of changed lines that are next to each other.
+type Hunk struct {
+	Lines []DiffLine
+}
+
+// DiffStats counts the added and removed lines. Indentation-only lines of
+// -ignore-leading-spaces are not counted.
+type DiffStats struct {
+	Added   int
+	Removed int
+}
+
+// DiffOutput is the result of a Differ.
+type DiffOutput struct {
+	Hunks []Hunk
+	Stats DiffStats
+}
+
+// Lines returns the lines of all hunks in order.
+func (o DiffOutput) Lines() []DiffLine {
+	var lines []DiffLine
+	for _, hunk := range o.Hunks {
+		lines = append(lines, hunk.Lines...)
+	}
+	return lines
+}
+
+// newDiffOutput groups lines into hunks, starting a new one whenever
+// continues reports that lines[i] does not continue the hunk of lines[i-1].
+func newDiffOutput(lines []DiffLine, continues func(i int) bool) DiffOutput {
+	var output DiffOutput
+	for i, line := range lines {
+		if i == 0 || !continues(i) {
+			output.Hunks = append(output.Hunks, Hunk{})
+		}
+		hunk := &output.Hunks[len(output.Hunks)-1]
+		hunk.Lines = append(hunk.Lines, line)
+		if line.Indent {
+			continue
+		}
+		switch line.Kind {
+		case '+':
+			output.Stats.Added++
+		case '-':
+			output.Stats.Removed++
+		}
+	}
+	return output
+}
+
+// editOutput turns the changes of an edit script into a DiffOutput. Changes
+// that follow each other in the script, without an unchanged element in
+// between, form one hunk.
+func editOutput(edits []edit, toLine func(e edit) DiffLine) DiffOutput {
+	var lines []DiffLine
+	var positions []int
+	for i, e := range edits {
+		if e.kind != editEqual {
+			lines = append(lines, toLine(e))
+			positions = append(positions, i)
+		}
+	}
+	return newDiffOutput(lines, func(i int) bool {
+		return positions[i] == positions[i-1]+1
+	})
+}
+
+// linePos is the position of a line on whichever side it belongs to.
+func linePos(line DiffLine) int {
+	if line.Kind == '+' {
+		return line.NewLine
+	}
+	return line.OldLine
+}
+
+// LineDiffer compares the files line by line at the same position, with
+// surrounding whitespace trimmed. It is the default algorithm.
+type LineDiffer struct {
+	IgnoreLeadingSpaces bool
+}
+
+func (d LineDiffer) Diff(before, after string) DiffOutput {
+	var lines []DiffLine
+	walkDiffLines(before, after, d.IgnoreLeadingSpaces, func(line DiffLine) error {
+		lines = append(lines, line)
+		return nil
+	})
+	// lines at the same position belong to one hunk, as do consecutive ones
+	return newDiffOutput(lines, func(i int) bool {
+		return linePos(lines[i])-linePos(lines[i-1]) <= 1
+	})
+}
+
+// MyersDiffer computes the shortest edit script between the lines of both
+// versions, so inserted or removed lines do not shift every line after
+// them into the diff.
+type MyersDiffer struct{}
+
+func (MyersDiffer) Diff(before, after string) DiffOutput {
+	return editOutput(myersDiff(splitDiffLines(before), splitDiffLines(after)), func(e edit) DiffLine {
+		if e.kind == editDelete {
+			return DiffLine{Kind: '-', Text: e.line, OldLine: e.oldPos + 1}
+		}
+		return DiffLine{Kind: '+', Text: e.line, NewLine: e.newPos + 1}
+	})
+}
+
+func splitDiffLines(s string) []string {
+	s = strings.TrimRight(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// WordDiffer computes the shortest edit script between the words of both
+// versions and reports each changed word as a line, with the line number
+// the word is on. Whitespace and line breaks between words are ignored.
+type WordDiffer struct{}
+
+// splitWords returns the words of s and the line number of each.
+func splitWords(s string) ([]string, []int) {
+	var words []string
+	var lines []int
+	for i, line := range strings.Split(s, "\n") {
+		for _, word := range strings.Fields(line) {
+			words = append(words, word)
+			lines = append(lines, i+1)
+		}
+	}
+	return words, lines
+}
+
+func (WordDiffer) Diff(before, after string) DiffOutput {
+	oldWords, oldLines := splitWords(before)
+	newWords, newLines := splitWords(after)
+	return editOutput(myersDiff(oldWords, newWords), func(e edit) DiffLine {
+		if e.kind == editDelete {
+			return DiffLine{Kind: '-', Text: e.line, OldLine: oldLines[e.oldPos]}
+		}
+		return DiffLine{Kind: '+', Text: e.line, NewLine: newLines[e.newPos]}
+	})
+}
+
+// newDiffer returns the Differ for -diff-algorithm.
+func newDiffer(algorithm string, ignoreLeadingSpaces bool) (Differ, error) {
+	switch algorithm {
+	case "", "line":
+		return LineDiffer{IgnoreLeadingSpaces: ignoreLeadingSpaces}, nil
+	case "myers":
+		return MyersDiffer{}, nil
+	case "word":
+		return WordDiffer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown diff algorithm %q, expected line, myers or word", algorithm)
+	}
+}
@@ -0,0 +1,250 @@
+package comparegitfiles
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// MockDiffer returns Output for every pair of contents and records the
+// pairs it was asked to diff.
+type MockDiffer struct {
+	Output DiffOutput
+
+	mu    sync.Mutex
+	calls [][2]string
+}
+
+func (m *MockDiffer) Diff(before, after string) DiffOutput {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, [2]string{before, after})
+	return m.Output
+}
+
+// hunkLines renders the lines of each hunk like formatDiff.
+func hunkLines(output DiffOutput) [][]string {
+	var hunks [][]string
+	for _, hunk := range output.Hunks {
+		var lines []string
+		for _, line := range hunk.Lines {
+			lines = append(lines, line.String())
+		}
+		hunks = append(hunks, lines)
+	}
+	return hunks
+}
+
+func TestDiffers(t *testing.T) {
+	tests := []struct {
+		name          string
+		differ        Differ
+		before, after string
+		hunks         [][]string
+		stats         DiffStats
+	}{
+		{name: "line changed", differ: LineDiffer{}, before: "a\nb\nc\n", after: "a\nB\nc\n", hunks: [][]string{{"-b", "+B"}}, stats: DiffStats{1, 1}},
+		{name: "line identical", differ: LineDiffer{}, before: "same\n", after: "same\n"},
+		{name: "line surrounding whitespace", differ: LineDiffer{}, before: "  a\nb\n", after: "a\nb\n"},
+		{
+			name: "line inserted shifts every line", differ: LineDiffer{}, before: "a\nb\nc\n", after: "x\na\nb\nc\n",
+			hunks: [][]string{{"-a", "+x", "-b", "+a", "-c", "+b", "+c"}}, stats: DiffStats{4, 3},
+		},
+		{name: "myers changed", differ: MyersDiffer{}, before: "a\nb\nc\n", after: "a\nB\nc\n", hunks: [][]string{{"-b", "+B"}}, stats: DiffStats{1, 1}},
+		{name: "myers inserted", differ: MyersDiffer{}, before: "a\nb\nc\n", after: "x\na\nb\nc\n", hunks: [][]string{{"+x"}}, stats: DiffStats{1, 0}},
+		{
+			name: "myers separate hunks", differ: MyersDiffer{}, before: "a\nb\nc\nd\ne\n", after: "a\nc\nd\nE\n",
+			hunks: [][]string{{"-b"}, {"-e", "+E"}}, stats: DiffStats{1, 2},
+		},
+		{name: "myers whitespace", differ: MyersDiffer{}, before: "  a\nb\n", after: "a\nb\n", hunks: [][]string{{"-  a", "+a"}}, stats: DiffStats{1, 1}},
+		{
+			name: "word changed", differ: WordDiffer{}, before: "one two\nthree\n", after: "one 2\nthree four\n",
+			hunks: [][]string{{"-two", "+2"}, {"+four"}}, stats: DiffStats{2, 1},
+		},
+		{name: "word whitespace", differ: WordDiffer{}, before: "one  two\nthree\n", after: "one two three\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := tt.differ.Diff(tt.before, tt.after)
+			if got := hunkLines(output); !reflect.DeepEqual(got, tt.hunks) {
+				t.Errorf("hunks = %q, want %q", got, tt.hunks)
+			}
+			if output.Stats != tt.stats {
+				t.Errorf("stats = %+v, want %+v", output.Stats, tt.stats)
+			}
+		})
+	}
+}
+
+func TestDiffStats(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []DiffLine
+		want  DiffStats
+	}{
+		{name: "empty"},
+		{name: "all additions", lines: []DiffLine{{Kind: '+', Text: "a"}, {Kind: '+', Text: "b"}, {Kind: '+', Text: "c"}}, want: DiffStats{Added: 3}},
+		{name: "all deletions", lines: []DiffLine{{Kind: '-', Text: "a"}, {Kind: '-', Text: "b"}}, want: DiffStats{Removed: 2}},
+		{
+			name:  "mixed",
+			lines: []DiffLine{{Kind: '-', Text: "a"}, {Kind: '+', Text: "A"}, {Kind: '-', Text: "b"}, {Kind: '+', Text: "B"}, {Kind: '+', Text: "c"}},
+			want:  DiffStats{Added: 3, Removed: 2},
+		},
+		{
+			name:  "indent lines",
+			lines: []DiffLine{{Kind: '-', Text: "  a", Indent: true}, {Kind: '+', Text: "    a", Indent: true}, {Kind: '+', Text: "b"}},
+			want:  DiffStats{Added: 1},
+		},
+		{name: "empty changed lines", lines: []DiffLine{{Kind: '+'}, {Kind: '-'}}, want: DiffStats{Added: 1, Removed: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := newDiffOutput(tt.lines, func(int) bool { return true })
+			if output.Stats != tt.want {
+				t.Errorf("stats = %+v, want %+v", output.Stats, tt.want)
+			}
+			if got := output.Lines(); len(got) != len(tt.lines) {
+				t.Errorf("output holds %d lines, want %d", len(got), len(tt.lines))
+			}
+		})
+	}
+}
+
+func TestNewDiffer(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		want      Differ
+	}{
+		{algorithm: "", want: LineDiffer{IgnoreLeadingSpaces: true}},
+		{algorithm: "line", want: LineDiffer{IgnoreLeadingSpaces: true}},
+		{algorithm: "myers", want: MyersDiffer{}},
+		{algorithm: "word", want: WordDiffer{}},
+		{algorithm: "patience"},
+	}
+	for _, tt := range tests {
+		differ, err := newDiffer(tt.algorithm, true)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("newDiffer(%q) = %T, want an error", tt.algorithm, differ)
+			}
+			continue
+		}
+		if err != nil || differ != tt.want {
+			t.Errorf("newDiffer(%q) = %#v, %v, want %#v", tt.algorithm, differ, err, tt.want)
+		}
+	}
+}
+
+// fileDiff is the part of a DiffResult that comes from the Differ.
+type fileDiff struct {
+	status         string
+	added, removed int
+	diff           string
+}
+
+func TestMockDifferResults(t *testing.T) {
+	canned := DiffOutput{
+		Hunks: []Hunk{
+			{Lines: []DiffLine{{Kind: '-', Text: "canned old", OldLine: 7}, {Kind: '+', Text: "canned new", NewLine: 7}}},
+			{Lines: []DiffLine{{Kind: '+', Text: "canned extra", NewLine: 20}}},
+		},
+		Stats: DiffStats{Added: 2, Removed: 1},
+	}
+	tests := []struct {
+		name   string
+		output DiffOutput
+		want   map[string]fileDiff
+	}{
+		{
+			name:   "canned changes",
+			output: canned,
+			want: map[string]fileDiff{
+				"conf/app.yaml":  {statusChanged, 2, 1, "-canned old\n+canned new\n+canned extra\n"},
+				"conf/same.yaml": {statusIdentical, 0, 0, ""},
+			},
+		},
+		{
+			name: "no changes",
+			want: map[string]fileDiff{
+				"conf/app.yaml":  {statusIdentical, 0, 0, ""},
+				"conf/same.yaml": {statusIdentical, 0, 0, ""},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newCompareRepo(t, driftRemote, driftLocal, "conf")
+			mock := &MockDiffer{Output: tt.output}
+			results, err := NewClient("secret", WithDiffer(mock)).Compare(context.Background(), &Config{Name: "owner/repo", Files: []string{"conf"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]fileDiff)
+			for _, result := range results {
+				got[result.Path] = fileDiff{result.Status, result.Added, result.Removed, result.Diff}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("results = %+v, want %+v", got, tt.want)
+			}
+			// identical blobs are not diffed
+			wantCalls := [][2]string{{driftLocal["conf/app.yaml"], driftRemote["conf/app.yaml"]}}
+			if !reflect.DeepEqual(mock.calls, wantCalls) {
+				t.Errorf("Diff called with %q, want %q", mock.calls, wantCalls)
+			}
+		})
+	}
+}
+
+func TestClientRestoresRunState(t *testing.T) {
+	newCompareRepo(t, driftRemote, driftLocal, "conf")
+	savedClient, savedSem := client, sem
+	if _, err := NewClient("secret", WithConcurrency(2)).Compare(context.Background(), &Config{Name: "owner/repo", Files: []string{"conf"}}); err != nil {
+		t.Fatal(err)
+	}
+	if client != savedClient || sem != savedSem {
+		t.Error("Compare left its http client or semaphore behind")
+	}
+}
+
+func TestMockDifferRendering(t *testing.T) {
+	newCompareRepo(t, driftRemote, driftLocal, "conf")
+	resetRunState()
+	useIgnoreRules(t, nil, nil, &Options{})
+	var out bytes.Buffer
+	defer func(saved io.Writer) { diffOut = saved }(diffOut)
+	diffOut = &out
+
+	opts := &Options{
+		Compare:          true,
+		Verbose:          true,
+		Token:            "secret",
+		ParallelStrategy: "depth-first",
+		Concurrency:      maxParallel,
+		Format:           "pretty",
+		MaxSimilarity:    100,
+		Differ: &MockDiffer{Output: DiffOutput{
+			Hunks: []Hunk{{Lines: []DiffLine{{Kind: '-', Text: "canned old", OldLine: 7}, {Kind: '+', Text: "canned new", NewLine: 7}}}},
+			Stats: DiffStats{Added: 1, Removed: 1},
+		}},
+	}
+	if err := updateDependencies(context.Background(), opts, &Config{Name: "owner/repo", Files: []string{"conf"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pendingDiffs.render(opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"conf/app.yaml", "canned old", "canned new"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("rendered diff does not contain %q:\n%s", want, out.String())
+		}
+	}
+	for _, absent := range []string{"b: 2", "b: 3", "conf/same.yaml"} {
+		if strings.Contains(out.String(), absent) {
+			t.Errorf("rendered diff contains %q instead of the canned output:\n%s", absent, out.String())
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+type dockerInstruction struct {
+	Command string
+	Text    string
+	Line    int
+}
+
+func isDockerfile(path string) bool {
+	base := filepath.Base(path)
+	return base == "Dockerfile" || strings.HasSuffix(strings.ToLower(base), ".dockerfile")
+}
+
+func parseDockerfile(src, filename string) ([]dockerInstruction, error) {
+	result, err := parser.Parse(strings.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	instructions := make([]dockerInstruction, 0, len(result.AST.Children))
+	for _, node := range result.AST.Children {
+		instructions = append(instructions, dockerInstruction{
+			Command: strings.ToUpper(node.Value),
+			Text:    strings.Join(strings.Fields(node.Original), " "),
+			Line:    node.StartLine,
+		})
+	}
+	return instructions, nil
+}
+
+// compareDockerfile aligns both instruction lists on their longest common
+// subsequence. Within each unaligned gap, a removed and an added instruction
+// with the same command are reported as one change.
+func compareDockerfile(local, remote, filename string) ([]string, error) {
+	before, err := parseDockerfile(local, filename)
+	if err != nil {
+		return nil, err
+	}
+	after, err := parseDockerfile(remote, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	lcs := make([][]int, len(before)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(after)+1)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			if before[i].Text == after[j].Text {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var changes []string
+	var removed, added []dockerInstruction
+	flush := func() {
+		for _, old := range removed {
+			paired := false
+			for k, instruction := range added {
+				if instruction.Command == old.Command {
+					changes = append(changes, fmt.Sprintf("[CHANGED] %s instruction at line %d", instruction.Command, instruction.Line))
+					added = append(added[:k], added[k+1:]...)
+					paired = true
+					break
+				}
+			}
+			if !paired {
+				changes = append(changes, fmt.Sprintf("[REMOVED] %s", old.Command))
+			}
+		}
+		for _, instruction := range added {
+			changes = append(changes, fmt.Sprintf("[ADDED] %s instruction", instruction.Command))
+		}
+		removed, added = nil, nil
+	}
+
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case i < len(before) && j < len(after) && before[i].Text == after[j].Text:
+			flush()
+			i++
+			j++
+		case j == len(after) || (i < len(before) && lcs[i+1][j] >= lcs[i][j+1]):
+			removed = append(removed, before[i])
+			i++
+		default:
+			added = append(added, after[j])
+			j++
+		}
+	}
+	flush()
+	return changes, nil
+}
@@ -0,0 +1,36 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"log/slog"
+	"unicode/utf8"
+)
+
+// invalidUTF8Offset returns the byte offset of the first invalid UTF-8
+// sequence in s, or -1 when s is valid.
+func invalidUTF8Offset(s string) int {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size <= 1 {
+			return i
+		}
+		i += size
+	}
+	return -1
+}
+
+// reportInvalidUTF8 prints an encoding error for filePath when either side
+// is not valid UTF-8 and reports whether the diff should be skipped.
+func reportInvalidUTF8(filePath, local, remote string) bool {
+	if utf8.ValidString(local) && utf8.ValidString(remote) {
+		return false
+	}
+	if offset := invalidUTF8Offset(local); offset >= 0 {
+		slog.Warn("invalid UTF-8", "side", "local", "path", filePath, "offset", offset)
+	}
+	if offset := invalidUTF8Offset(remote); offset >= 0 {
+		slog.Warn("invalid UTF-8", "side", "remote", "path", filePath, "offset", offset)
+	}
+	fmt.Fprintf(diffOut, "[ENCODING ERROR] %s: invalid UTF-8\n", filePath)
+	return true
+}
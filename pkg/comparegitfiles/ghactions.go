@@ -0,0 +1,89 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// inGitHubActions reports whether the run happens inside a GitHub Actions
+// job, where -format defaults to github-actions.
+func inGitHubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// escapeWorkflowData escapes the message of a workflow command.
+func escapeWorkflowData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeWorkflowProperty escapes a property value of a workflow command,
+// which additionally may not contain the separators.
+func escapeWorkflowProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+func annotationMessage(result DiffResult) string {
+	switch {
+	case result.Status == statusMissingLocal:
+		return fmt.Sprintf("missing locally (%s)", result.RemoteSha)
+	case result.Status == statusRenamed:
+		return fmt.Sprintf("renamed locally to %s (%s)", result.RenamedTo, result.RemoteSha)
+	case result.Changes == 0:
+		return fmt.Sprintf("differs from remote (%s)", result.RemoteSha)
+	default:
+		return fmt.Sprintf("%s from remote (%s)", linesDiffer(result.Changes), result.RemoteSha)
+	}
+}
+
+// writeGitHubAnnotations writes one ::warning workflow command per changed
+// file, which GitHub shows inline on the pull request diff.
+func writeGitHubAnnotations(w io.Writer, results []DiffResult) error {
+	for _, result := range results {
+		_, err := fmt.Fprintf(w, "::warning file=%s,line=1::%s\n",
+			escapeWorkflowProperty(result.Path), escapeWorkflowData(annotationMessage(result)))
+		if err != nil {
+			return fmt.Errorf("failed to write annotations: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeStepSummary appends a markdown table of the changed files to the
+// $GITHUB_STEP_SUMMARY file, if the job provides one.
+func writeStepSummary(pkgdef *Config, results []DiffResult) error {
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if path == "" {
+		return nil
+	}
+	ref := resolveRef(pkgdef)
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "## comparegitfiles: `%s@%s`\n\n", pkgdef.Name, ref)
+	if len(results) == 0 {
+		builder.WriteString("All files match the remote.\n\n")
+	} else {
+		builder.WriteString("| File | Added | Removed | Remote sha |\n")
+		builder.WriteString("| --- | ---: | ---: | --- |\n")
+		for _, result := range results {
+			fmt.Fprintf(&builder, "| `%s` | %d | %d | `%s` |\n", result.Path, result.Added, result.Removed, shortSHA(result.RemoteSha))
+		}
+		summary := summarize(results)
+		fmt.Fprintf(&builder, "\n%s changed, %s(+), %s(-).\n\n",
+			plural(summary.Files, "file"), plural(summary.Added, "insertion"), plural(summary.Removed, "deletion"))
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open step summary: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(builder.String()); err != nil {
+		return fmt.Errorf("failed to write step summary: %w", err)
+	}
+	return nil
+}
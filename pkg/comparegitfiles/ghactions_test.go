@@ -0,0 +1,89 @@
+package comparegitfiles
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteGitHubAnnotations(t *testing.T) {
+	tests := []struct {
+		name   string
+		result DiffResult
+		want   string
+	}{
+		{
+			name:   "changed lines",
+			result: DiffResult{Path: "conf/app.yaml", RemoteSha: "abc123", Changes: 3},
+			want:   "::warning file=conf/app.yaml,line=1::3 lines differ from remote (abc123)\n",
+		},
+		{
+			name:   "single line",
+			result: DiffResult{Path: "conf/app.yaml", RemoteSha: "abc123", Changes: 1},
+			want:   "::warning file=conf/app.yaml,line=1::1 line differs from remote (abc123)\n",
+		},
+		{
+			name:   "no line changes",
+			result: DiffResult{Path: "bin/tool", RemoteSha: "abc123"},
+			want:   "::warning file=bin/tool,line=1::differs from remote (abc123)\n",
+		},
+		{
+			name:   "missing locally",
+			result: DiffResult{Path: "conf/new.yaml", RemoteSha: "abc123", Status: statusMissingLocal},
+			want:   "::warning file=conf/new.yaml,line=1::missing locally (abc123)\n",
+		},
+		{
+			name:   "escaped path",
+			result: DiffResult{Path: "conf/a,b:c%d.yaml", RemoteSha: "abc123", Changes: 2},
+			want:   "::warning file=conf/a%2Cb%3Ac%25d.yaml,line=1::2 lines differ from remote (abc123)\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := writeGitHubAnnotations(&out, []DiffResult{tt.result}); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("annotation = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestGitHubActionsFormat(t *testing.T) {
+	gh, dir := newCompareRepo(t, driftRemote, driftLocal, "conf")
+	summary := filepath.Join(t.TempDir(), "summary.md")
+	writeFile(t, summary, "## earlier step\n\n")
+	t.Setenv("GITHUB_ACTIONS", "true")
+	t.Setenv("GITHUB_STEP_SUMMARY", summary)
+
+	stdout, stderr, code := runMain(t, dir, gh.URL, "compare", "-no-state")
+	if code != 0 {
+		t.Fatalf("exit code %d\n%s", code, stderr)
+	}
+	remoteSha := blobSha([]byte(driftRemote["conf/app.yaml"]))
+	want := "::warning file=conf/app.yaml,line=1::2 lines differ from remote (" + remoteSha + ")\n"
+	if stdout != want {
+		t.Errorf("stdout = %q, want %q", stdout, want)
+	}
+
+	data, err := os.ReadFile(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"## earlier step\n\n## comparegitfiles: `owner/repo@HEAD`",
+		"| `conf/app.yaml` | 1 | 1 | `" + shortSHA(remoteSha) + "` |",
+		"1 file changed, 1 insertion(+), 1 deletion(-).",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("step summary does not contain %q:\n%s", want, data)
+		}
+	}
+	if strings.Contains(string(data), "conf/same.yaml") {
+		t.Errorf("step summary lists an identical file:\n%s", data)
+	}
+}
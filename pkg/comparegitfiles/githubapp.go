@@ -0,0 +1,190 @@
+package comparegitfiles
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	appTokenLifetime      = time.Hour
+	appTokenRefreshMargin = 5 * time.Minute
+)
+
+func githubAppConfigured() bool {
+	_, isSet := os.LookupEnv("GITHUB_APP_ID")
+	return isSet
+}
+
+func setupGithubApp() (string, error) {
+	appID, err := strconv.ParseInt(os.Getenv("GITHUB_APP_ID"), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid GITHUB_APP_ID: %w", err)
+	}
+	installationID, err := strconv.ParseInt(os.Getenv("GITHUB_INSTALLATION_ID"), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid GITHUB_INSTALLATION_ID: %w", err)
+	}
+	keyPath, isSet := os.LookupEnv("GITHUB_APP_PRIVATE_KEY_PATH")
+	if !isSet {
+		return "", errors.New("Missing github app private key -> GITHUB_APP_PRIVATE_KEY_PATH")
+	}
+
+	source := &appTokenSource{appID: appID, installationID: installationID, keyPath: keyPath}
+	token, err := source.Token()
+	if err != nil {
+		return "", err
+	}
+	client.Transport = &appAuthTransport{next: baseTransport(), source: source}
+	return token, nil
+}
+
+type appTokenSource struct {
+	appID          int64
+	installationID int64
+	keyPath        string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (s *appTokenSource) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Until(s.expires) > appTokenRefreshMargin {
+		return s.token, nil
+	}
+	token, expires, err := githubAppToken(s.appID, s.installationID, s.keyPath)
+	if err != nil {
+		return "", err
+	}
+	if expires.IsZero() {
+		expires = time.Now().Add(appTokenLifetime)
+	}
+	s.token = token
+	s.expires = expires
+	return s.token, nil
+}
+
+type appAuthTransport struct {
+	next   http.RoundTripper
+	source *appTokenSource
+}
+
+// authorizes reports whether requests to u get the installation token: those
+// to the configured api, except the /app endpoints that use the app jwt.
+func (t *appAuthTransport) authorizes(u *url.URL) bool {
+	api, err := url.Parse(githubAPI)
+	if err != nil || u.Host != api.Host {
+		return false
+	}
+	rest, ok := strings.CutPrefix(u.Path, strings.TrimSuffix(api.Path, "/"))
+	return ok && !strings.HasPrefix(rest, "/app/")
+}
+
+func (t *appAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.authorizes(req.URL) {
+		return t.next.RoundTrip(req)
+	}
+	token, err := t.source.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh github app token: %w", err)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "token "+token)
+	return t.next.RoundTrip(req)
+}
+
+// githubAppToken creates an installation access token and returns it with
+// the expires_at github reports, zero if the response has none.
+func githubAppToken(appID, installationID int64, privateKeyPath string) (string, time.Time, error) {
+	jwt, err := signAppJWT(appID, privateKeyPath)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", githubAPI, installationID)
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", time.Time{}, fmt.Errorf("unexpected status code: %d -> installation %d", resp.StatusCode, installationID)
+	}
+	var body struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return body.Token, body.ExpiresAt, nil
+}
+
+func signAppJWT(appID int64, privateKeyPath string) (string, error) {
+	pemData, err := os.ReadFile(privateKeyPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read private key: %w", err)
+	}
+	key, err := parseRSAPrivateKey(pemData)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": appID,
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign jwt: %w", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func parseRSAPrivateKey(pemData []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("failed to decode private key: no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("failed to parse private key: not an RSA key")
+	}
+	return key, nil
+}
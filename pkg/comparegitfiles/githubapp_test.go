@@ -0,0 +1,102 @@
+package comparegitfiles
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAppAuthTransportAuthorizes(t *testing.T) {
+	tests := []struct {
+		name string
+		api  string
+		url  string
+		want bool
+	}{
+		{name: "github api", api: "https://api.github.com", url: "https://api.github.com/repos/owner/repo/contents/conf", want: true},
+		{name: "app endpoint", api: "https://api.github.com", url: "https://api.github.com/app/installations/1/access_tokens"},
+		{name: "other host", api: "https://api.github.com", url: "https://raw.githubusercontent.com/owner/repo/HEAD/conf"},
+		{name: "enterprise api", api: "https://ghe.example.com/api/v3", url: "https://ghe.example.com/api/v3/repos/owner/repo/contents/conf", want: true},
+		{name: "enterprise app endpoint", api: "https://ghe.example.com/api/v3", url: "https://ghe.example.com/api/v3/app/installations/1/access_tokens"},
+		{name: "enterprise host outside the api", api: "https://ghe.example.com/api/v3", url: "https://ghe.example.com/owner/repo/raw/HEAD/conf"},
+		{name: "github.com with an enterprise api", api: "https://ghe.example.com/api/v3", url: "https://api.github.com/repos/owner/repo/contents/conf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := githubAPI
+			githubAPI = tt.api
+			t.Cleanup(func() { githubAPI = saved })
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := (&appAuthTransport{}).authorizes(u); got != tt.want {
+				t.Errorf("authorizes(%s) = %v, want %v", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppTokenSourceExpiry(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "app.pem")
+	writeFile(t, keyPath, string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})))
+
+	tests := []struct {
+		name      string
+		expiresAt string
+		wantCalls int32
+	}{
+		{name: "valid for an hour", expiresAt: time.Now().Add(time.Hour).UTC().Format(time.RFC3339), wantCalls: 1},
+		{name: "expires within the refresh margin", expiresAt: time.Now().Add(time.Minute).UTC().Format(time.RFC3339), wantCalls: 2},
+		{name: "no expires_at", wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/api/v3/app/installations/7/access_tokens" {
+					http.NotFound(w, r)
+					return
+				}
+				calls.Add(1)
+				body := map[string]string{"token": "installation-token"}
+				if tt.expiresAt != "" {
+					body["expires_at"] = tt.expiresAt
+				}
+				w.WriteHeader(http.StatusCreated)
+				json.NewEncoder(w).Encode(body)
+			}))
+			t.Cleanup(srv.Close)
+			saved := githubAPI
+			githubAPI = srv.URL + "/api/v3"
+			t.Cleanup(func() { githubAPI = saved })
+
+			source := &appTokenSource{appID: 1, installationID: 7, keyPath: keyPath}
+			for i := 0; i < 2; i++ {
+				token, err := source.Token()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if token != "installation-token" {
+					t.Errorf("token = %q, want installation-token", token)
+				}
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("requested %d tokens, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
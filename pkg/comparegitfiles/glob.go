@@ -0,0 +1,103 @@
+package comparegitfiles
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// remoteTree caches the full listing of the repository so that every glob
+// in Files is matched against a single fetch.
+var remoteTree struct {
+	once     sync.Once
+	contents []GithubContent
+	err      error
+}
+
+func isGlobPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[{")
+}
+
+func listRemoteTree(ctx context.Context, opts *Options, pkgdef *Config) ([]GithubContent, error) {
+	remoteTree.once.Do(func() {
+		if providerName(pkgdef) == "github" {
+			var treeSHA string
+			treeSHA, remoteTree.err = lookupTreeSHA(ctx, "", opts, pkgdef)
+			if remoteTree.err == nil {
+				remoteTree.contents, remoteTree.err = listTreeRecursive(ctx, treeSHA, "", opts, pkgdef)
+			}
+			return
+		}
+		remoteTree.contents, remoteTree.err = listContentsRecursive(ctx, "", opts, pkgdef)
+	})
+	return remoteTree.contents, remoteTree.err
+}
+
+func listContentsRecursive(ctx context.Context, dir string, opts *Options, pkgdef *Config) ([]GithubContent, error) {
+	contents, err := listContents(ctx, dir, opts, pkgdef)
+	if err != nil {
+		return nil, err
+	}
+	all := contents
+	for _, content := range contents {
+		if content.Type != "dir" {
+			continue
+		}
+		children, err := listContentsRecursive(ctx, content.Path, opts, pkgdef)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, children...)
+	}
+	return all, nil
+}
+
+// fetchGlob fetches every remote file matching pattern. `**` matches any
+// number of directories.
+func fetchGlob(ctx context.Context, pattern, baseDir string, opts *Options, pkgdef *Config) error {
+	contents, err := listRemoteTree(ctx, opts, pkgdef)
+	if err != nil {
+		return fmt.Errorf("failed to list repository for %s: %w", pattern, err)
+	}
+	pattern = strings.Trim(pattern, "/")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(contents))
+	matched := 0
+	for _, content := range contents {
+		if content.Type != "file" || checkIgnore(content.Path) {
+			continue
+		}
+		if ok, _ := doublestar.Match(pattern, content.Path); !ok {
+			continue
+		}
+		matched++
+		fileGraph.addChildren(path.Dir(content.Path), []GithubContent{content})
+
+		wg.Add(1)
+		go func(content GithubContent) {
+			defer wg.Done()
+			if err := fetchFile(ctx, content, baseDir, opts, pkgdef); err != nil {
+				errs <- err
+			}
+		}(content)
+	}
+	wg.Wait()
+	close(errs)
+
+	if err, ok := <-errs; ok {
+		return err
+	}
+	if matched == 0 {
+		if opts.StrictGlobs {
+			return fmt.Errorf("pattern %s matched no files", pattern)
+		}
+		slog.Warn("pattern matched no files", "pattern", pattern)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package comparegitfiles
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const goEmbedFileName = "synced_files.go"
+
+var syncedFiles = &syncedFileSet{}
+
+type syncedFile struct {
+	Path string
+	URL  string
+}
+
+// syncedFileSet collects every file a fetch or sync run wrote or found up to
+// date, with the url it was downloaded from.
+type syncedFileSet struct {
+	mu    sync.Mutex
+	files []syncedFile
+}
+
+func (s *syncedFileSet) add(path, url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files = append(s.files, syncedFile{path, url})
+}
+
+func (s *syncedFileSet) sorted() []syncedFile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := make([]syncedFile, len(s.files))
+	copy(files, s.files)
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+func (s *syncedFileSet) paths() []string {
+	var paths []string
+	for _, file := range s.sorted() {
+		paths = append(paths, file.Path)
+	}
+	return paths
+}
+
+// writeGoEmbed writes synced_files.go into packageDir with one //go:embed
+// directive per synced file. go:embed only accepts paths below the package
+// directory, so every file must live there.
+func writeGoEmbed(packageDir string, files []string) error {
+	absDir, err := filepath.Abs(packageDir)
+	if err != nil {
+		return err
+	}
+	var patterns []string
+	for _, file := range files {
+		absFile, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(absDir, absFile)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("cannot embed %s: outside of package directory %s", file, packageDir)
+		}
+		patterns = append(patterns, embedPattern(filepath.ToSlash(rel)))
+	}
+	if len(patterns) == 0 {
+		return fmt.Errorf("no synced files to embed")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "// Code generated by comparegitfiles -write-go-embed. DO NOT EDIT.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintf(&buf, "package %s\n\n", goPackageName(absDir))
+	fmt.Fprintln(&buf, `import "embed"`)
+	fmt.Fprintln(&buf)
+	for _, pattern := range patterns {
+		fmt.Fprintf(&buf, "//go:embed %s\n", pattern)
+	}
+	fmt.Fprintln(&buf, "var SyncedFS embed.FS")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to format %s: %w", goEmbedFileName, err)
+	}
+	output := filepath.Join(packageDir, goEmbedFileName)
+	if err := os.WriteFile(output, src, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", goEmbedFileName, err)
+	}
+	slog.Info("go embed file written", "path", output)
+	return nil
+}
+
+// embedPattern quotes paths that go:embed would otherwise split or treat as
+// a glob.
+func embedPattern(path string) string {
+	if strings.ContainsAny(path, " \t\"`*?[\\") {
+		return strconv.Quote(path)
+	}
+	return path
+}
+
+// goPackageName reuses the package clause of an existing Go file in dir and
+// otherwise derives the name from the directory.
+func goPackageName(dir string) string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, match := range matches {
+		if filepath.Base(match) == goEmbedFileName || strings.HasSuffix(match, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), match, nil, parser.PackageClauseOnly)
+		if err == nil {
+			return file.Name.Name
+		}
+	}
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, filepath.Base(dir))
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "synced" + name
+	}
+	return name
+}
@@ -0,0 +1,90 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+func isGoTemplateFile(path string) bool {
+	switch filepath.Ext(path) {
+	case ".tmpl", ".gotmpl", ".tpl":
+		return true
+	}
+	return false
+}
+
+// canonicalizeGoTemplate parses src with text/template and prints the parse
+// trees one node per line. Comments are dropped, actions are printed the way
+// the parser formats them and whitespace in text is collapsed, so only
+// changes to the text or the template logic remain. Functions are not
+// checked since the FuncMap of the program using the template is unknown.
+func canonicalizeGoTemplate(src, filename string) (string, error) {
+	name := filepath.Base(filename)
+	trees := make(map[string]*parse.Tree)
+	tree := parse.New(name)
+	tree.Mode = parse.SkipFuncCheck
+	if _, err := tree.Parse(src, "", "", trees); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+
+	names := make([]string, 0, len(trees))
+	for defined := range trees {
+		if defined != name {
+			names = append(names, defined)
+		}
+	}
+	sort.Strings(names)
+
+	var builder strings.Builder
+	if root, ok := trees[name]; ok {
+		writeTemplateList(&builder, root.Root, 0)
+	}
+	for _, defined := range names {
+		fmt.Fprintf(&builder, "{{define %q}}\n", defined)
+		writeTemplateList(&builder, trees[defined].Root, 1)
+		builder.WriteString("{{end}}\n")
+	}
+	return builder.String(), nil
+}
+
+func writeTemplateList(builder *strings.Builder, list *parse.ListNode, depth int) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		writeTemplateNode(builder, node, depth)
+	}
+}
+
+func writeTemplateNode(builder *strings.Builder, node parse.Node, depth int) {
+	indent := strings.Repeat("  ", depth)
+	switch n := node.(type) {
+	case *parse.TextNode:
+		if text := strings.Join(strings.Fields(string(n.Text)), " "); text != "" {
+			builder.WriteString(indent + text + "\n")
+		}
+	case *parse.IfNode:
+		writeTemplateBranch(builder, "if", &n.BranchNode, depth)
+	case *parse.RangeNode:
+		writeTemplateBranch(builder, "range", &n.BranchNode, depth)
+	case *parse.WithNode:
+		writeTemplateBranch(builder, "with", &n.BranchNode, depth)
+	case *parse.CommentNode:
+	default:
+		builder.WriteString(indent + node.String() + "\n")
+	}
+}
+
+func writeTemplateBranch(builder *strings.Builder, keyword string, branch *parse.BranchNode, depth int) {
+	indent := strings.Repeat("  ", depth)
+	fmt.Fprintf(builder, "%s{{%s %s}}\n", indent, keyword, branch.Pipe)
+	writeTemplateList(builder, branch.List, depth+1)
+	if branch.ElseList != nil {
+		builder.WriteString(indent + "{{else}}\n")
+		writeTemplateList(builder, branch.ElseList, depth+1)
+	}
+	builder.WriteString(indent + "{{end}}\n")
+}
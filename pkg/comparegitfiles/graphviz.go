@@ -0,0 +1,88 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var fileGraph = &dotGraph{
+	nodes: make(map[string]string),
+	edges: make(map[[2]string]bool),
+}
+
+type dotGraph struct {
+	mu    sync.Mutex
+	nodes map[string]string
+	edges map[[2]string]bool
+}
+
+func (g *dotGraph) addChildren(parent string, contents []GithubContent) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	parent = filepath.Clean(parent)
+	for _, content := range contents {
+		child := filepath.Clean(content.Path)
+		if _, ok := g.nodes[child]; !ok {
+			g.nodes[child] = content.Type
+		}
+		if child == parent {
+			continue
+		}
+		if _, ok := g.nodes[parent]; !ok {
+			g.nodes[parent] = "dir"
+		}
+		g.edges[[2]string{parent, child}] = true
+	}
+}
+
+func (g *dotGraph) setStatus(path, status string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.nodes[filepath.Clean(path)] = status
+}
+
+func (g *dotGraph) write(path string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	names := make([]string, 0, len(g.nodes))
+	for name := range g.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	edges := make([]string, 0, len(g.edges))
+	for edge := range g.edges {
+		edges = append(edges, fmt.Sprintf("  %q -> %q;\n", edge[0], edge[1]))
+	}
+	sort.Strings(edges)
+
+	var builder strings.Builder
+	builder.WriteString("digraph comparegitfiles {\n")
+	builder.WriteString("  rankdir=LR;\n")
+	builder.WriteString("  node [shape=box, style=filled, fillcolor=white];\n")
+	for _, name := range names {
+		switch g.nodes[name] {
+		case "dir":
+			fmt.Fprintf(&builder, "  %q [shape=folder, fillcolor=lightgrey];\n", name)
+		case "changed":
+			fmt.Fprintf(&builder, "  %q [fillcolor=red];\n", name)
+		case "equal":
+			fmt.Fprintf(&builder, "  %q [fillcolor=green];\n", name)
+		default:
+			fmt.Fprintf(&builder, "  %q;\n", name)
+		}
+	}
+	for _, edge := range edges {
+		builder.WriteString(edge)
+	}
+	builder.WriteString("}\n")
+
+	if err := os.WriteFile(path, []byte(builder.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write graphviz output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package comparegitfiles
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// hookMarker identifies hooks written by hooks install, so that uninstall
+// and a repeated install never touch a hook written by someone else.
+const hookMarker = "Generated by comparegitfiles hooks install."
+
+//go:embed pre-commit.tmpl
+var preCommitHookSource string
+
+type preCommitHook struct {
+	Marker    string
+	Command   string
+	ConfigArg string
+}
+
+// renderPreCommitHook fills the pre-commit template, either the bundled one
+// or templatePath when it is set.
+func renderPreCommitHook(templatePath, config string) ([]byte, error) {
+	source := preCommitHookSource
+	if templatePath != "" {
+		data, err := os.ReadFile(templatePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read hook template: %w", err)
+		}
+		source = string(data)
+	}
+	tmpl, err := template.New("pre-commit").Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse hook template: %w", err)
+	}
+	hook := preCommitHook{Marker: hookMarker}
+	if config != "" {
+		hook.ConfigArg = " -config " + shellQuote(config)
+	}
+	// every commit compares from scratch instead of trusting the state file
+	hook.Command = "comparegitfiles" + hook.ConfigArg + " compare -no-state -fail-if-files-changed-gt 0"
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, hook); err != nil {
+		return nil, fmt.Errorf("failed to render hook template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// hooksDir returns hookPath when set and otherwise asks git, which honours
+// core.hooksPath and worktrees.
+func hooksDir(hookPath string) (string, error) {
+	if hookPath != "" {
+		return hookPath, nil
+	}
+	output, err := exec.Command("git", "rev-parse", "--git-path", "hooks").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the git hooks directory, use -hook-path: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// ownedHook reports whether the hook at path was written by hooks install.
+func ownedHook(path string) (exists, owned bool, err error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	return true, bytes.Contains(data, []byte(hookMarker)), nil
+}
+
+func installHook(hookPath, templatePath, config string) error {
+	dir, err := hooksDir(hookPath)
+	if err != nil {
+		return err
+	}
+	script, err := renderPreCommitHook(templatePath, config)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, "pre-commit")
+	exists, owned, err := ownedHook(path)
+	if err != nil {
+		return err
+	}
+	if exists && !owned {
+		return fmt.Errorf("%s already exists and was not installed by comparegitfiles, remove it first", path)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+	if err := os.WriteFile(path, script, 0755); err != nil {
+		return fmt.Errorf("failed to write hook: %w", err)
+	}
+	// WriteFile keeps the mode of an existing file.
+	if err := os.Chmod(path, 0755); err != nil {
+		return err
+	}
+	fmt.Printf("Pre-commit hook written to %s\n", path)
+	return nil
+}
+
+func uninstallHook(hookPath string) error {
+	dir, err := hooksDir(hookPath)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, "pre-commit")
+	exists, owned, err := ownedHook(path)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		fmt.Printf("No pre-commit hook at %s\n", path)
+		return nil
+	}
+	if !owned {
+		return fmt.Errorf("%s was not installed by comparegitfiles, leaving it in place", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove hook: %w", err)
+	}
+	fmt.Printf("Pre-commit hook removed from %s\n", path)
+	return nil
+}
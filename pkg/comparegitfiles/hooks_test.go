@@ -0,0 +1,144 @@
+package comparegitfiles
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderPreCommitHook(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		template string
+		want     []string
+	}{
+		{
+			name: "bundled template",
+			want: []string{
+				"#!/bin/sh\n",
+				hookMarker,
+				"output=$(comparegitfiles compare -no-state -fail-if-files-changed-gt 0 2>&1)",
+				"commit blocked",
+				"Run \\`comparegitfiles sync\\` to update them",
+			},
+		},
+		{
+			name:   "config with spaces and quotes",
+			config: "conf/it's here.json",
+			want: []string{
+				`comparegitfiles -config 'conf/it'\''s here.json' compare -no-state -fail-if-files-changed-gt 0`,
+				`comparegitfiles sync -config 'conf/it'\''s here.json'`,
+			},
+		},
+		{
+			name:     "custom template",
+			template: "#!/bin/sh\n# {{.Marker}}\n{{.Command}} || exit 1\n",
+			want:     []string{"#!/bin/sh\n# " + hookMarker + "\ncomparegitfiles compare -no-state -fail-if-files-changed-gt 0 || exit 1\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			templatePath := ""
+			if tt.template != "" {
+				templatePath = filepath.Join(t.TempDir(), "pre-commit.tmpl")
+				writeFile(t, templatePath, tt.template)
+			}
+			script, err := renderPreCommitHook(templatePath, tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(script), want) {
+					t.Errorf("hook does not contain %q:\n%s", want, script)
+				}
+			}
+		})
+	}
+}
+
+func TestInstallHookKeepsForeignHooks(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pre-commit")
+	writeFile(t, path, "#!/bin/sh\nmake lint\n")
+
+	if err := installHook(dir, "", ""); err == nil {
+		t.Error("installHook replaced a hook it did not install")
+	}
+	if err := uninstallHook(dir); err == nil {
+		t.Error("uninstallHook removed a hook it did not install")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "#!/bin/sh\nmake lint\n" {
+		t.Errorf("foreign hook changed to %q", data)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := installHook(dir, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := installHook(dir, "", ""); err != nil {
+		t.Errorf("installHook over its own hook: %v", err)
+	}
+	if err := uninstallHook(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("hook still present after uninstall: %v", err)
+	}
+}
+
+func TestPreCommitHookBlocksEveryCommit(t *testing.T) {
+	remote := map[string]string{"conf/app.yaml": "a: 1\nb: 2\n"}
+	gh, dir := newCompareRepo(t, remote, map[string]string{"conf/app.yaml": "a: 1\nb: 3\n"}, "conf")
+	if err := installHook("", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// the hook runs comparegitfiles from PATH, here the test binary
+	bin := t.TempDir()
+	writeFile(t, filepath.Join(bin, "comparegitfiles"), "#!/bin/sh\nexec "+shellQuote(os.Args[0])+` "$@"`+"\n")
+	if err := os.Chmod(filepath.Join(bin, "comparegitfiles"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	runHook := func() (string, int) {
+		cmd := exec.Command(filepath.Join(dir, ".git", "hooks", "pre-commit"))
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"),
+			"COMPAREGITFILES_TEST_API="+gh.URL,
+			"GITHUB_TOKEN=secret")
+		var out bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &out, &out
+		var exitErr *exec.ExitError
+		if err := cmd.Run(); err != nil && !errors.As(err, &exitErr) {
+			t.Fatal(err)
+		}
+		return out.String(), cmd.ProcessState.ExitCode()
+	}
+
+	for commit := 1; commit <= 2; commit++ {
+		out, code := runHook()
+		if code != 1 {
+			t.Fatalf("commit %d: hook exit code = %d, want 1\n%s", commit, code, out)
+		}
+		for _, want := range []string{"commit blocked", "conf/app.yaml"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("commit %d: hook output does not contain %q:\n%s", commit, want, out)
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, defaultStateFile)); !os.IsNotExist(err) {
+		t.Errorf("hook wrote the state file: %v", err)
+	}
+
+	writeFile(t, filepath.Join(dir, "conf", "app.yaml"), remote["conf/app.yaml"])
+	if out, code := runHook(); code != 0 {
+		t.Errorf("hook exit code = %d after syncing, want 0\n%s", code, out)
+	}
+}
@@ -0,0 +1,71 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+)
+
+const diffFragmentStyles = `<style>
+.comparegitfiles-diff { border-collapse: collapse; font-family: monospace; margin-bottom: 1em; width: 100%; }
+.comparegitfiles-diff caption { font-weight: bold; text-align: left; padding: 4px 0; }
+.comparegitfiles-diff td { padding: 0 6px; white-space: pre-wrap; }
+.comparegitfiles-diff .del { background: #ffebe9; }
+.comparegitfiles-diff .add { background: #e6ffec; }
+</style>
+`
+
+type diffFragmentLine struct {
+	Class string
+	Sign  string
+	Text  string
+}
+
+var diffFragmentTemplate = template.Must(template.New("fragment").Parse(`{{range .}}<table class="comparegitfiles-diff">
+<caption><code>{{.Path}}</code> ({{.Changes}} changed lines)</caption>
+{{range .Lines}}<tr class="{{.Class}}"><td>{{.Sign}}</td><td>{{.Text}}</td></tr>
+{{else}}<tr><td></td><td>diff not available</td></tr>
+{{end}}</table>
+{{end}}`))
+
+func diffFragmentLines(diff string) []diffFragmentLine {
+	var lines []diffFragmentLine
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "-"):
+			lines = append(lines, diffFragmentLine{"del", "-", line[1:]})
+		case strings.HasPrefix(line, "+"):
+			lines = append(lines, diffFragmentLine{"add", "+", line[1:]})
+		case line != "":
+			lines = append(lines, diffFragmentLine{"", "", line})
+		}
+	}
+	return lines
+}
+
+// writeDiffHTMLFragment writes one <table> per changed file and nothing
+// else, for embedding into pages that provide their own document.
+func writeDiffHTMLFragment(path string, results []DiffResult, includeStyles bool) error {
+	type fileDiff struct {
+		Path    string
+		Changes int
+		Lines   []diffFragmentLine
+	}
+	files := make([]fileDiff, 0, len(results))
+	for _, result := range results {
+		files = append(files, fileDiff{result.Path, result.Changes, diffFragmentLines(result.Diff)})
+	}
+
+	var builder strings.Builder
+	if includeStyles {
+		builder.WriteString(diffFragmentStyles)
+	}
+	if err := diffFragmentTemplate.Execute(&builder, files); err != nil {
+		return fmt.Errorf("failed to render html fragment: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(builder.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write html fragment: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package comparegitfiles
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+//go:embed report.html
+var htmlDiffReportSource string
+
+var htmlDiffReportTemplate = template.Must(template.New("report.html").Parse(htmlDiffReportSource))
+
+type highlightToken struct {
+	Class string
+	Text  string
+}
+
+type reportLine struct {
+	Class  string
+	Sign   string
+	Tokens []highlightToken
+}
+
+// highlighter tokenizes a single line with one alternation; the n-th
+// capture group gets the n-th class.
+type highlighter struct {
+	re      *regexp.Regexp
+	classes []string
+}
+
+var highlighters = map[string]highlighter{
+	"go": {
+		re:      regexp.MustCompile(`(//.*)|("(?:[^"\\]|\\.)*"|` + "`[^`]*`" + `|'(?:[^'\\]|\\.)*')|\b(break|case|chan|const|continue|default|defer|else|fallthrough|for|func|go|goto|if|import|interface|map|package|range|return|select|struct|switch|type|var|nil|true|false)\b|\b(\d+(?:\.\d+)?)\b`),
+		classes: []string{"com", "str", "kw", "num"},
+	},
+	"python": {
+		re:      regexp.MustCompile(`(#.*)|("(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*')|\b(and|as|assert|async|await|break|class|continue|def|del|elif|else|except|finally|for|from|global|if|import|in|is|lambda|nonlocal|not|or|pass|raise|return|try|while|with|yield|None|True|False)\b|\b(\d+(?:\.\d+)?)\b`),
+		classes: []string{"com", "str", "kw", "num"},
+	},
+	"yaml": {
+		re:      regexp.MustCompile(`(#.*)|^(\s*-?\s*[\w./-]+)\s*:|("(?:[^"\\]|\\.)*"|'[^']*')|\b(true|false|null|yes|no)\b|\b(\d+(?:\.\d+)?)\b`),
+		classes: []string{"com", "key", "str", "kw", "num"},
+	},
+	"json": {
+		re:      regexp.MustCompile(`("(?:[^"\\]|\\.)*")\s*:|("(?:[^"\\]|\\.)*")|\b(true|false|null)\b|(-?\d+(?:\.\d+)?(?:[eE][+-]?\d+)?)`),
+		classes: []string{"key", "str", "kw", "num"},
+	},
+}
+
+func highlighterFor(path string) (highlighter, bool) {
+	var language string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".go":
+		language = "go"
+	case ".py":
+		language = "python"
+	case ".yaml", ".yml":
+		language = "yaml"
+	case ".json":
+		language = "json"
+	}
+	h, ok := highlighters[language]
+	return h, ok
+}
+
+func (h highlighter) tokens(line string) []highlightToken {
+	var tokens []highlightToken
+	last := 0
+	for _, match := range h.re.FindAllStringSubmatchIndex(line, -1) {
+		for group, class := range h.classes {
+			start, end := match[2+2*group], match[3+2*group]
+			if start < 0 {
+				continue
+			}
+			if start > last {
+				tokens = append(tokens, highlightToken{Text: line[last:start]})
+			}
+			tokens = append(tokens, highlightToken{Class: class, Text: line[start:end]})
+			last = end
+			break
+		}
+	}
+	if last < len(line) {
+		tokens = append(tokens, highlightToken{Text: line[last:]})
+	}
+	return tokens
+}
+
+func reportLines(path, diff string) []reportLine {
+	h, highlight := highlighterFor(path)
+	var lines []reportLine
+	for _, line := range diffFragmentLines(diff) {
+		tokens := []highlightToken{{Text: line.Text}}
+		if highlight {
+			tokens = h.tokens(line.Text)
+		}
+		lines = append(lines, reportLine{Class: line.Class, Sign: line.Sign, Tokens: tokens})
+	}
+	return lines
+}
+
+// writeHTMLResults renders a self-contained page for -format html, with all
+// styles inlined so that it can be opened offline or attached to an email.
+func writeHTMLResults(w io.Writer, pkgdef *Config, results []DiffResult) error {
+	type fileDiff struct {
+		Path    string
+		Changes int
+		Lines   []reportLine
+	}
+	ref := resolveRef(pkgdef)
+	if ref == "" {
+		ref = "HEAD"
+	}
+	data := struct {
+		Name           string
+		Ref            string
+		Added, Removed int
+		Files          []fileDiff
+	}{Name: pkgdef.Name, Ref: ref}
+	for _, result := range results {
+		data.Added += result.Added
+		data.Removed += result.Removed
+		data.Files = append(data.Files, fileDiff{result.Path, result.Changes, reportLines(result.Path, result.Diff)})
+	}
+	if err := htmlDiffReportTemplate.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to write html report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package comparegitfiles
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+type HTTPClientConfig struct {
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	IdleConnTimeout       time.Duration
+	ResponseHeaderTimeout time.Duration
+	DialTimeout           time.Duration
+	Proxy                 func(*http.Request) (*url.URL, error)
+	TLSConfig             *tls.Config
+}
+
+func defaultHTTPClientConfig() HTTPClientConfig {
+	return HTTPClientConfig{
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   maxParallel * 2,
+		IdleConnTimeout:       90 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		DialTimeout:           30 * time.Second,
+		Proxy:                 http.ProxyFromEnvironment,
+	}
+}
+
+func NewHTTPClient(cfg HTTPClientConfig) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 cfg.Proxy,
+			TLSClientConfig:       cfg.TLSConfig,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          cfg.MaxIdleConns,
+			MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+			IdleConnTimeout:       cfg.IdleConnTimeout,
+			ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+}
+
+// proxyFunc selects the proxy like http.ProxyFromEnvironment, except that a
+// non-empty proxy replaces HTTP_PROXY and HTTPS_PROXY and the hosts in
+// noProxy are bypassed in addition to those in NO_PROXY.
+func proxyFunc(proxy, noProxy string) (func(*http.Request) (*url.URL, error), error) {
+	if proxy == "" && noProxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	cfg := httpproxy.FromEnvironment()
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if !strings.Contains(proxy, "://") {
+			// host:port like curl accepts it
+			u, err = url.Parse("http://" + proxy)
+		}
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy url: %s", proxy)
+		}
+		cfg.HTTPProxy, cfg.HTTPSProxy = u.String(), u.String()
+	}
+	if noProxy != "" {
+		if cfg.NoProxy != "" {
+			noProxy = cfg.NoProxy + "," + noProxy
+		}
+		cfg.NoProxy = noProxy
+	}
+	selectProxy := cfg.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return selectProxy(req.URL)
+	}, nil
+}
+
+// redirectPolicy reports redirects, which the api answers with when a
+// repository was renamed or transferred. With fail set the redirect is not
+// followed and the request fails instead.
+func redirectPolicy(config string, fail bool) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		from, to := *via[len(via)-1].URL, *req.URL
+		from.RawQuery, to.RawQuery = "", ""
+		msg := fmt.Sprintf("[REDIRECT] %s -> %s. Update your %s.", &from, &to, filepath.Base(config))
+		if fail {
+			return errors.New(msg)
+		}
+		slog.Warn(msg)
+		return nil
+	}
+}
+
+func httpClientConfigFromEnv() (HTTPClientConfig, error) {
+	cfg := defaultHTTPClientConfig()
+	ints := map[string]*int{
+		"COMPAREGITFILES_HTTP_MAX_IDLE_CONNS":          &cfg.MaxIdleConns,
+		"COMPAREGITFILES_HTTP_MAX_IDLE_CONNS_PER_HOST": &cfg.MaxIdleConnsPerHost,
+	}
+	for env, field := range ints {
+		value, isSet := os.LookupEnv(env)
+		if !isSet {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid %s: %w", env, err)
+		}
+		*field = parsed
+	}
+	durations := map[string]*time.Duration{
+		"COMPAREGITFILES_HTTP_IDLE_CONN_TIMEOUT":       &cfg.IdleConnTimeout,
+		"COMPAREGITFILES_HTTP_RESPONSE_HEADER_TIMEOUT": &cfg.ResponseHeaderTimeout,
+		"COMPAREGITFILES_HTTP_DIAL_TIMEOUT":            &cfg.DialTimeout,
+	}
+	for env, field := range durations {
+		value, isSet := os.LookupEnv(env)
+		if !isSet {
+			continue
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid %s: %w", env, err)
+		}
+		*field = parsed
+	}
+	return cfg, nil
+}
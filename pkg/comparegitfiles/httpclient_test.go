@@ -0,0 +1,166 @@
+package comparegitfiles
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeProxy is a forward proxy that passes every request on to target and
+// records the hosts it was asked for.
+type fakeProxy struct {
+	*httptest.Server
+	mu    sync.Mutex
+	hosts []string
+}
+
+func newFakeProxy(t *testing.T, target string) *fakeProxy {
+	t.Helper()
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forward := httputil.NewSingleHostReverseProxy(targetURL)
+	p := &fakeProxy{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.hosts = append(p.hosts, r.URL.Host)
+		p.mu.Unlock()
+		forward.ServeHTTP(w, r)
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *fakeProxy) requestedHosts() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.hosts
+}
+
+func TestProxyFunc(t *testing.T) {
+	tests := []struct {
+		name           string
+		env            map[string]string
+		proxy, noProxy string
+		url            string
+		want           string
+		wantErr        bool
+	}{
+		{name: "flag", proxy: "http://proxy.example.com:3128", url: "https://api.github.com/repos", want: "http://proxy.example.com:3128"},
+		{name: "host and port", proxy: "proxy.example.com:3128", url: "https://api.github.com/repos", want: "http://proxy.example.com:3128"},
+		{
+			name:  "flag overrides environment",
+			env:   map[string]string{"HTTPS_PROXY": "http://env.example.com:8080", "HTTP_PROXY": "http://env.example.com:8080"},
+			proxy: "http://proxy.example.com:3128", url: "https://api.github.com/repos", want: "http://proxy.example.com:3128",
+		},
+		{name: "no-proxy host", proxy: "http://proxy.example.com:3128", noProxy: "api.github.com", url: "https://api.github.com/repos"},
+		{name: "no-proxy wildcard", proxy: "http://proxy.example.com:3128", noProxy: "*.corp.example.com", url: "https://git.corp.example.com/api", want: ""},
+		{name: "no-proxy wildcard other host", proxy: "http://proxy.example.com:3128", noProxy: "*.corp.example.com", url: "https://api.github.com/repos", want: "http://proxy.example.com:3128"},
+		{name: "no-proxy list", proxy: "http://proxy.example.com:3128", noProxy: "gitlab.example.com, api.github.com", url: "https://api.github.com/repos"},
+		{
+			name:    "no-proxy adds to NO_PROXY",
+			env:     map[string]string{"HTTPS_PROXY": "http://env.example.com:8080", "NO_PROXY": "gitlab.example.com"},
+			noProxy: "bitbucket.example.com",
+			url:     "https://gitlab.example.com/api",
+		},
+		{
+			name:    "environment proxy with no-proxy",
+			env:     map[string]string{"HTTPS_PROXY": "http://env.example.com:8080", "NO_PROXY": "gitlab.example.com"},
+			noProxy: "bitbucket.example.com",
+			url:     "https://api.github.com/repos",
+			want:    "http://env.example.com:8080",
+		},
+		{name: "invalid proxy", proxy: "http://", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, env := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy", "REQUEST_METHOD"} {
+				t.Setenv(env, tt.env[env])
+			}
+			selectProxy, err := proxyFunc(tt.proxy, tt.noProxy)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("proxyFunc succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			proxy, err := selectProxy(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			if proxy != nil {
+				got = proxy.String()
+			}
+			if got != tt.want {
+				t.Errorf("proxy for %s = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestsThroughProxy(t *testing.T) {
+	// requests to loopback addresses are never proxied, so the api is
+	// reached at a name only the proxy can resolve
+	const api = "http://api.github.test"
+	tests := []struct {
+		name    string
+		env     map[string]string
+		args    []string
+		proxied bool
+	}{
+		{name: "proxy flag", args: []string{"-proxy", "{proxy}"}, proxied: true},
+		{name: "HTTP_PROXY", env: map[string]string{"HTTP_PROXY": "{proxy}"}, proxied: true},
+		{name: "proxy flag overrides HTTP_PROXY", env: map[string]string{"HTTP_PROXY": "http://127.0.0.1:1"}, args: []string{"-proxy", "{proxy}"}, proxied: true},
+		{name: "no-proxy", args: []string{"-proxy", "{proxy}", "-no-proxy", "*.github.test"}},
+		{name: "NO_PROXY", env: map[string]string{"HTTP_PROXY": "{proxy}", "NO_PROXY": "api.github.test"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gh, dir := newCompareRepo(t, driftRemote, driftLocal, "conf")
+			proxy := newFakeProxy(t, gh.URL)
+			for _, env := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy"} {
+				t.Setenv(env, strings.ReplaceAll(tt.env[env], "{proxy}", proxy.URL))
+			}
+			args := []string{"compare", "-no-state"}
+			for _, arg := range tt.args {
+				args = append(args, strings.ReplaceAll(arg, "{proxy}", proxy.URL))
+			}
+
+			stdout, stderr, code := runMain(t, dir, api, args...)
+			hosts := proxy.requestedHosts()
+			if !tt.proxied {
+				if len(hosts) != 0 {
+					t.Errorf("proxy received requests for %v, want none", hosts)
+				}
+				return
+			}
+			if code != 0 {
+				t.Fatalf("exit code %d\n%s", code, stderr)
+			}
+			if len(hosts) == 0 {
+				t.Fatal("no request went through the proxy")
+			}
+			for _, host := range hosts {
+				if host != "api.github.test" {
+					t.Errorf("proxy received a request for %s, want api.github.test", host)
+				}
+			}
+			if !strings.Contains(stdout, "conf/app.yaml | 2 +-") {
+				t.Errorf("stdout does not contain the diff stat:\n%s", stdout)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package comparegitfiles
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"sync"
+	"time"
+)
+
+// debugBodyLimit truncates the bodies logged with -http-dump-bodies, blob
+// responses would flood the log otherwise.
+const debugBodyLimit = 1024
+
+var redactedHeaders = []string{"Authorization", "Private-Token"}
+
+// dumpTransport writes every request and response to w with token masked,
+// also in credential headers that do not contain it verbatim and wherever
+// the response echoes such a header back.
+type dumpTransport struct {
+	next  http.RoundTripper
+	mu    sync.Mutex
+	w     io.Writer
+	token string
+}
+
+func (t *dumpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	redacted := req.Clone(req.Context())
+	var credentials []string
+	for _, header := range redactedHeaders {
+		value := redacted.Header.Get(header)
+		if value == "" {
+			continue
+		}
+		masked := sanitize(value, t.token)
+		if masked == value {
+			// e.g. basic auth, where the token is base64 encoded
+			masked = "***"
+			credentials = append(credentials, value)
+		}
+		redacted.Header.Set(header, masked)
+	}
+	mask := func(s string) string {
+		for _, credential := range credentials {
+			s = strings.ReplaceAll(s, credential, "***")
+		}
+		return sanitize(s, t.token)
+	}
+	withBody := req.Body == nil || req.GetBody != nil
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		redacted.Body = body
+	}
+	reqDump, err := httputil.DumpRequestOut(redacted, withBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump request: %w", err)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.w, "=== %s %s %s\n", time.Now().Format(time.RFC3339), req.Method, mask(req.URL.String()))
+	io.WriteString(t.w, mask(string(reqDump)))
+	fmt.Fprintln(t.w)
+	if err != nil {
+		fmt.Fprintf(t.w, "--- error: %s\n\n", mask(err.Error()))
+		return nil, err
+	}
+	respDump, dumpErr := httputil.DumpResponse(resp, true)
+	if dumpErr != nil {
+		fmt.Fprintf(t.w, "--- failed to dump response: %v\n\n", dumpErr)
+		return resp, nil
+	}
+	io.WriteString(t.w, mask(string(respDump)))
+	fmt.Fprint(t.w, "\n\n")
+	return resp, nil
+}
+
+// debugTransport logs method, url, status and latency of every request at
+// debug level, and with bodies set the first debugBodyLimit bytes of the
+// request and response bodies. The token is masked by the log handler.
+type debugTransport struct {
+	next   http.RoundTripper
+	bodies bool
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !slog.Default().Enabled(req.Context(), slog.LevelDebug) {
+		return t.next.RoundTrip(req)
+	}
+	attrs := []slog.Attr{slog.String("method", req.Method), slog.String("url", req.URL.String())}
+	if t.bodies && req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			prefix, _ := io.ReadAll(io.LimitReader(body, debugBodyLimit+1))
+			body.Close()
+			attrs = append(attrs, slog.String("request_body", truncateBody(prefix)))
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	attrs = append(attrs, slog.Duration("latency", time.Since(start)))
+	if err != nil {
+		attrs = append(attrs, slog.Any("err", err))
+		slog.LogAttrs(context.Background(), slog.LevelDebug, "http request failed", attrs...)
+		return nil, err
+	}
+	attrs = append(attrs, slog.Int("status", resp.StatusCode))
+	if t.bodies {
+		prefix, readErr := io.ReadAll(io.LimitReader(resp.Body, debugBodyLimit+1))
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(prefix), &errReader{readErr}, resp.Body), resp.Body}
+		attrs = append(attrs, slog.String("response_body", truncateBody(prefix)))
+	}
+	slog.LogAttrs(context.Background(), slog.LevelDebug, "http request", attrs...)
+	return resp, nil
+}
+
+func truncateBody(body []byte) string {
+	if len(body) > debugBodyLimit {
+		return string(body[:debugBodyLimit]) + "...(truncated)"
+	}
+	return string(body)
+}
+
+// errReader returns err once the logged prefix of a body was read, so that
+// a failed read is not hidden from the caller.
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 0, io.EOF
+}
@@ -0,0 +1,172 @@
+package comparegitfiles
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDebugTransport(t *testing.T) {
+	large := strings.Repeat("x", 3*debugBodyLimit)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large":
+			io.WriteString(w, large)
+		case "/missing":
+			http.NotFound(w, r)
+		default:
+			io.Copy(w, r.Body)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name     string
+		level    string
+		bodies   bool
+		method   string
+		path     string
+		body     string
+		wantBody string
+		want     map[string]any
+		absent   []string
+	}{
+		{
+			name: "request", level: "debug", method: http.MethodGet, path: "/small", wantBody: "",
+			want:   map[string]any{"msg": "http request", "method": "GET", "url": server.URL + "/small", "status": 200.0},
+			absent: []string{"request_body", "response_body"},
+		},
+		{
+			name: "error status", level: "debug", method: http.MethodGet, path: "/missing", wantBody: "404 page not found\n",
+			want: map[string]any{"msg": "http request", "status": 404.0},
+		},
+		{
+			name: "bodies", level: "debug", bodies: true, method: http.MethodPost, path: "/echo", body: `{"query":"x"}`, wantBody: `{"query":"x"}`,
+			want: map[string]any{"method": "POST", "request_body": `{"query":"x"}`, "response_body": `{"query":"x"}`},
+		},
+		{
+			name: "truncated body", level: "debug", bodies: true, method: http.MethodGet, path: "/large", wantBody: large,
+			want: map[string]any{"response_body": large[:debugBodyLimit] + "...(truncated)"},
+		},
+		{
+			name: "info level", level: "info", bodies: true, method: http.MethodGet, path: "/large", wantBody: large,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := useLogger(t, "json", tt.level, false)
+			req, err := http.NewRequest(tt.method, server.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := (&debugTransport{next: http.DefaultTransport, bodies: tt.bodies}).RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil || string(data) != tt.wantBody {
+				t.Errorf("caller read %d bytes, %v, want the whole body of %d bytes", len(data), err, len(tt.wantBody))
+			}
+
+			records := jsonRecords(t, out.String())
+			if tt.want == nil {
+				if len(records) != 0 {
+					t.Errorf("logged %v at %s level", records, tt.level)
+				}
+				return
+			}
+			if len(records) != 1 {
+				t.Fatalf("got %d records, want 1:\n%s", len(records), out)
+			}
+			record := records[0]
+			if record["level"] != "DEBUG" {
+				t.Errorf("level = %v, want DEBUG", record["level"])
+			}
+			if _, ok := record["latency"]; !ok {
+				t.Errorf("record has no latency: %v", record)
+			}
+			for key, want := range tt.want {
+				if record[key] != want {
+					t.Errorf("%s = %v, want %v", key, record[key], want)
+				}
+			}
+			for _, key := range tt.absent {
+				if _, ok := record[key]; ok {
+					t.Errorf("record has %s: %v", key, record)
+				}
+			}
+		})
+	}
+}
+
+func TestDebugTransportFailedRequest(t *testing.T) {
+	out := useLogger(t, "json", "debug", false)
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/gone", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&debugTransport{next: http.DefaultTransport}).RoundTrip(req); err == nil {
+		t.Fatal("request to a closed server succeeded")
+	}
+	records := jsonRecords(t, out.String())
+	if len(records) != 1 || records[0]["msg"] != "http request failed" || records[0]["err"] == nil || records[0]["url"] != server.URL+"/gone" {
+		t.Errorf("records = %v, want one failed request with its error", records)
+	}
+}
+
+func TestHTTPDebugFlag(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		want   []string
+		absent []string
+	}{
+		{
+			name:   "debug level",
+			args:   []string{"-log-level", "debug"},
+			want:   []string{`msg="http request" method=GET url={api}/repos/owner/repo/contents/conf`, "status=200", "latency="},
+			absent: []string{"response_body="},
+		},
+		{
+			name: "bodies",
+			args: []string{"-log-level", "debug", "-http-dump-bodies"},
+			want: []string{`msg="http request"`, `response_body="{\"content\":`},
+		},
+		{
+			name:   "info level",
+			args:   []string{"-log-level", "info"},
+			absent: []string{`msg="http request"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gh, dir := newCompareRepo(t, driftRemote, driftLocal, "conf")
+			args := append([]string{"compare", "-no-state", "-http-debug", filepath.Join(t.TempDir(), "http.log")}, tt.args...)
+			_, stderr, code := runMain(t, dir, gh.URL, args...)
+			if code != 0 {
+				t.Fatalf("exit code %d\n%s", code, stderr)
+			}
+			for _, want := range tt.want {
+				if want = strings.ReplaceAll(want, "{api}", gh.URL); !strings.Contains(stderr, want) {
+					t.Errorf("stderr does not contain %q:\n%s", want, stderr)
+				}
+			}
+			for _, absent := range tt.absent {
+				if strings.Contains(stderr, absent) {
+					t.Errorf("stderr contains %q:\n%s", absent, stderr)
+				}
+			}
+		})
+	}
+
+	gh, dir := newCompareRepo(t, driftRemote, driftLocal, "conf")
+	if _, stderr, code := runMain(t, dir, gh.URL, "compare", "-no-state", "-http-dump-bodies"); code != 1 || !strings.Contains(stderr, "-http-dump-bodies requires -http-debug") {
+		t.Errorf("-http-dump-bodies without -http-debug: exit code %d\n%s", code, stderr)
+	}
+}
@@ -0,0 +1,103 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ignoreRules holds Config.Ignore followed by every -ignore-pattern,
+// compiled once at startup and evaluated in order.
+var ignoreRules []ignoreRule
+
+// ignoreRule is one ignore entry. A leading `!` negates it, re-including
+// paths that earlier entries excluded.
+type ignoreRule struct {
+	negate  bool
+	literal string
+	re      *regexp.Regexp
+}
+
+// matches applies a regex to the path. A literal matches the path itself and
+// everything below it; a negated literal also matches its parent directories
+// so that traversal can still reach the re-included path.
+func (r ignoreRule) matches(path string) bool {
+	if r.re != nil {
+		return r.re.MatchString(path)
+	}
+	path = strings.Trim(path, "/")
+	literal := strings.Trim(r.literal, "/")
+	if path == literal || strings.HasPrefix(path, literal+"/") {
+		return true
+	}
+	return r.negate && strings.HasPrefix(literal, path+"/")
+}
+
+// isRegexPattern reports whether an ignore entry is a regular expression.
+// Entries without metacharacters are matched as literal paths; a dot alone
+// does not make an entry a regex.
+func isRegexPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, `^$*+?()[]{}|\`)
+}
+
+// compileIgnoreRules compiles patterns in order. With forceRegex every entry
+// is a regular expression; errors name entries as source[index].
+func compileIgnoreRules(patterns []string, source string, forceRegex bool) ([]ignoreRule, []error) {
+	var rules []ignoreRule
+	var errs []error
+	for i, pattern := range patterns {
+		rule := ignoreRule{negate: strings.HasPrefix(pattern, "!")}
+		pattern = strings.TrimPrefix(pattern, "!")
+		if !forceRegex && !isRegexPattern(pattern) {
+			rule.literal = pattern
+			rules = append(rules, rule)
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s[%d] '%s' is not a valid regular expression: %w", source, i, pattern, err))
+			continue
+		}
+		rule.re = re
+		rules = append(rules, rule)
+	}
+	return rules, errs
+}
+
+// buildIgnoreRules orders the config entries first, then -ignore-pattern,
+// -exclude and finally -include, so command line filters take precedence
+// and an -include re-includes paths excluded by anything before it.
+func buildIgnoreRules(ignore, ignorePatterns []string, opts *Options) ([]ignoreRule, error) {
+	rules, _ := compileIgnoreRules(ignore, "ignore", false)
+	adhoc, errs := compileIgnoreRules(ignorePatterns, "ignore-pattern", true)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	rules = append(rules, adhoc...)
+	exclude, errs := compileIgnoreRules(opts.Exclude, "exclude", false)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	rules = append(rules, exclude...)
+	include := make([]string, len(opts.Include))
+	for i, pattern := range opts.Include {
+		include[i] = "!" + strings.TrimPrefix(pattern, "!")
+	}
+	included, errs := compileIgnoreRules(include, "include", false)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return append(rules, included...), nil
+}
+
+// checkIgnore reports whether path is excluded. The last matching rule wins,
+// as in .gitignore.
+func checkIgnore(path string) bool {
+	ignored := false
+	for _, rule := range ignoreRules {
+		if rule.matches(path) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
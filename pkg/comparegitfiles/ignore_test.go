@@ -0,0 +1,217 @@
+package comparegitfiles
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useIgnoreRules builds the ignore rules checkIgnore evaluates until the
+// test ends.
+func useIgnoreRules(t *testing.T, ignore, ignorePatterns []string, opts *Options) {
+	t.Helper()
+	rules, err := buildIgnoreRules(ignore, ignorePatterns, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := ignoreRules
+	ignoreRules = rules
+	t.Cleanup(func() { ignoreRules = saved })
+}
+
+func TestCheckIgnoreNegation(t *testing.T) {
+	tests := []struct {
+		name   string
+		ignore []string
+		paths  map[string]bool
+	}{
+		{
+			name:   "no negation",
+			ignore: []string{"generated"},
+			paths: map[string]bool{
+				"generated":        true,
+				"generated/a.go":   true,
+				"generatedfile.go": false,
+				"src/a.go":         false,
+			},
+		},
+		{
+			name:   "negated file in ignored directory",
+			ignore: []string{"generated", "!generated/keep.go"},
+			paths: map[string]bool{
+				"generated":         false,
+				"generated/keep.go": false,
+				"generated/a.go":    true,
+			},
+		},
+		{
+			name:   "nested negation",
+			ignore: []string{"vendor", "!vendor/github.com/acme/lib"},
+			paths: map[string]bool{
+				"vendor":                          false,
+				"vendor/github.com":               false,
+				"vendor/github.com/acme":          false,
+				"vendor/github.com/acme/lib":      false,
+				"vendor/github.com/acme/lib/x.go": false,
+				"vendor/github.com/acme/other":    true,
+				"vendor/golang.org/x/net":         true,
+			},
+		},
+		{
+			name:   "negation after directory glob",
+			ignore: []string{"^docs/.*", "!docs/README.md"},
+			paths: map[string]bool{
+				"docs/README.md":    false,
+				"docs/guide.md":     true,
+				"docs/api/index.md": true,
+			},
+		},
+		{
+			name:   "negation of negation",
+			ignore: []string{"docs", "!docs/api", "docs/api/internal"},
+			paths: map[string]bool{
+				"docs/guide.md":          true,
+				"docs/api/public.md":     false,
+				"docs/api/internal/x.md": true,
+			},
+		},
+		{
+			name:   "last match wins",
+			ignore: []string{"!conf/app.yaml", "conf"},
+			paths: map[string]bool{
+				"conf/app.yaml": true,
+			},
+		},
+		{
+			name:   "negated regex",
+			ignore: []string{`\.pb\.go$`, `!^api/.*\.pb\.go$`},
+			paths: map[string]bool{
+				"internal/x.pb.go": true,
+				"api/v1/x.pb.go":   false,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useIgnoreRules(t, tt.ignore, nil, &Options{})
+			for path, want := range tt.paths {
+				if got := checkIgnore(path); got != want {
+					t.Errorf("checkIgnore(%s) = %v, want %v", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckIgnoreIncludeExclude(t *testing.T) {
+	tests := []struct {
+		name             string
+		ignore           []string
+		include, exclude []string
+		paths            map[string]bool
+	}{
+		{
+			name:    "exclude",
+			exclude: []string{"conf/local.yaml"},
+			paths: map[string]bool{
+				"conf/local.yaml": true,
+				"conf/app.yaml":   false,
+			},
+		},
+		{
+			name:    "include re-includes config ignore",
+			ignore:  []string{"conf"},
+			include: []string{"conf/app.yaml"},
+			paths: map[string]bool{
+				"conf/app.yaml":   false,
+				"conf/local.yaml": true,
+			},
+		},
+		{
+			name:    "include re-includes exclude",
+			exclude: []string{"conf"},
+			include: []string{"conf/app.yaml"},
+			paths: map[string]bool{
+				"conf/app.yaml":   false,
+				"conf/local.yaml": true,
+			},
+		},
+		{
+			name:    "exclude adds to config ignore",
+			ignore:  []string{"generated"},
+			exclude: []string{`\.bak$`},
+			paths: map[string]bool{
+				"generated/a.go": true,
+				"conf/app.bak":   true,
+				"conf/app.yaml":  false,
+			},
+		},
+		{
+			name:    "exclude overrides config negation",
+			ignore:  []string{"conf", "!conf/app.yaml"},
+			exclude: []string{"conf/app.yaml"},
+			paths: map[string]bool{
+				"conf/app.yaml": true,
+			},
+		},
+		{
+			name:    "include with leading bang",
+			ignore:  []string{"conf"},
+			include: []string{"!conf/app.yaml"},
+			paths: map[string]bool{
+				"conf/app.yaml": false,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useIgnoreRules(t, tt.ignore, nil, &Options{Include: tt.include, Exclude: tt.exclude})
+			for path, want := range tt.paths {
+				if got := checkIgnore(path); got != want {
+					t.Errorf("checkIgnore(%s) = %v, want %v", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchPathIncludeExclude(t *testing.T) {
+	newFakeGitHub(t, map[string]string{
+		"conf/app.yaml":        "app\n",
+		"conf/local.yaml":      "local\n",
+		"conf/secrets/db.yaml": "db\n",
+		"conf/secrets/ca.pem":  "ca\n",
+		"src/main.go":          "main\n",
+	})
+	dir := t.TempDir()
+	chdir(t, dir)
+	resetRunState()
+
+	opts := &Options{
+		Path:             "conf",
+		Token:            "secret",
+		ParallelStrategy: "depth-first",
+		Concurrency:      maxParallel,
+		Exclude:          []string{"conf/secrets", "conf/local.yaml"},
+		Include:          []string{"conf/secrets/ca.pem"},
+	}
+	useIgnoreRules(t, nil, nil, opts)
+	if err := updateDependencies(context.Background(), opts, &Config{Name: "owner/repo", Files: []string{"src"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		"conf/app.yaml":        true,
+		"conf/local.yaml":      false,
+		"conf/secrets/db.yaml": false,
+		"conf/secrets/ca.pem":  true,
+		"src/main.go":          false,
+	}
+	for path, fetched := range want {
+		_, err := os.Stat(filepath.Join(dir, path))
+		if got := err == nil; got != fetched {
+			t.Errorf("%s fetched = %v, want %v", path, got, fetched)
+		}
+	}
+}
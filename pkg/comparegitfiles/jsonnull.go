@@ -0,0 +1,98 @@
+package comparegitfiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+func isJSONFile(path string) bool {
+	return strings.HasSuffix(path, ".json")
+}
+
+// dropJSONNulls re-indents src without the object members whose value is
+// null, so that `{"key": null}` and `{}` compare equal. Member order and
+// number formatting are kept; nulls inside arrays are positional and stay.
+func dropJSONNulls(src, filename string) (string, error) {
+	stripped, err := stripJSONNulls(json.RawMessage(src))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, stripped, "", "  "); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	out.WriteByte('\n')
+	return out.String(), nil
+}
+
+func stripJSONNulls(raw json.RawMessage) (json.RawMessage, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("unexpected end of JSON input")
+	}
+	switch raw[0] {
+	case '{':
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		var out bytes.Buffer
+		out.WriteByte('{')
+		first := true
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return nil, err
+			}
+			if string(value) == "null" {
+				continue
+			}
+			if value, err = stripJSONNulls(value); err != nil {
+				return nil, err
+			}
+			name, err := json.Marshal(key)
+			if err != nil {
+				return nil, err
+			}
+			if !first {
+				out.WriteByte(',')
+			}
+			first = false
+			out.Write(name)
+			out.WriteByte(':')
+			out.Write(value)
+		}
+		out.WriteByte('}')
+		return out.Bytes(), nil
+	case '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, err
+		}
+		var out bytes.Buffer
+		out.WriteByte('[')
+		for i, item := range items {
+			item, err := stripJSONNulls(item)
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			out.Write(item)
+		}
+		out.WriteByte(']')
+		return out.Bytes(), nil
+	default:
+		if !json.Valid(raw) {
+			return nil, fmt.Errorf("invalid JSON value %.20q", raw)
+		}
+		return raw, nil
+	}
+}
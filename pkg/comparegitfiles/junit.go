@@ -0,0 +1,64 @@
+package comparegitfiles
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// writeJUnitResults writes one testcase per examined file, failing the
+// ones that differ with the diff as the failure body.
+func writeJUnitResults(w io.Writer, results []DiffResult) error {
+	suite := junitSuite{Name: "comparegitfiles", Tests: len(results)}
+	for _, result := range results {
+		testCase := junitTestCase{Name: result.Path, ClassName: "comparegitfiles"}
+		switch {
+		case result.Status == statusMissingLocal:
+			testCase.Failure = &junitFailure{Message: "missing locally"}
+		case result.Status == statusRenamed:
+			testCase.Failure = &junitFailure{Message: "renamed to " + result.RenamedTo}
+		case result.Status != statusIdentical:
+			testCase.Failure = &junitFailure{Message: linesDiffer(result.Changes), Body: result.Diff}
+		}
+		if testCase.Failure != nil {
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("failed to write junit results: %w", err)
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{Suites: []junitSuite{suite}}); err != nil {
+		return fmt.Errorf("failed to write junit results: %w", err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("failed to write junit results: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package comparegitfiles
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestWriteJUnitResults(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   DiffResult
+		failure  *junitFailure
+		contains string
+	}{
+		{
+			name:   "identical",
+			result: DiffResult{Path: "conf/same.yaml", Status: statusIdentical},
+		},
+		{
+			name:    "changed",
+			result:  DiffResult{Path: "conf/app.yaml", Status: statusChanged, Changes: 2, Diff: "-b: 3\n+b: 2\n"},
+			failure: &junitFailure{Message: "2 lines differ", Body: "-b: 3\n+b: 2\n"},
+		},
+		{
+			name:    "one line",
+			result:  DiffResult{Path: "conf/app.yaml", Status: statusChanged, Changes: 1, Diff: "+b: 2\n"},
+			failure: &junitFailure{Message: "1 line differs", Body: "+b: 2\n"},
+		},
+		{
+			name:    "missing locally",
+			result:  DiffResult{Path: "conf/new.yaml", Status: statusMissingLocal},
+			failure: &junitFailure{Message: "missing locally"},
+		},
+		{
+			name:     "special characters",
+			result:   DiffResult{Path: `conf/<a & "b">'.yaml`, Status: statusChanged, Changes: 2, Diff: "-x: <a href=\"y\">&amp;</a>\n+x: ]]> & <\n"},
+			failure:  &junitFailure{Message: "2 lines differ", Body: "-x: <a href=\"y\">&amp;</a>\n+x: ]]> & <\n"},
+			contains: `name="conf/&lt;a &amp; &#34;b&#34;&gt;&#39;.yaml"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := writeJUnitResults(&out, []DiffResult{tt.result}); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(out.String(), xml.Header) {
+				t.Errorf("output does not start with the xml header:\n%s", out.String())
+			}
+			if !strings.Contains(out.String(), tt.contains) {
+				t.Errorf("output does not contain %q:\n%s", tt.contains, out.String())
+			}
+
+			var suites junitTestSuites
+			if err := xml.Unmarshal(out.Bytes(), &suites); err != nil {
+				t.Fatalf("output is not valid xml: %v\n%s", err, out.String())
+			}
+			if len(suites.Suites) != 1 || len(suites.Suites[0].Cases) != 1 {
+				t.Fatalf("suites = %+v, want one suite with one testcase", suites.Suites)
+			}
+			suite := suites.Suites[0]
+			wantFailures := 0
+			if tt.failure != nil {
+				wantFailures = 1
+			}
+			if suite.Name != "comparegitfiles" || suite.Tests != 1 || suite.Failures != wantFailures {
+				t.Errorf("suite %q has %d tests and %d failures, want comparegitfiles with 1 and %d", suite.Name, suite.Tests, suite.Failures, wantFailures)
+			}
+			testCase := suite.Cases[0]
+			if testCase.Name != tt.result.Path {
+				t.Errorf("testcase name = %q, want %q", testCase.Name, tt.result.Path)
+			}
+			switch {
+			case tt.failure == nil && testCase.Failure != nil:
+				t.Errorf("failure = %+v, want a passing testcase", testCase.Failure)
+			case tt.failure != nil && (testCase.Failure == nil || *testCase.Failure != *tt.failure):
+				t.Errorf("failure = %+v, want %+v", testCase.Failure, tt.failure)
+			}
+		})
+	}
+}
+
+func TestJUnitFormat(t *testing.T) {
+	gh, dir := newCompareRepo(t, driftRemote, driftLocal, "conf")
+	stdout, stderr, code := runMain(t, dir, gh.URL, "compare", "-no-state", "-format", "junit")
+	if code != 0 {
+		t.Fatalf("exit code %d\n%s", code, stderr)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal([]byte(stdout), &suites); err != nil {
+		t.Fatalf("stdout is not valid xml: %v\n%s", err, stdout)
+	}
+	cases := suites.Suites[0].Cases
+	if len(cases) != 2 || cases[0].Name != "conf/app.yaml" || cases[1].Name != "conf/same.yaml" {
+		t.Fatalf("testcases = %+v, want conf/app.yaml and conf/same.yaml", cases)
+	}
+	if cases[0].Failure == nil || !strings.Contains(cases[0].Failure.Body, "+b: 2") {
+		t.Errorf("conf/app.yaml failure = %+v, want the diff as its body", cases[0].Failure)
+	}
+	if cases[1].Failure != nil {
+		t.Errorf("conf/same.yaml failure = %+v, want a passing testcase", cases[1].Failure)
+	}
+}
@@ -0,0 +1,82 @@
+package comparegitfiles
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/term"
+)
+
+const (
+	keyringService = "comparegitfiles"
+	keyringUser    = "github-token"
+)
+
+// readKeyringToken returns the github token stored by auth set, or an empty
+// string when there is none or no keychain is available.
+func readKeyringToken() string {
+	token, err := keyring.Get(keyringService, keyringUser)
+	if err != nil {
+		if !errors.Is(err, keyring.ErrNotFound) {
+			slog.Debug("failed to read the os keychain", "err", err)
+		}
+		return ""
+	}
+	return token
+}
+
+// readTokenInput reads the token for auth set from stdin, without echoing it
+// on a terminal.
+func readTokenInput() (string, error) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprint(os.Stderr, "GitHub token: ")
+		data, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read token: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// runAuth stores the token in the os keychain for auth set, or prints the
+// stored one for auth get so that scripts can reuse it.
+func runAuth(action, token string) error {
+	switch action {
+	case "set":
+		if token == "" {
+			var err error
+			if token, err = readTokenInput(); err != nil {
+				return err
+			}
+		}
+		if token == "" {
+			return fmt.Errorf("no token given")
+		}
+		if err := keyring.Set(keyringService, keyringUser, token); err != nil {
+			return fmt.Errorf("failed to store token in the os keychain: %w", err)
+		}
+		fmt.Println("Token stored in the os keychain")
+	case "get":
+		token, err := keyring.Get(keyringService, keyringUser)
+		if errors.Is(err, keyring.ErrNotFound) {
+			return fmt.Errorf("no token stored, run `comparegitfiles auth set`")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read the os keychain: %w", err)
+		}
+		fmt.Println(token)
+	}
+	return nil
+}
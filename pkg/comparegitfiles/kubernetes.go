@@ -0,0 +1,181 @@
+package comparegitfiles
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	kubernetesAPIVersion = regexp.MustCompile(`(?m)^apiVersion:`)
+	kubernetesKind       = regexp.MustCompile(`(?m)^kind:`)
+)
+
+type kubernetesResource struct {
+	Labels      map[string]string
+	Annotations map[string]string
+	Replicas    string
+	Images      map[string]string
+	Spec        map[string]string
+}
+
+func isKubernetesManifest(path, content string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".yaml" && ext != ".yml" {
+		return false
+	}
+	return kubernetesAPIVersion.MatchString(content) && kubernetesKind.MatchString(content)
+}
+
+// parseKubernetes indexes every document of a manifest by kind/name, with the
+// namespace prepended when one is set.
+func parseKubernetes(src, filename string) (map[string]kubernetesResource, error) {
+	resources := make(map[string]kubernetesResource)
+	decoder := yaml.NewDecoder(bytes.NewBufferString(src))
+	for {
+		var doc map[string]interface{}
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+		}
+		kind, _ := doc["kind"].(string)
+		if kind == "" {
+			continue
+		}
+		metadata, _ := doc["metadata"].(map[string]interface{})
+		name, _ := metadata["name"].(string)
+		key := strings.ToLower(kind) + "/" + name
+		if namespace, _ := metadata["namespace"].(string); namespace != "" {
+			key = namespace + "/" + key
+		}
+
+		resource := kubernetesResource{
+			Labels:      stringMap(metadata["labels"]),
+			Annotations: stringMap(metadata["annotations"]),
+			Images:      make(map[string]string),
+			Spec:        make(map[string]string),
+		}
+		if spec, ok := doc["spec"].(map[string]interface{}); ok {
+			if replicas, ok := spec["replicas"]; ok {
+				resource.Replicas = fmt.Sprint(replicas)
+			}
+			flattenKubernetes("spec", spec, resource)
+		}
+		resources[key] = resource
+	}
+	return resources, nil
+}
+
+func stringMap(value interface{}) map[string]string {
+	out := make(map[string]string)
+	m, _ := value.(map[string]interface{})
+	for key, v := range m {
+		out[key] = fmt.Sprint(v)
+	}
+	return out
+}
+
+// flattenKubernetes records every scalar below spec by its dotted path.
+// List items that carry a name are addressed by it, so reordering containers
+// does not show up as a change. Replicas and container images are kept apart
+// because they get their own report lines.
+func flattenKubernetes(path string, value interface{}, resource kubernetesResource) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if path == "spec" && key == "replicas" {
+				continue
+			}
+			flattenKubernetes(path+"."+key, child, resource)
+		}
+	case []interface{}:
+		for i, item := range v {
+			index := fmt.Sprint(i)
+			if m, ok := item.(map[string]interface{}); ok {
+				if name, ok := m["name"].(string); ok {
+					index = name
+				}
+				if image, ok := m["image"].(string); ok && (strings.HasSuffix(path, ".containers") || strings.HasSuffix(path, ".initContainers")) {
+					resource.Images[index] = image
+				}
+			}
+			flattenKubernetes(path+"["+index+"]", item, resource)
+		}
+	default:
+		if strings.HasSuffix(path, "].image") && strings.Contains(path, "ontainers[") {
+			return
+		}
+		resource.Spec[path] = fmt.Sprint(v)
+	}
+}
+
+func compareKubernetes(local, remote, filename string) ([]string, error) {
+	before, err := parseKubernetes(local, filename)
+	if err != nil {
+		return nil, err
+	}
+	after, err := parseKubernetes(remote, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []string
+	for _, key := range sortedKeys(before, after) {
+		oldResource, hadResource := before[key]
+		newResource, hasResource := after[key]
+		switch {
+		case !hasResource:
+			changes = append(changes, fmt.Sprintf("[REMOVED] %s", key))
+			continue
+		case !hadResource:
+			changes = append(changes, fmt.Sprintf("[ADDED] %s", key))
+			continue
+		}
+		changes = append(changes, compareKubernetesValues(key, "LABEL", oldResource.Labels, newResource.Labels)...)
+		changes = append(changes, compareKubernetesValues(key, "ANNOTATION", oldResource.Annotations, newResource.Annotations)...)
+		if oldResource.Replicas != newResource.Replicas {
+			changes = append(changes, fmt.Sprintf("[REPLICA CHANGED] %s: %s → %s", key, kubernetesValue(oldResource.Replicas), kubernetesValue(newResource.Replicas)))
+		}
+		for _, container := range sortedKeys(oldResource.Images, newResource.Images) {
+			oldImage, newImage := oldResource.Images[container], newResource.Images[container]
+			if oldImage != newImage {
+				changes = append(changes, fmt.Sprintf("[IMAGE CHANGED] %s: %s → %s", key, kubernetesValue(oldImage), kubernetesValue(newImage)))
+			}
+		}
+		changes = append(changes, compareKubernetesValues(key, "SPEC", oldResource.Spec, newResource.Spec)...)
+	}
+	return changes, nil
+}
+
+func compareKubernetesValues(key, label string, before, after map[string]string) []string {
+	var changes []string
+	for _, field := range sortedKeys(before, after) {
+		oldValue, hadValue := before[field]
+		newValue, hasValue := after[field]
+		switch {
+		case !hasValue:
+			changes = append(changes, fmt.Sprintf("[%s REMOVED] %s: %s", label, key, field))
+		case !hadValue:
+			changes = append(changes, fmt.Sprintf("[%s ADDED] %s: %s=%s", label, key, field, newValue))
+		case oldValue != newValue:
+			changes = append(changes, fmt.Sprintf("[%s CHANGED] %s: %s: %s → %s", label, key, field, oldValue, newValue))
+		}
+	}
+	return changes
+}
+
+func kubernetesValue(value string) string {
+	if value == "" {
+		return "<unset>"
+	}
+	return value
+}
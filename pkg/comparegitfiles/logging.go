@@ -0,0 +1,35 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// setupLogger installs the default slog logger writing to w. Without
+// -log-level only warnings and errors are logged, or everything with
+// -verbose, so that a successful run stays quiet on stderr.
+func setupLogger(w io.Writer, format, level string, verbose bool) error {
+	lvl := slog.LevelWarn
+	switch {
+	case level != "":
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return fmt.Errorf("invalid log level %q: must be debug, info, warn or error", level)
+		}
+	case verbose:
+		lvl = slog.LevelDebug
+	}
+	options := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	case "json":
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return fmt.Errorf("invalid log format %q: must be text or json", format)
+	}
+	slog.SetDefault(slog.New(sanitizingHandler{handler}))
+	return nil
+}
@@ -0,0 +1,195 @@
+package comparegitfiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// useLogger installs the logger setupLogger builds until the test ends and
+// returns the buffer it writes to.
+func useLogger(t *testing.T, format, level string, verbose bool) *bytes.Buffer {
+	t.Helper()
+	saved := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(saved) })
+	var out bytes.Buffer
+	if err := setupLogger(&out, format, level, verbose); err != nil {
+		t.Fatal(err)
+	}
+	return &out
+}
+
+// jsonRecords parses the newline-delimited records of the json handler.
+func jsonRecords(t *testing.T, out string) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("log line is not json: %v\n%s", err, line)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestSetupLoggerLevels(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   string
+		verbose bool
+		want    []string
+	}{
+		{name: "default", want: []string{"WARN", "ERROR"}},
+		{name: "verbose", verbose: true, want: []string{"DEBUG", "INFO", "WARN", "ERROR"}},
+		{name: "debug", level: "debug", want: []string{"DEBUG", "INFO", "WARN", "ERROR"}},
+		{name: "info", level: "info", want: []string{"INFO", "WARN", "ERROR"}},
+		{name: "warn", level: "warn", want: []string{"WARN", "ERROR"}},
+		{name: "error", level: "error", want: []string{"ERROR"}},
+		{name: "level overrides verbose", level: "error", verbose: true, want: []string{"ERROR"}},
+		{name: "upper case level", level: "INFO", want: []string{"INFO", "WARN", "ERROR"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := useLogger(t, "json", tt.level, tt.verbose)
+			slog.Debug("fetched file", "path", "conf/app.yaml")
+			slog.Info("differences", "path", "conf/app.yaml", "changes", 2)
+			slog.Warn("falling back to text diff", "path", "conf/app.yaml")
+			slog.Error("failed", "path", "conf/app.yaml")
+
+			var levels []string
+			for _, record := range jsonRecords(t, out.String()) {
+				levels = append(levels, record["level"].(string))
+				if record["path"] != "conf/app.yaml" {
+					t.Errorf("record %v has no path field", record)
+				}
+			}
+			if strings.Join(levels, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("logged levels %v, want %v", levels, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetupLoggerFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		check  func(t *testing.T, out string)
+	}{
+		{
+			format: "text",
+			check: func(t *testing.T, out string) {
+				if want := "level=INFO msg=differences path=conf/app.yaml changes=2\n"; !strings.HasSuffix(out, want) {
+					t.Errorf("text record = %q, want suffix %q", out, want)
+				}
+			},
+		},
+		{
+			format: "json",
+			check: func(t *testing.T, out string) {
+				records := jsonRecords(t, out)
+				if len(records) != 1 {
+					t.Fatalf("got %d records, want 1", len(records))
+				}
+				record := records[0]
+				if record["level"] != "INFO" || record["msg"] != "differences" || record["path"] != "conf/app.yaml" || record["changes"] != 2.0 {
+					t.Errorf("json record = %v", record)
+				}
+				if _, ok := record["time"]; !ok {
+					t.Errorf("json record has no time: %v", record)
+				}
+			},
+		},
+		{
+			format: "JSON",
+			check: func(t *testing.T, out string) {
+				if len(jsonRecords(t, out)) != 1 {
+					t.Errorf("format is not case insensitive: %q", out)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			out := useLogger(t, tt.format, "info", false)
+			slog.Info("differences", "path", "conf/app.yaml", "changes", 2)
+			tt.check(t, out.String())
+		})
+	}
+}
+
+func TestSetupLoggerInvalid(t *testing.T) {
+	tests := []struct {
+		format, level string
+		want          string
+	}{
+		{format: "xml", want: `invalid log format "xml"`},
+		{format: "text", level: "trace", want: `invalid log level "trace"`},
+	}
+	saved := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(saved) })
+	for _, tt := range tests {
+		err := setupLogger(&bytes.Buffer{}, tt.format, tt.level, false)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("setupLogger(%q, %q) = %v, want %s", tt.format, tt.level, err, tt.want)
+		}
+	}
+}
+
+func TestLogFormatJSON(t *testing.T) {
+	gh, dir := newCompareRepo(t, driftRemote, driftLocal, "conf")
+	_, stderr, code := runMain(t, dir, gh.URL, "compare", "-no-state", "-log-format", "json", "-log-level", "info")
+	if code != 0 {
+		t.Fatalf("exit code %d\n%s", code, stderr)
+	}
+	var found bool
+	for _, record := range jsonRecords(t, stderr) {
+		if record["msg"] == "differences" {
+			found = true
+			if record["level"] != "INFO" || record["path"] != "conf/app.yaml" || record["changes"] != 2.0 {
+				t.Errorf("differences record = %v", record)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("no differences record in stderr:\n%s", stderr)
+	}
+}
+
+func TestQuietRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		stderr []string
+	}{
+		{name: "compare", args: []string{"compare", "-no-state"}},
+		{name: "compare at warn", args: []string{"compare", "-no-state", "-log-level", "warn"}},
+		{name: "sync", args: []string{"sync"}},
+		{name: "fetch", args: []string{"fetch"}},
+		{name: "sync verbose", args: []string{"sync", "-verbose"}, stderr: []string{"msg=\"fetched file\" path=conf/app.yaml"}},
+		{name: "sync at debug", args: []string{"sync", "-log-level", "debug"}, stderr: []string{"msg=\"fetched file\" path=conf/app.yaml"}},
+		{name: "compare at info", args: []string{"compare", "-no-state", "-log-level", "info"}, stderr: []string{"msg=differences path=conf/app.yaml"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gh, dir := newCompareRepo(t, driftRemote, driftLocal, "conf")
+			_, stderr, code := runMain(t, dir, gh.URL, tt.args...)
+			if code != 0 {
+				t.Fatalf("exit code %d\n%s", code, stderr)
+			}
+			if tt.stderr == nil && stderr != "" {
+				t.Errorf("stderr = %q, want no output from a successful run", stderr)
+			}
+			for _, want := range tt.stderr {
+				if !strings.Contains(stderr, want) {
+					t.Errorf("stderr does not contain %q:\n%s", want, stderr)
+				}
+			}
+		})
+	}
+}
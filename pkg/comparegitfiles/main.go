@@ -0,0 +1,1558 @@
+package comparegitfiles
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/muesli/termenv"
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/term"
+)
+
+const (
+	depsDir     = "./"
+	maxParallel = 5
+	maxLineSize = 1024 * 1024
+
+	maxAPIPageSize = 100
+)
+
+type BlobResponse struct {
+	Content  string `json:"content"`
+	Encoding string `json:"encoding"`
+}
+
+type GithubContent struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Type        string `json:"type"`
+	Sha         string `json:"sha"`
+	DownloadURL string `json:"download_url"`
+	Mode        string `json:"mode"`
+	Size        int64  `json:"size"`
+	Target      string `json:"target"`
+}
+
+// Config is a parsed diffs.json: the repository, the ref and the files to
+// compare or fetch.
+type Config struct {
+	Files    []string `json:"files" yaml:"files" toml:"files"`
+	Ignore   []string `json:"ignore" yaml:"ignore,omitempty" toml:"ignore,omitempty"`
+	Branch   string   `json:"branch" yaml:"branch,omitempty" toml:"branch,omitempty"`
+	Commit   string   `json:"commit" yaml:"commit,omitempty" toml:"commit,omitempty"`
+	Tag      string   `json:"tag" yaml:"tag,omitempty" toml:"tag,omitempty"`
+	Name     string   `json:"name" yaml:"name" toml:"name"`
+	Provider string   `json:"provider" yaml:"provider,omitempty" toml:"provider,omitempty"`
+}
+
+var (
+	githubAPI = "https://api.github.com"
+	sem       = semaphore.NewWeighted(maxParallel)
+	client    = NewHTTPClient(defaultHTTPClientConfig())
+
+	// apiPageSize is the number of entries requested per directory listing page.
+	apiPageSize = maxAPIPageSize
+)
+
+// serialSem lets only one file outside -parallel-file-types download at a
+// time, so slow binaries hold at most one slot of sem.
+var serialSem = semaphore.NewWeighted(1)
+
+type Options struct {
+	Compare               bool
+	Verbose               bool
+	Path                  string
+	Token                 string
+	ParallelStrategy      string
+	StreamThreshold       int64
+	CompareTerraform      bool
+	CompareAsTemplate     bool
+	CompareNullTolerant   bool
+	GraphvizOutput        string
+	AutoConfigureIgnore   bool
+	CompareProto          bool
+	HTTPDebug             string
+	TLSCACert             string
+	TLSCert               string
+	TLSKey                string
+	TLSSkipVerify         bool
+	StreamOutput          bool
+	UseTreesAPI           bool
+	CopyPermissions       bool
+	StateFile             string
+	DedupIdentical        bool
+	Sync                  bool
+	Status                bool
+	CompareOpenAPI        bool
+	PRDescription         string
+	CompareDocker         bool
+	SplitLargeDiffs       int
+	Concurrency           int
+	ParallelDownload      bool
+	ChunkThreshold        int64
+	CompareKubernetes     bool
+	StrictGlobs           bool
+	CreateDirsOnly        bool
+	Include               []string
+	Exclude               []string
+	HTMLFragment          string
+	IncludeStyles         bool
+	NoColor               bool
+	IgnoreVersionComments bool
+	VersionComments       []*regexp.Regexp
+	VersionCommentLines   int
+	Output                string
+	Format                string
+	GoEmbed               string
+	DiffOnlyChangedDirs   bool
+	ReportSymlinkTargets  bool
+	ValidateUTF8          bool
+	SideBySide            bool
+	Width                 int
+	ShellScript           string
+	LineNumbers           bool
+	ComparePermissions    bool
+	MinSimilarity         float64
+	MaxSimilarity         float64
+	DetectRenames         bool
+	RenameSimilarity      float64
+	NormalizeWhitespace   bool
+	IgnoreLeadingSpaces   bool
+	TerraformBackend      string
+	DiffSQLite            string
+	ComplianceReport      string
+	DiffContextSeparator  string
+	RebaseOnto            string
+	DependabotConfig      string
+	NginxInclude          string
+	VersionsFile          string
+	// Differ replaces the line diff of compareFile, e.g. with MyersDiffer.
+	// Files above StreamThreshold are only streamed with the default.
+	Differ Differ
+	// ParallelFileTypes lists the extensions, without dot, downloaded in
+	// parallel. Other files are downloaded one at a time. Empty means all.
+	ParallelFileTypes []string
+}
+
+// similarityShown reports whether a file with the given similarity passes
+// -min-similarity and -max-similarity.
+func (o *Options) similarityShown(similarity float64) bool {
+	return similarity >= o.MinSimilarity && similarity <= o.MaxSimilarity
+}
+
+// showsDiffs reports whether diffs are rendered, which -verbose does on the
+// terminal and -output always does for the file.
+func (o *Options) showsDiffs() bool {
+	return o.Verbose || o.Output != ""
+}
+
+// differ returns the Differ compareFile uses, LineDiffer unless one is set.
+func (o *Options) differ() Differ {
+	if o.Differ != nil {
+		return o.Differ
+	}
+	return LineDiffer{IgnoreLeadingSpaces: o.IgnoreLeadingSpaces}
+}
+
+// downloadsInParallel reports whether filePath may be downloaded while other
+// files are, see -parallel-file-types.
+func (o *Options) downloadsInParallel(filePath string) bool {
+	if len(o.ParallelFileTypes) == 0 {
+		return true
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filePath), "."))
+	return ext != "" && slices.Contains(o.ParallelFileTypes, ext)
+}
+
+// collectsPatches reports whether compareFile keeps a unified patch of
+// every changed file, for -format patch or -rebase-onto.
+func (o *Options) collectsPatches() bool {
+	return o.Format == "patch" || o.RebaseOnto != ""
+}
+
+func baseTransport() http.RoundTripper {
+	if client.Transport != nil {
+		return client.Transport
+	}
+	return http.DefaultTransport
+}
+
+// Main runs the comparegitfiles command line with args, excluding the
+// program name, and exits the process.
+func Main(args []string) {
+	cli, err := parseArgs(args)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	opts := &cli.opts
+	if err := setupLogger(os.Stderr, cli.logFormat, cli.logLevel, opts.Verbose); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if cli.showVersion {
+		fmt.Println(versionString())
+		os.Exit(0)
+	}
+	slog.Debug(versionString())
+	if cli.logout {
+		if err := deleteCachedToken(); err != nil {
+			slog.Error("failed to log out", "err", err)
+			os.Exit(1)
+		}
+		fmt.Println("Logged out")
+		os.Exit(0)
+	}
+	if cli.command == "convert" {
+		if err := convertConfig(cli.config, cli.convertTo, cli.convertOutput); err != nil {
+			slog.Error("failed to convert config", "err", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if cli.command == "auth" {
+		if err := runAuth(cli.action, cli.token); err != nil {
+			slog.Error("failed to "+cli.action+" token", "err", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if cli.command == "completion" {
+		if err := generateCompletion(cli.action, os.Stdout); err != nil {
+			slog.Error("failed to generate completion", "err", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if cli.command == "hooks" {
+		var err error
+		switch cli.action {
+		case "install":
+			err = installHook(cli.hookPath, cli.hookTemplate, cli.config)
+		case "uninstall":
+			err = uninstallHook(cli.hookPath)
+		}
+		if err != nil {
+			slog.Error("failed to "+cli.action+" hook", "err", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if opts.Concurrency < 1 {
+		slog.Error("invalid concurrency", "concurrency", opts.Concurrency)
+		os.Exit(1)
+	}
+	sem = semaphore.NewWeighted(int64(opts.Concurrency))
+	if cli.apiPageSize < 1 || cli.apiPageSize > maxAPIPageSize {
+		slog.Error("invalid api page size", "size", cli.apiPageSize, "max", maxAPIPageSize)
+		os.Exit(1)
+	}
+	apiPageSize = cli.apiPageSize
+	for _, ext := range strings.Split(cli.parallelFileTypes, ",") {
+		if ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), ".")); ext != "" {
+			opts.ParallelFileTypes = append(opts.ParallelFileTypes, ext)
+		}
+	}
+	opts.ChunkThreshold, err = parseByteSize(cli.chunkThreshold)
+	if err != nil {
+		slog.Error("invalid chunk threshold", "err", err)
+		os.Exit(1)
+	}
+	if opts.IgnoreVersionComments {
+		patterns := cli.versionCommentPatterns
+		if len(patterns) == 0 {
+			patterns = defaultVersionCommentPatterns
+		}
+		for _, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				slog.Error("invalid -version-comment-pattern", "pattern", pattern, "err", err)
+				os.Exit(1)
+			}
+			opts.VersionComments = append(opts.VersionComments, re)
+		}
+	}
+	switch opts.Format {
+	case "pretty", "json", "csv", "html", "patch", "github-actions", "sarif", "junit":
+	default:
+		slog.Error("invalid format", "format", opts.Format)
+		os.Exit(1)
+	}
+	differ, err := newDiffer(cli.diffAlgorithm, opts.IgnoreLeadingSpaces)
+	if err != nil {
+		slog.Error("invalid -diff-algorithm", "err", err)
+		os.Exit(1)
+	}
+	if opts.RenameSimilarity < 0 || opts.RenameSimilarity > 1 {
+		slog.Error("invalid -rename-similarity, must be between 0.0 and 1.0", "similarity", opts.RenameSimilarity)
+		os.Exit(1)
+	}
+	// the default line diff keeps streaming files above -stream-threshold
+	if cli.diffAlgorithm != "line" {
+		opts.Differ = differ
+	}
+	if cli.watch && cli.watchInterval <= 0 {
+		slog.Error("invalid watch interval", "interval", cli.watchInterval)
+		os.Exit(1)
+	}
+	if opts.RebaseOnto != "" && opts.DiffContextSeparator != "" {
+		slog.Error("-rebase-onto needs applicable patches and cannot be combined with -diff-context-separator")
+		os.Exit(1)
+	}
+	if cli.notifyEmail != "" && cli.smtp.Host == "" {
+		slog.Error("-notify-email requires -smtp-host")
+		os.Exit(1)
+	}
+	if cli.smtp.Pass == "" {
+		cli.smtp.Pass = os.Getenv("COMPAREGITFILES_SMTP_PASS")
+	}
+	if opts.ParallelStrategy != "depth-first" && opts.ParallelStrategy != "breadth-first" {
+		slog.Error("invalid parallel strategy", "strategy", opts.ParallelStrategy)
+		os.Exit(1)
+	}
+	httpConfig, err := httpClientConfigFromEnv()
+	if err != nil {
+		slog.Error("invalid http client configuration", "err", err)
+		os.Exit(1)
+	}
+	if httpConfig.Proxy, err = proxyFunc(cli.proxy, cli.noProxy); err != nil {
+		slog.Error("invalid proxy configuration", "err", err)
+		os.Exit(1)
+	}
+	if httpConfig.TLSConfig, err = buildTLSConfig(opts); err != nil {
+		slog.Error("invalid tls configuration", "err", err)
+		os.Exit(1)
+	}
+	client = NewHTTPClient(httpConfig)
+	if cli.requestTimeout < 0 || cli.totalTimeout < 0 {
+		slog.Error("timeouts must not be negative")
+		os.Exit(1)
+	}
+	if cli.requestTimeout > 0 {
+		client.Transport = &timeoutTransport{next: baseTransport(), timeout: cli.requestTimeout}
+	}
+	retryStatuses, err := parseStatusCodes(cli.retryOnStatus)
+	if err != nil {
+		slog.Error("invalid retry status list", "err", err)
+		os.Exit(1)
+	}
+	if err := cli.backoff.validate(); err != nil {
+		slog.Error("invalid rate limit backoff", "err", err)
+		os.Exit(1)
+	}
+	client.Transport = &retryTransport{next: baseTransport(), statuses: retryStatuses, backoff: cli.backoff}
+	refreshIntervals, err := parseRefreshIntervals(cli.refreshInterval)
+	if err != nil {
+		slog.Error("invalid refresh interval", "err", err)
+		os.Exit(1)
+	}
+	if cli.cacheTTL > 0 || len(refreshIntervals) > 0 {
+		client.Transport = &cacheTransport{next: baseTransport(), ttl: cli.cacheTTL, intervals: refreshIntervals}
+	}
+	cli.config, err = resolveConfigPath(cli.config)
+	if err != nil {
+		slog.Error("failed to find config", "err", err)
+		os.Exit(1)
+	}
+	if cli.followRedirects || cli.failOnRedirect {
+		client.CheckRedirect = redirectPolicy(cli.config, cli.failOnRedirect)
+	}
+	if os.Getenv("NO_COLOR") != "" || !term.IsTerminal(int(os.Stdout.Fd())) || opts.Output != "" {
+		opts.NoColor = true
+	}
+	slog.Debug("using config", "path", cli.config)
+	configData, err := os.ReadFile(cli.config)
+	if err != nil {
+		slog.Error("failed to read config", "err", err)
+		os.Exit(1)
+	}
+	if cli.schemaValidate {
+		violations, err := validateConfigSchema(configData, cli.config)
+		if err != nil {
+			slog.Error("failed to validate config schema", "err", err)
+			os.Exit(1)
+		}
+		if len(violations) > 0 {
+			for _, violation := range violations {
+				slog.Error("config does not match the schema", "violation", violation)
+			}
+			os.Exit(1)
+		}
+	}
+	pkg, err := parseConfig(configData, cli.config)
+	if err != nil {
+		slog.Error("failed to parse config", "err", err)
+		os.Exit(1)
+	}
+	if cli.commit != "" {
+		pkg.Commit = cli.commit
+	}
+	if cli.tag != "" {
+		pkg.Tag = cli.tag
+	}
+	if cli.ignoreFileList != "" {
+		patterns, err := readIgnoreFileList(cli.ignoreFileList)
+		if err != nil {
+			slog.Error("failed to read ignore file list", "err", err)
+			os.Exit(1)
+		}
+		pkg.Ignore = append(patterns, pkg.Ignore...)
+	}
+	var invalid []error
+	for _, err := range ValidateConfig(pkg) {
+		if errors.Is(err, errNoFiles) && strings.TrimSpace(opts.Path) != "" {
+			continue
+		}
+		invalid = append(invalid, err)
+	}
+	if len(invalid) > 0 {
+		for _, err := range invalid {
+			slog.Error("invalid config", "err", err)
+		}
+		os.Exit(1)
+	}
+	ignoreRules, err = buildIgnoreRules(pkg.Ignore, cli.ignorePatterns, opts)
+	if err != nil {
+		slog.Error("invalid ignore patterns", "err", err)
+		os.Exit(1)
+	}
+	switch cli.authType {
+	case "token", "none":
+	case "bearer", "basic":
+		if providerName(pkg) != "github" {
+			slog.Error("-auth-type " + cli.authType + " is only supported for the github provider")
+			os.Exit(1)
+		}
+	default:
+		slog.Error("invalid auth type", "type", cli.authType)
+		os.Exit(1)
+	}
+	authType = cli.authType
+	var tokenSource string
+	if cli.authType == "none" {
+		tokenSource = "none, -auth-type none"
+	} else if providerName(pkg) == "github" && githubAppConfigured() {
+		opts.Token, err = setupGithubApp()
+		tokenSource = "github app"
+	} else {
+		opts.Token, tokenSource, err = lookupToken(pkg, tokenFlags{cli.token, cli.tokenFile, cli.tokenEnv})
+		if err != nil && providerName(pkg) == "github" {
+			opts.Token, err = githubTokenFallback(err)
+			tokenSource = "device flow"
+		}
+	}
+	if err == nil && cli.authType == "basic" && !strings.Contains(opts.Token, ":") {
+		err = fmt.Errorf("-auth-type basic expects the token as username:password")
+	}
+	if err != nil {
+		slog.Error("failed to authenticate", "err", err)
+		os.Exit(1)
+	}
+	slog.Info("using token", "provider", providerName(pkg), "source", tokenSource)
+	redactedToken = opts.Token
+	if pkg.Commit == "" && pkg.Tag != "" {
+		if err := validateTag(pkg, opts.Token); err != nil {
+			slog.Error("invalid tag", "err", err)
+			os.Exit(1)
+		}
+	}
+	if opts.AutoConfigureIgnore || opts.PRDescription != "" || opts.HTMLFragment != "" {
+		opts.Compare = true
+	}
+	if cli.resetState {
+		if err := os.Remove(cli.stateFile); err != nil && !os.IsNotExist(err) {
+			slog.Error("failed to reset state file", "err", err)
+			os.Exit(1)
+		}
+	}
+	if opts.Compare && !opts.Status && !cli.noState && !cli.reportsAllResults() {
+		opts.StateFile = cli.stateFile
+	}
+	if opts.HTTPDebug != "" {
+		debugLog, err := os.Create(opts.HTTPDebug)
+		if err != nil {
+			slog.Error("failed to create http debug log", "err", err)
+			os.Exit(1)
+		}
+		defer debugLog.Close()
+		client.Transport = &dumpTransport{next: baseTransport(), w: debugLog, token: opts.Token}
+		client.Transport = &debugTransport{next: baseTransport(), bodies: cli.httpDumpBodies}
+	} else if cli.httpDumpBodies {
+		slog.Error("-http-dump-bodies requires -http-debug")
+		os.Exit(1)
+	}
+	if cli.auditLog != "" {
+		if audit, err = openAuditLog(cli.auditLog); err != nil {
+			slog.Error("failed to open audit log", "err", err)
+			os.Exit(1)
+		}
+		defer audit.Close()
+	}
+	if cli.serve != "" {
+		if _, err := startMetricsServer(cli.serve); err != nil {
+			slog.Error("failed to start metrics server", "err", err)
+			os.Exit(1)
+		}
+	}
+	if cli.watch {
+		jitter := cli.watchInterval / 10
+		if cli.watchJitter != nil {
+			jitter = *cli.watchJitter
+		}
+		rerun := func() error {
+			resetRunState()
+			if err := run(cli, pkg); err != nil {
+				return err
+			}
+			if cli.watchOnChange != "" && opts.Compare {
+				return runOnChange(cli.watchOnChange, pkg, compareResults.sorted())
+			}
+			return nil
+		}
+		if opts.Compare && !opts.Status {
+			if err := watchLocalChanges(pkg, opts, cli.watchInterval, jitter, rerun); err != nil {
+				slog.Warn("file notifications unavailable, polling instead", "interval", cli.watchInterval, "err", err)
+			}
+		}
+		watchLoop(cli.watchInterval, jitter, rerun)
+	}
+	if err := run(cli, pkg); err != nil {
+		slog.Error("run failed", "err", err)
+		if cli.usesThresholds() {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+	if cli.serve != "" {
+		// keep serving the metrics of the single run until interrupted
+		select {}
+	}
+	if opts.Compare {
+		os.Exit(cli.thresholdExitCode(compareResults.sorted()))
+	}
+}
+
+// run performs one fetch or comparison and writes the reports requested
+// for it.
+func run(cli *cliFlags, pkg *Config) error {
+	opts := &cli.opts
+	if opts.StateFile != "" {
+		if err := loadState(opts.StateFile); err != nil {
+			return err
+		}
+	}
+	out, closeOutput, err := openOutput(opts)
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+	if opts.StreamOutput {
+		pendingDiffs.streamTo(opts)
+	}
+	defer pendingDiffs.discard()
+	ctx := context.Background()
+	if cli.totalTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cli.totalTimeout)
+		defer cancel()
+	}
+	if err := updateDependencies(ctx, opts, pkg); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("failed to update dependencies within -total-timeout %s: %w", cli.totalTimeout, err)
+		}
+		return fmt.Errorf("failed to update dependencies: %w", err)
+	}
+	if opts.Compare && !opts.Status && opts.DetectRenames {
+		if err := detectRenames(ctx, opts, pkg); err != nil {
+			return fmt.Errorf("failed to detect renames: %w", err)
+		}
+	}
+	if err := pendingDiffs.render(opts); err != nil {
+		return err
+	}
+	if opts.Compare && !opts.Status && opts.Format == "pretty" {
+		writeDiffStat(diffOut, compareResults.sorted())
+	}
+	if opts.Compare {
+		runMetrics.record(compareResults.all())
+	}
+	switch opts.Format {
+	case "json":
+		if err := writeJSONResults(out, compareResults.sorted()); err != nil {
+			return err
+		}
+	case "csv":
+		if err := writeCSVResults(out, compareResults.all()); err != nil {
+			return err
+		}
+	case "html":
+		if err := writeHTMLResults(out, pkg, compareResults.sorted()); err != nil {
+			return err
+		}
+	case "patch":
+		if err := patches.write(out); err != nil {
+			return err
+		}
+	case "junit":
+		if err := writeJUnitResults(out, compareResults.all()); err != nil {
+			return err
+		}
+	case "sarif":
+		if err := writeSARIFResults(out, compareResults.sorted()); err != nil {
+			return err
+		}
+	case "github-actions":
+		if err := writeGitHubAnnotations(out, compareResults.sorted()); err != nil {
+			return err
+		}
+		if err := writeStepSummary(pkg, compareResults.sorted()); err != nil {
+			return err
+		}
+	}
+	if opts.StateFile != "" {
+		if err := compareState.save(opts.StateFile, compareResults.all()); err != nil {
+			return err
+		}
+	}
+	if opts.ShellScript != "" && !opts.Compare {
+		if err := writeShellScript(opts.ShellScript, pkg, syncedFiles.sorted()); err != nil {
+			return err
+		}
+	}
+	if opts.TerraformBackend != "" && !opts.Compare {
+		if err := writeTerraformBackend(opts.TerraformBackend, pkg, syncedFiles.sorted()); err != nil {
+			return err
+		}
+	}
+	if opts.DependabotConfig != "" && !opts.Compare {
+		if err := writeDependabotConfig(opts.DependabotConfig, pkg, syncedFiles.paths()); err != nil {
+			return err
+		}
+	}
+	if opts.NginxInclude != "" && !opts.Compare {
+		if err := writeNginxInclude(opts.NginxInclude, pkg, syncedFiles.paths()); err != nil {
+			return err
+		}
+	}
+	if opts.GoEmbed != "" && !opts.Compare {
+		if err := writeGoEmbed(opts.GoEmbed, syncedFiles.paths()); err != nil {
+			return err
+		}
+	}
+	if opts.VersionsFile != "" {
+		var files []string
+		if opts.Compare {
+			for _, result := range compareResults.all() {
+				files = append(files, result.Path)
+			}
+		} else {
+			files = syncedFiles.paths()
+		}
+		if err := writeVersionsFile(ctx, opts.VersionsFile, files, opts, pkg); err != nil {
+			return err
+		}
+	}
+	if opts.DiffSQLite != "" && opts.Compare {
+		if err := writeDiffSQLite(opts.DiffSQLite, pkg, compareResults.all()); err != nil {
+			return err
+		}
+	}
+	if opts.RebaseOnto != "" && opts.Compare && !opts.Status {
+		if err := rebaseOnto(opts.RebaseOnto, pkg, patches.sorted()); err != nil {
+			return err
+		}
+	}
+	if opts.ComplianceReport != "" && opts.Compare {
+		if err := writeComplianceReport(opts.ComplianceReport, pkg, compareResults.all()); err != nil {
+			return err
+		}
+	}
+	if opts.GraphvizOutput != "" {
+		if err := fileGraph.write(opts.GraphvizOutput); err != nil {
+			return err
+		}
+	}
+	if opts.AutoConfigureIgnore {
+		noiseReport.print()
+	}
+	if opts.PRDescription != "" {
+		if err := writePRDescription(opts.PRDescription, pkg, compareResults.sorted()); err != nil {
+			return err
+		}
+	}
+	if opts.HTMLFragment != "" {
+		if err := writeDiffHTMLFragment(opts.HTMLFragment, compareResults.sorted(), opts.IncludeStyles); err != nil {
+			return err
+		}
+	}
+	if cli.notifyEmail != "" {
+		if results := compareResults.sorted(); len(results) > 0 {
+			if err := sendReportEmail(cli.smtp, cli.notifyEmail, pkg, results); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func updateDependencies(ctx context.Context, opts *Options, pkg *Config) error {
+	if strings.TrimSpace(opts.Path) != "" {
+		var wg sync.WaitGroup
+		errs := make(chan error, 1)
+
+		wg.Add(1)
+		go func(dir string) {
+			defer wg.Done()
+			if err := walkContent(ctx, dir, depsDir, opts, pkg); err != nil {
+				errs <- fmt.Errorf("failed to fetch %s: %w", dir, err)
+			}
+		}(strings.TrimSpace(opts.Path))
+
+		go func() {
+			wg.Wait()
+			close(errs)
+		}()
+
+		for err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	} else {
+		var wg sync.WaitGroup
+		errs := make(chan error, len(pkg.Files))
+
+		for _, dir := range pkg.Files {
+			wg.Add(1)
+			go func(dir string) {
+				defer wg.Done()
+				if err := walkContent(ctx, dir, depsDir, opts, pkg); err != nil {
+					errs <- fmt.Errorf("failed to fetch %s: %w", dir, err)
+				}
+			}(dir)
+		}
+
+		go func() {
+			wg.Wait()
+			close(errs)
+		}()
+
+		for err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func readIgnoreFileList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ignore file list: %w", err)
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
+
+func walkContent(ctx context.Context, path, baseDir string, opts *Options, pkgdef *Config) error {
+	if isGlobPattern(path) {
+		return fetchGlob(ctx, path, baseDir, opts, pkgdef)
+	}
+	if opts.ParallelStrategy == "breadth-first" {
+		return fetchContentBreadthFirst(ctx, path, baseDir, opts, pkgdef)
+	}
+	return fetchContent(ctx, path, baseDir, opts, pkgdef)
+}
+
+func listContents(ctx context.Context, path string, opts *Options, pkgdef *Config) ([]GithubContent, error) {
+	provider, err := newContentProvider(pkgdef, opts.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	var contents []GithubContent
+	url := provider.ListPath(path)
+	for url != "" {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		provider.Authorize(req)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unexpected status code: %d -> %s", resp.StatusCode, path)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		page, next, err := provider.DecodeList(resp, body)
+		if err != nil {
+			return nil, err
+		}
+		cacheTreeSHAs(page)
+		contents = append(contents, page...)
+		url = next
+	}
+	return contents, nil
+}
+
+func fetchFile(ctx context.Context, content GithubContent, baseDir string, opts *Options, pkgdef *Config) error {
+	if content.Sha == "" && (opts.Compare || opts.Sync) {
+		if err := resolveBlobSha(ctx, &content, opts, pkgdef); err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", content.Path, err)
+		}
+	}
+	filePath := filepath.Join(baseDir, content.Path)
+	if opts.CreateDirsOnly {
+		return createDirs(filepath.Dir(filePath))
+	}
+	if opts.ReportSymlinkTargets && opts.Compare && !opts.Status && (isSymlink(content) || isLocalSymlink(filePath)) {
+		return compareSymlink(ctx, content, filePath, opts, pkgdef)
+	}
+	if opts.Sync {
+		if localsha, err := calculateLocalSHA(filePath); err == nil && localsha == content.Sha {
+			syncedFiles.add(filePath, content.DownloadURL)
+			if opts.StreamOutput {
+				fmt.Fprintf(diffOut, "unchanged  %s\n", filePath)
+			}
+			return nil
+		}
+	}
+	if !opts.downloadsInParallel(filePath) {
+		if err := serialSem.Acquire(ctx, 1); err != nil {
+			return fmt.Errorf("failed to acquire semaphore: %w", err)
+		}
+		defer serialSem.Release(1)
+	}
+	var err error
+	if opts.DedupIdentical && !opts.Compare && content.Sha != "" {
+		err = fetchDeduplicated(ctx, content, filePath, opts, pkgdef)
+	} else if opts.ParallelDownload && !opts.Compare && content.Size > opts.ChunkThreshold {
+		err = downloadChunked(ctx, content.DownloadURL, filePath, content.Size, opts, pkgdef)
+	} else {
+		err = downloadFile(ctx, content.DownloadURL, filePath, opts, content.Sha, pkgdef)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", content.Path, err)
+	}
+	if opts.Compare && opts.ComparePermissions && content.Mode != "" {
+		if err := comparePermissions(filePath, content.Mode); err != nil {
+			return err
+		}
+	}
+	if !opts.Compare && opts.CopyPermissions && content.Mode != "" {
+		if err := copyPermissions(filePath, content.Mode); err != nil {
+			return err
+		}
+	}
+	if !opts.Compare {
+		syncedFiles.add(filePath, content.DownloadURL)
+		slog.Debug("fetched file", "path", content.Path)
+		if opts.StreamOutput {
+			fmt.Fprintf(diffOut, "fetched    %s\n", filePath)
+		}
+	}
+	return nil
+}
+
+// comparePermissions reports when the executable bit, the only permission
+// git tracks, differs between the local file and the remote mode.
+func comparePermissions(filePath, mode string) error {
+	parsed, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return fmt.Errorf("invalid mode %s for %s: %w", mode, filePath, err)
+	}
+	if parsed&0170000 != 0100000 {
+		return nil
+	}
+	info, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	local := os.FileMode(0644)
+	if info.Mode().Perm()&0111 != 0 {
+		local = 0755
+	}
+	if remote := os.FileMode(parsed & 0777); local != remote {
+		fmt.Fprintf(diffOut, "[MODE] %s: %04o → %04o\n", filePath, local, remote)
+	}
+	return nil
+}
+
+func copyPermissions(filePath, mode string) error {
+	parsed, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return fmt.Errorf("invalid mode %s for %s: %w", mode, filePath, err)
+	}
+	if parsed&0170000 != 0100000 {
+		return nil
+	}
+	if err := os.Chmod(filePath, os.FileMode(parsed&0777)); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	return nil
+}
+
+func fetchContent(ctx context.Context, path, baseDir string, opts *Options, pkgdef *Config) error {
+	contents, err := listContents(ctx, path, opts, pkgdef)
+	if err != nil {
+		return err
+	}
+	if shouldUseTreesAPI(path, contents, opts, pkgdef) {
+		return fetchWithTreesAPI(ctx, path, baseDir, opts, pkgdef)
+	}
+	fileGraph.addChildren(path, contents)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(contents))
+
+	for _, content := range contents {
+		if !checkIgnore(content.Path) {
+			wg.Add(1)
+			go func(content GithubContent) {
+				defer wg.Done()
+				switch content.Type {
+				case "dir":
+					if unchangedDir(content, baseDir, opts) {
+						return
+					}
+					if err := fetchContent(ctx, content.Path, baseDir, opts, pkgdef); err != nil {
+						errs <- err
+					}
+				case "file":
+					if err := fetchFile(ctx, content, baseDir, opts, pkgdef); err != nil {
+						errs <- err
+					}
+				case "symlink":
+					if opts.ReportSymlinkTargets && opts.Compare {
+						if err := fetchFile(ctx, content, baseDir, opts, pkgdef); err != nil {
+							errs <- err
+						}
+					}
+				}
+			}(content)
+		}
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func fetchContentBreadthFirst(ctx context.Context, path, baseDir string, opts *Options, pkgdef *Config) error {
+	level := []string{path}
+	for len(level) > 0 {
+		queue := make(chan string)
+		next := make(chan []string)
+		go func() {
+			var dirs []string
+			for dir := range queue {
+				dirs = append(dirs, dir)
+			}
+			next <- dirs
+		}()
+
+		var wg sync.WaitGroup
+		errs := make(chan error, len(level))
+
+		for _, dir := range level {
+			wg.Add(1)
+			go func(dir string) {
+				defer wg.Done()
+				contents, err := listContents(ctx, dir, opts, pkgdef)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if shouldUseTreesAPI(dir, contents, opts, pkgdef) {
+					if err := fetchWithTreesAPI(ctx, dir, baseDir, opts, pkgdef); err != nil {
+						errs <- err
+					}
+					return
+				}
+				fileGraph.addChildren(dir, contents)
+
+				var fwg sync.WaitGroup
+				ferrs := make(chan error, len(contents))
+				for _, content := range contents {
+					if checkIgnore(content.Path) {
+						continue
+					}
+					switch content.Type {
+					case "dir":
+						if !unchangedDir(content, baseDir, opts) {
+							queue <- content.Path
+						}
+					case "symlink":
+						if !opts.ReportSymlinkTargets || !opts.Compare {
+							continue
+						}
+						fallthrough
+					case "file":
+						fwg.Add(1)
+						go func(content GithubContent) {
+							defer fwg.Done()
+							if err := fetchFile(ctx, content, baseDir, opts, pkgdef); err != nil {
+								ferrs <- err
+							}
+						}(content)
+					}
+				}
+				fwg.Wait()
+				close(ferrs)
+				if err, ok := <-ferrs; ok {
+					errs <- err
+				}
+			}(dir)
+		}
+
+		wg.Wait()
+		close(queue)
+		level = <-next
+		close(errs)
+
+		for err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func getContentGitSha(ctx context.Context, sha string, token string, pkgdef *Config) (string, error) {
+	if cached, ok := blobCache.Load(sha); ok {
+		return cached.(string), nil
+	}
+	provider, err := newContentProvider(pkgdef, token)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", provider.BlobURL(sha), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	provider.Authorize(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	var blobResp BlobResponse
+	err = json.Unmarshal(body, &blobResp)
+	if err != nil {
+		return "", err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(blobResp.Content)
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
+
+func getFileContentBySHA(sha string) (string, error) {
+	cmd := exec.Command("git", "cat-file", "-p", sha)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve file content: %v, output: %s", err, output)
+	}
+	return string(output), nil
+}
+
+// DiffLine is one changed line. OldLine is its line number in the local
+// file for removed lines, NewLine its line number in the remote file for
+// added lines; the other one is zero. Indent marks a line that only differs
+// in leading whitespace, which is shown but not counted as a change.
+type DiffLine struct {
+	Kind    byte
+	Text    string
+	OldLine int
+	NewLine int
+	Indent  bool
+}
+
+func (l DiffLine) String() string {
+	if l.Indent {
+		return "[indent] " + string(l.Kind) + l.Text
+	}
+	return string(l.Kind) + l.Text
+}
+
+func formatDiff(lines []DiffLine) string {
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// leadingLines counts the lines strings.TrimSpace drops from the start of
+// content, so that line numbers refer to the untrimmed file.
+func leadingLines(content string) int {
+	return strings.Count(content[:len(content)-len(strings.TrimLeftFunc(content, unicode.IsSpace))], "\n")
+}
+
+// walkDiffLines calls fn for every line that differs once surrounding
+// whitespace is trimmed. With indent set, lines that only differ in leading
+// whitespace are reported too, untrimmed and marked as Indent.
+func walkDiffLines(content1, content2 string, indent bool, fn func(DiffLine) error) error {
+	lines1 := strings.Split(strings.TrimSpace(content1), "\n")
+	lines2 := strings.Split(strings.TrimSpace(content2), "\n")
+	offset1, offset2 := leadingLines(content1), leadingLines(content2)
+
+	maxLen := len(lines1)
+	if len(lines2) > maxLen {
+		maxLen = len(lines2)
+	}
+
+	for i := 0; i < maxLen; i++ {
+		raw1, raw2 := "", ""
+		if i < len(lines1) {
+			raw1 = lines1[i]
+		}
+		if i < len(lines2) {
+			raw2 = lines2[i]
+		}
+		if err := walkLinePair(raw1, raw2, offset1+i+1, offset2+i+1, indent, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkLinePair calls fn for the difference between two lines found at the
+// same position, raw1 and raw2 being "" past the end of their file.
+func walkLinePair(raw1, raw2 string, oldLine, newLine int, indent bool, fn func(DiffLine) error) error {
+	line1, line2 := strings.TrimSpace(raw1), strings.TrimSpace(raw2)
+	if line1 == line2 {
+		if !indent || line1 == "" {
+			return nil
+		}
+		raw1 = strings.TrimRightFunc(raw1, unicode.IsSpace)
+		raw2 = strings.TrimRightFunc(raw2, unicode.IsSpace)
+		if raw1 == raw2 {
+			return nil
+		}
+		if err := fn(DiffLine{Kind: '-', Text: raw1, OldLine: oldLine, Indent: true}); err != nil {
+			return err
+		}
+		return fn(DiffLine{Kind: '+', Text: raw2, NewLine: newLine, Indent: true})
+	}
+	if line1 != "" {
+		if err := fn(DiffLine{Kind: '-', Text: line1, OldLine: oldLine}); err != nil {
+			return err
+		}
+	}
+	if line2 != "" {
+		return fn(DiffLine{Kind: '+', Text: line2, NewLine: newLine})
+	}
+	return nil
+}
+
+func semanticChanges(filePath, local, remote string, opts *Options) ([]string, bool) {
+	if opts.CompareProto && isProtoFile(filePath) {
+		changes, err := compareProto(local, remote, filePath)
+		if err != nil {
+			slog.Warn("falling back to text diff", "err", err)
+			return nil, false
+		}
+		return changes, true
+	}
+	if opts.CompareOpenAPI && isOpenAPIFile(filePath) {
+		changes, err := compareOpenAPI(local, remote, filePath)
+		if err != nil {
+			slog.Warn("falling back to text diff", "err", err)
+			return nil, false
+		}
+		return changes, true
+	}
+	if opts.CompareKubernetes && (isKubernetesManifest(filePath, local) || isKubernetesManifest(filePath, remote)) {
+		changes, err := compareKubernetes(local, remote, filePath)
+		if err != nil {
+			slog.Warn("falling back to text diff", "err", err)
+			return nil, false
+		}
+		return changes, true
+	}
+	if opts.CompareDocker && isDockerfile(filePath) {
+		changes, err := compareDockerfile(local, remote, filePath)
+		if err != nil {
+			slog.Warn("falling back to text diff", "err", err)
+			return nil, false
+		}
+		return changes, true
+	}
+	return nil, false
+}
+
+func reportSemanticChanges(filePath, localSha, remoteSha string, changes []string) {
+	if len(changes) == 0 {
+		recordEqual(filePath, localSha, remoteSha)
+		return
+	}
+	recordDifference(filePath, localSha, remoteSha, len(changes), strings.Join(changes, "\n"))
+	slog.Info("schema changes", "path", filePath, "changes", len(changes))
+	for _, change := range changes {
+		fmt.Fprintln(diffOut, change)
+	}
+}
+
+var whitespaceRun = regexp.MustCompile(`\s+`)
+
+// collapseWhitespace replaces every run of whitespace within a line with a
+// single space. Line breaks are kept so that line numbers stay valid.
+func collapseWhitespace(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		lines[i] = whitespaceRun.ReplaceAllString(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func normalizeContents(filePath, local, remote string, opts *Options) (string, string) {
+	if opts.IgnoreVersionComments {
+		local = blankVersionComments(local, opts.VersionComments, opts.VersionCommentLines)
+		remote = blankVersionComments(remote, opts.VersionComments, opts.VersionCommentLines)
+	}
+	if opts.NormalizeWhitespace {
+		local = collapseWhitespace(local)
+		remote = collapseWhitespace(remote)
+	}
+	if opts.CompareNullTolerant && isJSONFile(filePath) {
+		strippedLocal, err := dropJSONNulls(local, filePath)
+		if err != nil {
+			slog.Warn("falling back to text diff", "err", err)
+			return local, remote
+		}
+		strippedRemote, err := dropJSONNulls(remote, filePath)
+		if err != nil {
+			slog.Warn("falling back to text diff", "err", err)
+			return local, remote
+		}
+		return strippedLocal, strippedRemote
+	}
+	if opts.CompareTerraform && isTerraformFile(filePath) {
+		canonicalLocal, err := canonicalizeTerraform(local, filePath)
+		if err != nil {
+			slog.Warn("falling back to text diff", "err", err)
+			return local, remote
+		}
+		canonicalRemote, err := canonicalizeTerraform(remote, filePath)
+		if err != nil {
+			slog.Warn("falling back to text diff", "err", err)
+			return local, remote
+		}
+		return canonicalLocal, canonicalRemote
+	}
+	if opts.CompareAsTemplate && isGoTemplateFile(filePath) {
+		canonicalLocal, err := canonicalizeGoTemplate(local, filePath)
+		if err != nil {
+			slog.Warn("falling back to text diff", "err", err)
+			return local, remote
+		}
+		canonicalRemote, err := canonicalizeGoTemplate(remote, filePath)
+		if err != nil {
+			slog.Warn("falling back to text diff", "err", err)
+			return local, remote
+		}
+		return canonicalLocal, canonicalRemote
+	}
+	return local, remote
+}
+
+func calculateLocalSHA(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	header_size := fmt.Sprintf("blob %d\x00", info.Size())
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	store := append([]byte(header_size), content...)
+	hash_store := sha1.New()
+	hash_store.Write(store)
+
+	return hex.EncodeToString(hash_store.Sum(nil)), nil
+}
+
+func downloadFile(ctx context.Context, url, filePath string, opts *Options, gitsha string, pkgdef *Config) (err error) {
+	if err := sem.Acquire(ctx, 1); err != nil {
+		return fmt.Errorf("failed to acquire semaphore: %w", err)
+	}
+	defer sem.Release(1)
+
+	if audit != nil {
+		op := "download"
+		if opts.Compare {
+			op = "compare"
+		}
+		localSha, _ := calculateLocalSHA(filePath)
+		defer func() {
+			if auditErr := audit.record(op, filePath, localSha, gitsha, err); err == nil {
+				err = auditErr
+			}
+		}()
+	}
+
+	if opts.Status {
+		return statusFile(filePath, gitsha)
+	}
+	if opts.Compare {
+		return compareFile(ctx, filePath, gitsha, opts, pkgdef)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	provider, err := newContentProvider(pkgdef, opts.Token)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	provider.Authorize(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	out, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
+
+func compareFile(ctx context.Context, filePath, gitsha string, opts *Options, pkgdef *Config) error {
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		recordMissing(filePath, gitsha)
+		if opts.collectsPatches() {
+			remote, err := getContentGitSha(ctx, gitsha, opts.Token, pkgdef)
+			if err != nil {
+				return err
+			}
+			patches.add(filePath, unifiedPatch(filePath, "", remote, true, opts.DiffContextSeparator))
+		}
+		return nil
+	}
+	localsha, err := calculateLocalSHA(filePath)
+	if err != nil {
+		return err
+	}
+	if compareState.unchanged(filePath, localsha, gitsha) {
+		recordEqual(filePath, localsha, gitsha)
+		return nil
+	}
+	if localsha == gitsha {
+		recordEqual(filePath, localsha, gitsha)
+		return nil
+	}
+	if opts.showsDiffs() && !opts.collectsPatches() && opts.Differ == nil && exceedsStreamThreshold(filePath, opts) {
+		return streamDiff(ctx, filePath, localsha, gitsha, opts, pkgdef)
+	}
+
+	shalocal, err := getFileContentBySHA(localsha)
+	if err != nil {
+		return fmt.Errorf("failed to read local %s: %w", filePath, err)
+	}
+	shagit, err := getContentGitSha(ctx, gitsha, opts.Token, pkgdef)
+	if err != nil {
+		return fmt.Errorf("failed to read remote %s: %w", filePath, err)
+	}
+	if opts.collectsPatches() {
+		local, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		patches.add(filePath, unifiedPatch(filePath, string(local), shagit, false, opts.DiffContextSeparator))
+	}
+	if opts.ValidateUTF8 && reportInvalidUTF8(filePath, shalocal, shagit) {
+		return nil
+	}
+	if changes, ok := semanticChanges(filePath, shalocal, shagit, opts); ok {
+		reportSemanticChanges(filePath, localsha, gitsha, changes)
+		return nil
+	}
+	shalocal, shagit = normalizeContents(filePath, shalocal, shagit, opts)
+	output := opts.differ().Diff(shalocal, shagit)
+	diffLines := output.Lines()
+	diff := formatDiff(diffLines)
+	added, removed := output.Stats.Added, output.Stats.Removed
+	totalDiffs := added + removed
+	if opts.AutoConfigureIgnore {
+		noiseReport.record(filePath, diff, totalDiffs)
+	}
+	if totalDiffs == 0 {
+		recordEqual(filePath, localsha, gitsha)
+		if len(diffLines) > 0 && opts.showsDiffs() {
+			pendingDiffs.add(diffBlock{Path: filePath, LocalSha: localsha, RemoteSha: gitsha, Lines: diffLines})
+		}
+		return nil
+	}
+	similar := similarity(shalocal, shagit, totalDiffs)
+	recordLineDifference(filePath, localsha, gitsha, added, removed, similar, diff)
+	if !opts.similarityShown(similar) {
+		return nil
+	}
+	slog.Info("differences", "path", filePath, "changes", totalDiffs)
+	slog.Debug("similarity", "path", filePath, "similarity", fmt.Sprintf("%.0f%%", similar), "changes", totalDiffs)
+	if opts.showsDiffs() {
+		pendingDiffs.add(diffBlock{Path: filePath, LocalSha: localsha, RemoteSha: gitsha, Lines: diffLines})
+	}
+	return nil
+}
+
+func statusFile(filePath, gitsha string) error {
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		recordMissing(filePath, gitsha)
+		fmt.Fprintf(diffOut, "missing    %s\n", filePath)
+		return nil
+	}
+	localsha, err := calculateLocalSHA(filePath)
+	if err != nil {
+		return err
+	}
+	if localsha == gitsha {
+		recordEqual(filePath, localsha, gitsha)
+		fmt.Fprintf(diffOut, "unchanged  %s\n", filePath)
+		return nil
+	}
+	recordDifference(filePath, localsha, gitsha, 0, "")
+	fmt.Fprintf(diffOut, "changed    %s\n", filePath)
+	return nil
+}
+
+// renderDiff writes header followed by lines. header becomes a markdown
+// heading above the glamour rendered diff and a plain line otherwise.
+func renderDiff(header string, lines []DiffLine, opts *Options) error {
+	if opts.SideBySide || opts.LineNumbers || opts.NoColor {
+		fmt.Fprintln(diffOut, header)
+	}
+	if opts.SideBySide {
+		return renderSideBySide(lines, opts)
+	}
+	if opts.LineNumbers {
+		renderNumberedDiff(lines, opts.NoColor)
+		return nil
+	}
+	if opts.NoColor {
+		for _, line := range lines {
+			fmt.Fprintln(diffOut, line)
+		}
+		return nil
+	}
+	var markdownBuilder strings.Builder
+	markdownBuilder.WriteString(header + "\n\n")
+	markdownBuilder.WriteString("```diff\n")
+	for _, line := range lines {
+		markdownBuilder.WriteString(fmt.Sprintf("%s\n", line))
+	}
+	markdownBuilder.WriteString("```\n")
+	r, _ := glamour.NewTermRenderer(
+		glamour.WithStandardStyle("dark"),
+		glamour.WithWordWrap(80),
+		glamour.WithColorProfile(termenv.Profile(0)),
+	)
+	out, err := r.Render(markdownBuilder.String())
+	if err != nil {
+		return fmt.Errorf("failed to render diff: %w", err)
+	}
+
+	fmt.Fprint(diffOut, out)
+	return nil
+}
+
+// renderNumberedDiff prefixes every line with its line number in the file
+// it belongs to, dimmed unless noColor is set.
+func renderNumberedDiff(lines []DiffLine, noColor bool) {
+	for _, line := range lines {
+		number, color := line.OldLine, ansiRed
+		if line.Kind == '+' {
+			number, color = line.NewLine, ansiGreen
+		}
+		if line.Indent {
+			color = ansiDim
+		}
+		prefix := fmt.Sprintf("L%d: ", number)
+		if noColor {
+			fmt.Fprintf(diffOut, "%s%s\n", prefix, line)
+			continue
+		}
+		fmt.Fprintf(diffOut, "%s%s\n", colorize(prefix, ansiDim, false), colorize(line.String(), color, false))
+	}
+}
@@ -0,0 +1,232 @@
+package comparegitfiles
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestMain runs Main instead of the tests when runMain executes the test
+// binary again, with the github api at COMPAREGITFILES_TEST_API.
+func TestMain(m *testing.M) {
+	if api, ok := os.LookupEnv("COMPAREGITFILES_TEST_API"); ok {
+		githubAPI = api
+		Main(os.Args[1:])
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMain runs the command line args in dir against the github api at api
+// and returns its stdout, stderr and exit code.
+func runMain(t *testing.T, dir, api string, args ...string) (string, string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "COMPAREGITFILES_TEST_API="+api, "GITHUB_TOKEN=secret")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	var exitErr *exec.ExitError
+	if err := cmd.Run(); err != nil && !errors.As(err, &exitErr) {
+		t.Fatal(err)
+	}
+	return stdout.String(), stderr.String(), cmd.ProcessState.ExitCode()
+}
+
+// chdir changes the working directory to dir until the test ends, as the
+// files are compared and fetched relative to it.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// localRepo writes files into a new git repository, adds them so that their
+// blobs can be read back with git cat-file, and changes into it.
+func localRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	chdir(t, dir)
+	for path, content := range files {
+		writeFile(t, filepath.Join(dir, path), content)
+	}
+	for _, args := range [][]string{{"init", "-q"}, {"add", "-A"}} {
+		if _, err := git("", args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeGitHub serves the contents, blobs, tag refs and commits endpoints of
+// the github repository owner/repo holding files, and records the requests
+// it receives.
+type fakeGitHub struct {
+	URL   string
+	files map[string]string
+	tags  []string
+
+	mu       sync.Mutex
+	requests []*url.URL
+}
+
+// newFakeGitHub starts a fakeGitHub holding files and sends the github api
+// requests to it until the test ends.
+func newFakeGitHub(t *testing.T, files map[string]string, tags ...string) *fakeGitHub {
+	t.Helper()
+	f := &fakeGitHub{files: files, tags: tags}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	f.URL = srv.URL
+
+	saved := githubAPI
+	githubAPI = srv.URL
+	t.Cleanup(func() { githubAPI = saved })
+	return f
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, r.URL)
+	f.mu.Unlock()
+
+	const repo = "/repos/owner/repo/"
+	switch path := strings.TrimPrefix(r.URL.Path, repo); {
+	case strings.HasPrefix(r.URL.Path, "/raw/"):
+		content, ok := f.files[strings.TrimPrefix(r.URL.Path, "/raw/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	case !strings.HasPrefix(r.URL.Path, repo):
+		http.NotFound(w, r)
+	case strings.HasPrefix(path, "contents/"):
+		f.serveContents(w, r, strings.Trim(strings.TrimPrefix(path, "contents/"), "/"))
+	case strings.HasPrefix(path, "git/blobs/"):
+		sha := strings.TrimPrefix(path, "git/blobs/")
+		for _, content := range f.files {
+			if blobSha([]byte(content)) == sha {
+				json.NewEncoder(w).Encode(map[string]string{
+					"sha":      sha,
+					"content":  base64.StdEncoding.EncodeToString([]byte(content)),
+					"encoding": "base64",
+				})
+				return
+			}
+		}
+		http.NotFound(w, r)
+	case strings.HasPrefix(path, "git/ref/tags/"):
+		tag := strings.TrimPrefix(path, "git/ref/tags/")
+		if !slices.Contains(f.tags, tag) {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"ref": "refs/tags/" + tag})
+	case path == "commits":
+		json.NewEncoder(w).Encode([]map[string]string{{"sha": "c0ffee"}})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeGitHub) serveContents(w http.ResponseWriter, r *http.Request, path string) {
+	if content, ok := f.files[path]; ok {
+		json.NewEncoder(w).Encode(f.entry(path, content))
+		return
+	}
+	var entries []GithubContent
+	seen := make(map[string]bool)
+	for name, content := range f.files {
+		rest, ok := strings.CutPrefix(name, path+"/")
+		if !ok {
+			continue
+		}
+		child, _, isDir := strings.Cut(rest, "/")
+		child = path + "/" + child
+		if seen[child] {
+			continue
+		}
+		seen[child] = true
+		if isDir {
+			entries = append(entries, GithubContent{Name: filepath.Base(child), Path: child, Type: "dir"})
+		} else {
+			entries = append(entries, f.entry(child, content))
+		}
+	}
+	if len(entries) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(entries)
+}
+
+func (f *fakeGitHub) entry(path, content string) GithubContent {
+	return GithubContent{
+		Name:        filepath.Base(path),
+		Path:        path,
+		Type:        "file",
+		Sha:         blobSha([]byte(content)),
+		Size:        int64(len(content)),
+		DownloadURL: f.URL + "/raw/" + path,
+	}
+}
+
+// requested returns the urls requested so far whose path starts with prefix.
+func (f *fakeGitHub) requested(prefix string) []*url.URL {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var urls []*url.URL
+	for _, u := range f.requests {
+		if strings.HasPrefix(u.Path, prefix) {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// newCompareRepo serves remote from a fake github api and changes into a git
+// repository holding local, with a diffs.json comparing files.
+func newCompareRepo(t *testing.T, remote, local map[string]string, files ...string) (*fakeGitHub, string) {
+	t.Helper()
+	gh := newFakeGitHub(t, remote)
+	dir := localRepo(t, local)
+	writeFile(t, filepath.Join(dir, "diffs.json"), mustJSON(t, Config{Name: "owner/repo", Files: files}))
+	return gh, dir
+}
@@ -0,0 +1,94 @@
+package comparegitfiles
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var runMetrics = &metricsSnapshot{}
+
+// metricsSnapshot keeps the results of the last comparison for the
+// -serve endpoint, which may be scraped while the next run is going on.
+type metricsSnapshot struct {
+	mu      sync.Mutex
+	results []DiffResult
+	lastRun time.Time
+}
+
+func (m *metricsSnapshot) record(results []DiffResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.results = results
+	m.lastRun = time.Now()
+}
+
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// write renders the snapshot in the Prometheus text exposition format.
+func (m *metricsSnapshot) write(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var changed, identical int
+	for _, result := range m.results {
+		if result.Status == statusIdentical {
+			identical++
+		} else {
+			changed++
+		}
+	}
+	var lastRun float64
+	if !m.lastRun.IsZero() {
+		lastRun = float64(m.lastRun.UnixNano()) / 1e9
+	}
+
+	var b strings.Builder
+	gauge := func(name, help string, value float64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", name, help, name, name, strconv.FormatFloat(value, 'f', -1, 64))
+	}
+	gauge("comparegitfiles_files_changed_total", "Files that differ from the remote or are missing locally in the last run.", float64(changed))
+	gauge("comparegitfiles_files_identical_total", "Files that match the remote in the last run.", float64(identical))
+	gauge("comparegitfiles_last_run_timestamp", "Unix time the last run finished, 0 before the first run.", lastRun)
+	b.WriteString("# HELP comparegitfiles_file_diff_lines Changed lines per file in the last run.\n")
+	b.WriteString("# TYPE comparegitfiles_file_diff_lines gauge\n")
+	for _, result := range m.results {
+		fmt.Fprintf(&b, "comparegitfiles_file_diff_lines{path=\"%s\"} %d\n", metricLabelEscaper.Replace(result.Path), result.Changes)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// startMetricsServer listens on addr before returning, so that a busy port
+// fails the command, and serves /metrics and /healthz in the background
+// until the returned listener is closed.
+func startMetricsServer(addr string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := runMetrics.write(w); err != nil {
+			slog.Error("failed to write metrics", "err", err)
+		}
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "ok\n")
+	})
+	slog.Info("serving metrics", "url", fmt.Sprintf("http://%s/metrics", listener.Addr()))
+	go func() {
+		if err := http.Serve(listener, mux); err != nil && !errors.Is(err, net.ErrClosed) {
+			slog.Error("metrics server stopped", "err", err)
+		}
+	}()
+	return listener, nil
+}
@@ -0,0 +1,98 @@
+package comparegitfiles
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetricsServer(t *testing.T) {
+	saved := runMetrics
+	runMetrics = &metricsSnapshot{}
+	t.Cleanup(func() { runMetrics = saved })
+
+	listener, err := startMetricsServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	base := "http://" + listener.Addr().String()
+
+	if _, err := startMetricsServer(listener.Addr().String()); err == nil {
+		t.Error("startMetricsServer on a busy port succeeded")
+	}
+
+	get := func(path string) string {
+		t.Helper()
+		resp, err := http.Get(base + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: status %d", path, resp.StatusCode)
+		}
+		return string(body)
+	}
+
+	if body := get("/healthz"); body != "ok\n" {
+		t.Errorf("/healthz = %q, want ok", body)
+	}
+
+	tests := []struct {
+		name    string
+		results []DiffResult
+		want    []string
+		absent  []string
+	}{
+		{
+			name: "before the first run",
+			want: []string{
+				"# TYPE comparegitfiles_files_changed_total gauge\ncomparegitfiles_files_changed_total 0\n",
+				"comparegitfiles_files_identical_total 0\n",
+				"comparegitfiles_last_run_timestamp 0\n",
+			},
+			absent: []string{"comparegitfiles_file_diff_lines{"},
+		},
+		{
+			name: "after a run",
+			results: []DiffResult{
+				{Path: "conf/app.yaml", Status: statusChanged, Changes: 2},
+				{Path: "conf/new.yaml", Status: statusMissingLocal},
+				{Path: "conf/same.yaml", Status: statusIdentical},
+				{Path: "conf/\"quoted\"\\.yaml", Status: statusChanged, Changes: 1},
+			},
+			want: []string{
+				"comparegitfiles_files_changed_total 3\n",
+				"comparegitfiles_files_identical_total 1\n",
+				`comparegitfiles_file_diff_lines{path="conf/app.yaml"} 2` + "\n",
+				`comparegitfiles_file_diff_lines{path="conf/same.yaml"} 0` + "\n",
+				`comparegitfiles_file_diff_lines{path="conf/\"quoted\"\\.yaml"} 1` + "\n",
+			},
+			absent: []string{"comparegitfiles_last_run_timestamp 0\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.results != nil {
+				runMetrics.record(tt.results)
+			}
+			body := get("/metrics")
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("/metrics does not contain %q:\n%s", want, body)
+				}
+			}
+			for _, absent := range tt.absent {
+				if strings.Contains(body, absent) {
+					t.Errorf("/metrics contains %q:\n%s", absent, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeNginxInclude writes one include directive per synced .conf file that
+// exists locally. Paths are absolute since nginx resolves relative includes
+// against its own configuration prefix.
+func writeNginxInclude(output string, pkgdef *Config, files []string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by comparegitfiles -write-nginx-include for the files synced from %s.\n", pkgdef.Name)
+	absOutput, err := filepath.Abs(output)
+	if err != nil {
+		return err
+	}
+	count := 0
+	for _, file := range files {
+		if filepath.Ext(file) != ".conf" {
+			continue
+		}
+		if info, err := os.Stat(file); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		if abs == absOutput {
+			continue
+		}
+		fmt.Fprintf(&b, "include %s;\n", nginxQuote(abs))
+		count++
+	}
+	if count == 0 {
+		slog.Warn("no synced .conf files to include", "path", output)
+	}
+
+	if dir := filepath.Dir(output); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to write nginx include: %w", err)
+		}
+	}
+	if err := os.WriteFile(output, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write nginx include: %w", err)
+	}
+	slog.Info("nginx include written", "path", output, "files", count)
+	return nil
+}
+
+// nginxQuote quotes a path that nginx would otherwise split or treat as the
+// end of the directive.
+func nginxQuote(path string) string {
+	if !strings.ContainsAny(path, " \t\n;{}\"'#\\") {
+		return path
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(path) + `"`
+}
@@ -0,0 +1,97 @@
+package comparegitfiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const smallChangeLines = 2
+
+var (
+	timestampPattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}([T ]\d{2}:\d{2}(:\d{2})?)?|\d{2}:\d{2}:\d{2}`)
+	commentPrefixes  = []string{"//", "#", "/*", "*", "<!--", "-->", "--", ";"}
+	noiseReport      = &noiseAnalyzer{}
+)
+
+type noiseAnalyzer struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (n *noiseAnalyzer) record(filePath, diff string, totalDiffs int) {
+	if !isNoisyDiff(diff, totalDiffs) {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.paths = append(n.paths, filepath.ToSlash(filepath.Clean(filePath)))
+}
+
+func isNoisyDiff(diff string, totalDiffs int) bool {
+	if totalDiffs == 0 {
+		return false
+	}
+	if totalDiffs <= smallChangeLines {
+		return true
+	}
+	commentOnly, timestampOnly := true, true
+	for _, line := range strings.Split(diff, "\n") {
+		if !strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "-") {
+			continue
+		}
+		text := strings.TrimSpace(line[1:])
+		if !hasCommentPrefix(text) {
+			commentOnly = false
+		}
+		if !timestampPattern.MatchString(text) {
+			timestampOnly = false
+		}
+	}
+	return commentOnly || timestampOnly
+}
+
+func hasCommentPrefix(line string) bool {
+	for _, prefix := range commentPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *noiseAnalyzer) suggestions() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	groups := make(map[string][]string)
+	for _, p := range n.paths {
+		key := path.Join(path.Dir(p), "*"+path.Ext(p))
+		groups[key] = append(groups[key], p)
+	}
+	var patterns []string
+	for key, paths := range groups {
+		if len(paths) > 1 {
+			patterns = append(patterns, key)
+		} else {
+			patterns = append(patterns, paths[0])
+		}
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+func (n *noiseAnalyzer) print() {
+	patterns := n.suggestions()
+	if len(patterns) == 0 {
+		fmt.Println("No noisy files found, no ignore patterns to suggest")
+		return
+	}
+	encoded, _ := json.Marshal(patterns)
+	fmt.Printf("Consider adding to ignore: %s\n", encoded)
+}
@@ -0,0 +1,108 @@
+package comparegitfiles
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+type smtpConfig struct {
+	Host string
+	Port int
+	User string
+	Pass string
+	TLS  bool
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<h2>Upstream comparison of <code>{{.Name}}@{{.Ref}}</code></h2>
+{{if .Results}}<table>
+<tr><th align="left">File</th><th align="right">Changed lines</th></tr>
+{{range .Results}}<tr><td><code>{{.Path}}</code></td><td align="right">{{.Changes}}</td></tr>
+{{end}}</table>
+<p>{{len .Results}} files differ.</p>
+{{else}}<p>No changed files.</p>
+{{end}}`))
+
+func renderHTMLReport(w io.Writer, pkgdef *Config, results []DiffResult) error {
+	ref := resolveRef(pkgdef)
+	if ref == "" {
+		ref = "HEAD"
+	}
+	return htmlReportTemplate.Execute(w, struct {
+		Name    string
+		Ref     string
+		Results []DiffResult
+	}{pkgdef.Name, ref, results})
+}
+
+// sendReportEmail mails the HTML report to addr. With cfg.TLS the connection
+// uses implicit TLS, otherwise STARTTLS is negotiated when the server offers it.
+func sendReportEmail(cfg smtpConfig, addr string, pkgdef *Config, results []DiffResult) error {
+	from := cfg.User
+	if !strings.Contains(from, "@") {
+		from = "comparegitfiles@" + cfg.Host
+	}
+
+	var body bytes.Buffer
+	if err := renderHTMLReport(&body, pkgdef, results); err != nil {
+		return fmt.Errorf("failed to render email: %w", err)
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", addr)
+	fmt.Fprintf(&msg, "Subject: [comparegitfiles] %d files differ in %s\r\n", len(results), pkgdef.Name)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=UTF-8\r\n\r\n")
+	msg.Write(body.Bytes())
+
+	var auth smtp.Auth
+	if cfg.User != "" {
+		auth = smtp.PlainAuth("", cfg.User, cfg.Pass, cfg.Host)
+	}
+	server := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	if !cfg.TLS {
+		if err := smtp.SendMail(server, auth, from, []string{addr}, msg.Bytes()); err != nil {
+			return fmt.Errorf("failed to send email: %w", err)
+		}
+		return nil
+	}
+
+	conn, err := tls.Dial("tcp", server, &tls.Config{ServerName: cfg.Host})
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", server, err)
+	}
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to %s: %w", server, err)
+	}
+	defer c.Close()
+	if auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if err := c.Rcpt(addr); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return c.Quit()
+}
@@ -0,0 +1,152 @@
+package comparegitfiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi2"
+	"github.com/getkin/kin-openapi/openapi2conv"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/oasdiff/yaml"
+)
+
+func isOpenAPIFile(path string) bool {
+	switch strings.ToLower(filepath.Base(path)) {
+	case "openapi.yaml", "openapi.yml", "openapi.json", "swagger.yaml", "swagger.yml", "swagger.json":
+		return true
+	}
+	return false
+}
+
+func loadOpenAPI(data []byte, filename string) (*openapi3.T, error) {
+	var probe struct {
+		Swagger string `json:"swagger"`
+	}
+	if err := yaml.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	if strings.HasPrefix(probe.Swagger, "2") {
+		var doc openapi2.T
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+		}
+		converted, err := openapi2conv.ToV3(&doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s: %w", filename, err)
+		}
+		return converted, nil
+	}
+	doc, err := openapi3.NewLoader().LoadFromData(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	return doc, nil
+}
+
+func openAPIOperations(doc *openapi3.T) map[string]*openapi3.Operation {
+	operations := make(map[string]*openapi3.Operation)
+	if doc.Paths == nil {
+		return operations
+	}
+	for path, item := range doc.Paths.Map() {
+		for method, operation := range item.Operations() {
+			operations[method+" "+path] = operation
+		}
+	}
+	return operations
+}
+
+func jsonEqual(a, b interface{}) bool {
+	left, errLeft := json.Marshal(a)
+	right, errRight := json.Marshal(b)
+	return errLeft == nil && errRight == nil && string(left) == string(right)
+}
+
+func sortedKeys[V any](maps ...map[string]V) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range maps {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func compareOpenAPI(local, remote, filename string) ([]string, error) {
+	before, err := loadOpenAPI([]byte(local), filename)
+	if err != nil {
+		return nil, err
+	}
+	after, err := loadOpenAPI([]byte(remote), filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []string
+	oldOps, newOps := openAPIOperations(before), openAPIOperations(after)
+	for _, endpoint := range sortedKeys(oldOps, newOps) {
+		oldOp, hadOp := oldOps[endpoint]
+		newOp, hasOp := newOps[endpoint]
+		switch {
+		case !hasOp:
+			changes = append(changes, fmt.Sprintf("[BREAKING] endpoint %s removed", endpoint))
+		case !hadOp:
+			changes = append(changes, fmt.Sprintf("[ADDITIVE] endpoint %s added", endpoint))
+		default:
+			if !jsonEqual(oldOp.Parameters, newOp.Parameters) || !jsonEqual(oldOp.RequestBody, newOp.RequestBody) {
+				changes = append(changes, fmt.Sprintf("[BREAKING] request schema changed for %s", endpoint))
+			}
+			if !jsonEqual(oldOp.Responses, newOp.Responses) {
+				changes = append(changes, fmt.Sprintf("[BREAKING] response schema changed for %s", endpoint))
+			}
+			if !jsonEqual(oldOp.Security, newOp.Security) {
+				changes = append(changes, fmt.Sprintf("[BREAKING] authentication changed for %s", endpoint))
+			}
+		}
+	}
+
+	var oldSchemas, newSchemas openapi3.Schemas
+	var oldSchemes, newSchemes openapi3.SecuritySchemes
+	if before.Components != nil {
+		oldSchemas, oldSchemes = before.Components.Schemas, before.Components.SecuritySchemes
+	}
+	if after.Components != nil {
+		newSchemas, newSchemes = after.Components.Schemas, after.Components.SecuritySchemes
+	}
+	for _, name := range sortedKeys(oldSchemas, newSchemas) {
+		oldSchema, hadSchema := oldSchemas[name]
+		newSchema, hasSchema := newSchemas[name]
+		switch {
+		case !hasSchema:
+			changes = append(changes, fmt.Sprintf("[BREAKING] schema %s removed", name))
+		case !hadSchema:
+			changes = append(changes, fmt.Sprintf("[ADDITIVE] schema %s added", name))
+		case !jsonEqual(oldSchema, newSchema):
+			changes = append(changes, fmt.Sprintf("[BREAKING] schema %s changed", name))
+		}
+	}
+	for _, name := range sortedKeys(oldSchemes, newSchemes) {
+		oldScheme, hadScheme := oldSchemes[name]
+		newScheme, hasScheme := newSchemes[name]
+		switch {
+		case !hasScheme:
+			changes = append(changes, fmt.Sprintf("[BREAKING] security scheme %s removed", name))
+		case !hadScheme:
+			changes = append(changes, fmt.Sprintf("[ADDITIVE] security scheme %s added", name))
+		case !jsonEqual(oldScheme, newScheme):
+			changes = append(changes, fmt.Sprintf("[BREAKING] security scheme %s modified", name))
+		}
+	}
+	if !jsonEqual(before.Security, after.Security) {
+		changes = append(changes, "[BREAKING] global security requirements changed")
+	}
+	return changes, nil
+}
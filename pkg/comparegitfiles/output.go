@@ -0,0 +1,207 @@
+package comparegitfiles
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// diffOut receives diffs, schema changes and status lines. It points at the
+// -output file for the duration of a run; progress and log lines are not
+// affected.
+var diffOut io.Writer = os.Stdout
+
+// openOutput points diffOut at the destination chosen by -output and
+// -format and returns the writer for the final report together with a
+// function that restores stdout. With any -format other than pretty the
+// per-file output is dropped so that only the report is written.
+func openOutput(opts *Options) (io.Writer, func(), error) {
+	out := io.Writer(os.Stdout)
+	closeFile := func() {}
+	if opts.Output != "" {
+		file, err := os.Create(opts.Output)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create output file: %w", err)
+		}
+		out = file
+		closeFile = func() { file.Close() }
+	}
+	diffOut = out
+	if opts.Format != "pretty" {
+		diffOut = io.Discard
+	}
+	return out, func() {
+		diffOut = os.Stdout
+		closeFile()
+	}, nil
+}
+
+// diffBlock is the diff of one file, queued until all files are compared so
+// that blocks are rendered in path order instead of interleaving.
+type diffBlock struct {
+	Path      string
+	LocalSha  string
+	RemoteSha string
+	Lines     []DiffLine
+	// Spill is the temporary file holding the lines of a streamed diff in
+	// place of Lines, one JSON encoded DiffLine each, and Changes their
+	// count. It is read back when the block is rendered and then removed.
+	Spill   string
+	Changes int
+}
+
+func (b diffBlock) changes() int {
+	if b.Spill != "" {
+		return b.Changes
+	}
+	return len(b.Lines)
+}
+
+func (b diffBlock) header(verbose bool) string {
+	if verbose {
+		return fmt.Sprintf("### %s (%d changes, %s → %s)", b.Path, b.changes(), shortSHA(b.LocalSha), shortSHA(b.RemoteSha))
+	}
+	return fmt.Sprintf("### %s (%d changes)", b.Path, b.changes())
+}
+
+// lines returns the lines of the block, reading them from Spill for a
+// streamed diff.
+func (b diffBlock) lines() ([]DiffLine, error) {
+	if b.Spill == "" {
+		return b.Lines, nil
+	}
+	file, err := os.Open(b.Spill)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read streamed diff of %s: %w", b.Path, err)
+	}
+	defer file.Close()
+	lines := make([]DiffLine, 0, b.Changes)
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for {
+		var line DiffLine
+		if err := decoder.Decode(&line); err == io.EOF {
+			return lines, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read streamed diff of %s: %w", b.Path, err)
+		}
+		lines = append(lines, line)
+	}
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+var pendingDiffs = &diffQueue{}
+
+// diffQueue holds the diff blocks until render, unless streaming is enabled
+// with -stream-output: then add renders each block right away and the mutex
+// keeps blocks of concurrent files from interleaving.
+type diffQueue struct {
+	mu     sync.Mutex
+	blocks []diffBlock
+	stream *Options
+	err    error
+}
+
+func (q *diffQueue) add(block diffBlock) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stream != nil {
+		err := renderDiffPages(block, q.stream)
+		discardSpills([]diffBlock{block})
+		if err != nil && q.err == nil {
+			q.err = err
+		}
+		return
+	}
+	q.blocks = append(q.blocks, block)
+}
+
+func (q *diffQueue) streamTo(opts *Options) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.stream = opts
+}
+
+// render writes every queued block to diffOut and empties the queue. It
+// returns the first error of a streamed block.
+func (q *diffQueue) render(opts *Options) error {
+	q.mu.Lock()
+	blocks := q.blocks
+	q.blocks = nil
+	err := q.err
+	q.err = nil
+	q.mu.Unlock()
+	defer discardSpills(blocks)
+	if err != nil {
+		return err
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].Path < blocks[j].Path
+	})
+	for _, block := range blocks {
+		if err := renderDiffPages(block, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// discard empties the queue without rendering it, removing the temporary
+// files of streamed diffs.
+func (q *diffQueue) discard() {
+	q.mu.Lock()
+	blocks := q.blocks
+	q.blocks = nil
+	q.mu.Unlock()
+	discardSpills(blocks)
+}
+
+func discardSpills(blocks []diffBlock) {
+	for _, block := range blocks {
+		if block.Spill != "" {
+			os.Remove(block.Spill)
+		}
+	}
+}
+
+func writeJSONResults(w io.Writer, results []DiffResult) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(results); err != nil {
+		return fmt.Errorf("failed to write json results: %w", err)
+	}
+	return nil
+}
+
+// writeCSVResults writes one row per examined file. encoding/csv quotes
+// paths that contain commas, quotes or newlines.
+func writeCSVResults(w io.Writer, results []DiffResult) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"path", "local_sha", "remote_sha", "added", "removed", "status"})
+	for _, result := range results {
+		writer.Write([]string{
+			result.Path,
+			result.LocalSha,
+			result.RemoteSha,
+			strconv.Itoa(result.Added),
+			strconv.Itoa(result.Removed),
+			result.Status,
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write csv results: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package comparegitfiles
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var (
+	driftRemote = map[string]string{
+		"conf/app.yaml":  "a: 1\nb: 2\n",
+		"conf/same.yaml": "same\n",
+	}
+	driftLocal = map[string]string{
+		"conf/app.yaml":  "a: 1\nb: 3\n",
+		"conf/same.yaml": "same\n",
+	}
+)
+
+func TestOutputFile(t *testing.T) {
+	gh, dir := newCompareRepo(t, driftRemote, driftLocal, "conf")
+	report := filepath.Join(dir, "report.diff")
+	writeFile(t, report, "stale content from an earlier run\n")
+
+	stdout, stderr, code := runMain(t, dir, gh.URL, "compare", "-no-state", "-verbose", "-output", report)
+	if code != 0 {
+		t.Fatalf("exit code %d\n%s", code, stderr)
+	}
+	data, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{"### conf/app.yaml", "-b: 3", "+b: 2", "1 file changed, 1 insertion(+), 1 deletion(-)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report does not contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "stale content") || strings.Contains(got, "conf/same.yaml") {
+		t.Errorf("report was not truncated or lists identical files:\n%s", got)
+	}
+	if strings.Contains(stdout, "b: 2") {
+		t.Errorf("diff written to stdout as well:\n%s", stdout)
+	}
+	if !strings.Contains(stderr, "differences") {
+		t.Errorf("log lines missing from stderr:\n%s", stderr)
+	}
+}
+
+func TestOutputFileJSON(t *testing.T) {
+	gh, dir := newCompareRepo(t, driftRemote, driftLocal, "conf")
+	report := filepath.Join(dir, "report.json")
+
+	stdout, stderr, code := runMain(t, dir, gh.URL, "compare", "-no-state", "-format", "json", "-output", report)
+	if code != 0 {
+		t.Fatalf("exit code %d\n%s", code, stderr)
+	}
+	if stdout != "" {
+		t.Errorf("stdout = %q, want the report in the file only", stdout)
+	}
+	data, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []DiffResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		t.Fatalf("report is not a JSON array: %v\n%s", err, data)
+	}
+	if len(results) != 1 || results[0].Path != "conf/app.yaml" || results[0].Added != 1 || results[0].Removed != 1 {
+		t.Errorf("results = %+v, want conf/app.yaml with 1 added and 1 removed line", results)
+	}
+}
+
+func TestCSVResults(t *testing.T) {
+	remote := map[string]string{
+		"conf/app.yaml":      "a: 1\nb: 2\n",
+		"conf/same.yaml":     "same\n",
+		"conf/new.yaml":      "new\n",
+		"conf/a, \"b\".yaml": "x\n",
+	}
+	local := map[string]string{
+		"conf/app.yaml":      "a: 1\nb: 3\nc: 4\n",
+		"conf/same.yaml":     "same\n",
+		"conf/a, \"b\".yaml": "y\n",
+	}
+	gh, dir := newCompareRepo(t, remote, local, "conf")
+
+	stdout, stderr, code := runMain(t, dir, gh.URL, "compare", "-no-state", "-format", "csv")
+	if code != 0 {
+		t.Fatalf("exit code %d\n%s", code, stderr)
+	}
+	rows, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not CSV: %v\n%s", err, stdout)
+	}
+	sha := func(content string) string { return blobSha([]byte(content)) }
+	want := [][]string{
+		{"path", "local_sha", "remote_sha", "added", "removed", "status"},
+		{`conf/a, "b".yaml`, sha("y\n"), sha("x\n"), "1", "1", "changed"},
+		{"conf/app.yaml", sha(local["conf/app.yaml"]), sha(remote["conf/app.yaml"]), "1", "2", "changed"},
+		{"conf/new.yaml", "", sha("new\n"), "0", "0", "missing_local"},
+		{"conf/same.yaml", sha("same\n"), sha("same\n"), "0", "0", "identical"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q\nwant %q", rows, want)
+	}
+}
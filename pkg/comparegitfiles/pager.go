@@ -0,0 +1,63 @@
+package comparegitfiles
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+func splitDiffPages(lines []DiffLine, pageSize int) [][]DiffLine {
+	var pages [][]DiffLine
+	for len(lines) > pageSize {
+		pages = append(pages, lines[:pageSize])
+		lines = lines[pageSize:]
+	}
+	if len(lines) > 0 {
+		pages = append(pages, lines)
+	}
+	return pages
+}
+
+// renderDiffPages renders block in pages of opts.SplitLargeDiffs changed
+// lines. On a terminal it waits for Enter between pages, otherwise every page
+// is written with a separator.
+func renderDiffPages(block diffBlock, opts *Options) error {
+	header := block.header(opts.Verbose)
+	lines, err := block.lines()
+	if err != nil {
+		return err
+	}
+	pageSize := opts.SplitLargeDiffs
+	if pageSize <= 0 {
+		return renderDiff(header, lines, opts)
+	}
+	pages := splitDiffPages(lines, pageSize)
+	if len(pages) <= 1 {
+		return renderDiff(header, lines, opts)
+	}
+
+	interactive := diffOut == io.Writer(os.Stdout) && term.IsTerminal(int(os.Stdout.Fd()))
+	input := bufio.NewReader(os.Stdin)
+	for i, page := range pages {
+		if !interactive {
+			fmt.Fprintf(diffOut, "--- Page %d/%d ---\n", i+1, len(pages))
+		}
+		if err := renderDiff(header, page, opts); err != nil {
+			return err
+		}
+		if !interactive || i == len(pages)-1 {
+			continue
+		}
+		fmt.Print("-- More (press Enter for next page, q to quit) --")
+		answer, err := input.ReadString('\n')
+		if err != nil || strings.TrimSpace(answer) == "q" {
+			fmt.Println()
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,348 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const patchContext = 3
+
+var patches = &patchSet{}
+
+// patchSet collects the unified diff of every changed file for -format
+// patch, keyed by path so the output order does not depend on scheduling.
+type patchSet struct {
+	mu      sync.Mutex
+	patches map[string]string
+}
+
+func (p *patchSet) add(path, patch string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.patches == nil {
+		p.patches = make(map[string]string)
+	}
+	p.patches[path] = patch
+}
+
+type filePatch struct {
+	Path  string
+	Patch string
+}
+
+func (p *patchSet) sorted() []filePatch {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sorted := make([]filePatch, 0, len(p.patches))
+	for path, patch := range p.patches {
+		sorted = append(sorted, filePatch{path, patch})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Path < sorted[j].Path
+	})
+	return sorted
+}
+
+func (p *patchSet) write(w io.Writer) error {
+	for _, patch := range p.sorted() {
+		if _, err := io.WriteString(w, patch.Patch); err != nil {
+			return fmt.Errorf("failed to write patch: %w", err)
+		}
+	}
+	return nil
+}
+
+type editKind int
+
+const (
+	editEqual editKind = iota
+	editDelete
+	editInsert
+)
+
+// edit is one line of a diff script. oldPos and newPos count the lines of
+// each side that precede it.
+type edit struct {
+	kind           editKind
+	line           string
+	oldPos, newPos int
+}
+
+// splitPatchLines splits s after every newline, so a missing newline at the
+// end of the file is kept on the last line.
+func splitPatchLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// myersMaxCost bounds the work of myersDiff, which is about (n+m)·D for D
+// edits between n and m lines.
+var myersMaxCost = 1 << 26
+
+// myersDiff returns the shortest edit script turning a into b, following
+// the linear space variant of Myers' O(ND) algorithm that splits the
+// problem at the middle snake of the optimal path. When the edit distance
+// exceeds what myersMaxCost allows for the size of the inputs, it falls
+// back to positionalEdits.
+func myersDiff(a, b []string) []edit {
+	n, m := len(a), len(b)
+	s := &myers{a: a, b: b, maxD: myersMaxCost / max(n+m, 1)}
+	size := (n+m+1)/2 + 2
+	s.vf, s.vb = make([]int, 2*size), make([]int, 2*size)
+	if s.compare(0, n, 0, m) {
+		return deletionsFirst(s.edits)
+	}
+	slog.Debug("diff too large for myers, comparing lines by position", "lines", n+m, "max_edits", s.maxD)
+	return positionalEdits(a, b)
+}
+
+type myers struct {
+	a, b   []string
+	vf, vb []int
+	maxD   int
+	edits  []edit
+}
+
+// compare appends the edits turning a[aLo:aHi] into b[bLo:bHi] and reports
+// false once the edit distance exceeds maxD.
+func (s *myers) compare(aLo, aHi, bLo, bHi int) bool {
+	for aLo < aHi && bLo < bHi && s.a[aLo] == s.b[bLo] {
+		s.edits = append(s.edits, edit{editEqual, s.a[aLo], aLo, bLo})
+		aLo++
+		bLo++
+	}
+	suffix := 0
+	for aLo < aHi-suffix && bLo < bHi-suffix && s.a[aHi-1-suffix] == s.b[bHi-1-suffix] {
+		suffix++
+	}
+	aHi, bHi = aHi-suffix, bHi-suffix
+
+	switch {
+	case aLo == aHi:
+		for y := bLo; y < bHi; y++ {
+			s.edits = append(s.edits, edit{editInsert, s.b[y], aLo, y})
+		}
+	case bLo == bHi:
+		for x := aLo; x < aHi; x++ {
+			s.edits = append(s.edits, edit{editDelete, s.a[x], x, bLo})
+		}
+	default:
+		x, y, u, v, ok := s.middleSnake(aLo, aHi, bLo, bHi)
+		if !ok || !s.compare(aLo, x, bLo, y) {
+			return false
+		}
+		for ; x < u; x, y = x+1, y+1 {
+			s.edits = append(s.edits, edit{editEqual, s.a[x], x, y})
+		}
+		if !s.compare(u, aHi, v, bHi) {
+			return false
+		}
+	}
+	for i := 0; i < suffix; i++ {
+		s.edits = append(s.edits, edit{editEqual, s.a[aHi+i], aHi + i, bHi + i})
+	}
+	return true
+}
+
+// middleSnake searches from both ends of a[aLo:aHi] and b[bLo:bHi] at once
+// and returns the snake, from (x, y) to (u, v), where the forward and
+// backward paths of the shortest edit script meet. vf and vb hold the
+// furthest x reached on each diagonal, vb counting from the end.
+func (s *myers) middleSnake(aLo, aHi, bLo, bHi int) (x, y, u, v int, ok bool) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+	offset := len(s.vf) / 2
+	s.vf[offset+1], s.vb[offset+1] = 0, 0
+	for d := 0; d <= (n+m+1)/2; d++ {
+		if 2*d-1 > s.maxD {
+			return 0, 0, 0, 0, false
+		}
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && s.vf[offset+k-1] < s.vf[offset+k+1]) {
+				x = s.vf[offset+k+1]
+			} else {
+				x = s.vf[offset+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && s.a[aLo+x] == s.b[bLo+y] {
+				x++
+				y++
+			}
+			s.vf[offset+k] = x
+			if odd && k >= delta-(d-1) && k <= delta+(d-1) && x+s.vb[offset+delta-k] >= n {
+				return aLo + x0, bLo + y0, aLo + x, bLo + y, true
+			}
+		}
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && s.vb[offset+k-1] < s.vb[offset+k+1]) {
+				x = s.vb[offset+k+1]
+			} else {
+				x = s.vb[offset+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && s.a[aHi-1-x] == s.b[bHi-1-y] {
+				x++
+				y++
+			}
+			s.vb[offset+k] = x
+			if !odd && k >= delta-d && k <= delta+d && x+s.vf[offset+delta-k] >= n {
+				return aHi - x, bHi - y, aHi - x0, bHi - y0, true
+			}
+		}
+	}
+	return 0, 0, 0, 0, false
+}
+
+// deletionsFirst reorders every run of changes between unchanged lines so
+// that its deletions come before its insertions, which the two directions
+// of the search do not agree on.
+func deletionsFirst(edits []edit) []edit {
+	for start := 0; start < len(edits); {
+		if edits[start].kind == editEqual {
+			start++
+			continue
+		}
+		end := start
+		var deleted, inserted []string
+		for ; end < len(edits) && edits[end].kind != editEqual; end++ {
+			if edits[end].kind == editDelete {
+				deleted = append(deleted, edits[end].line)
+			} else {
+				inserted = append(inserted, edits[end].line)
+			}
+		}
+		x, y := edits[start].oldPos, edits[start].newPos
+		i := start
+		for _, line := range deleted {
+			edits[i] = edit{editDelete, line, x, y}
+			i, x = i+1, x+1
+		}
+		for _, line := range inserted {
+			edits[i] = edit{editInsert, line, x, y}
+			i, y = i+1, y+1
+		}
+		start = end
+	}
+	return edits
+}
+
+// positionalEdits pairs the lines of a and b at the same position, the way
+// LineDiffer does, replacing each differing pair with a deletion and an
+// insertion. The script is valid but not the shortest.
+func positionalEdits(a, b []string) []edit {
+	n, m := len(a), len(b)
+	edits := make([]edit, 0, max(n, m))
+	for i := 0; i < max(n, m); i++ {
+		if i < n && i < m && a[i] == b[i] {
+			edits = append(edits, edit{editEqual, a[i], i, i})
+			continue
+		}
+		if i < n {
+			edits = append(edits, edit{editDelete, a[i], i, min(i, m)})
+		}
+		if i < m {
+			edits = append(edits, edit{editInsert, b[i], min(i+1, n), i})
+		}
+	}
+	return edits
+}
+
+// unifiedPatch renders the change from local to remote as a unified diff
+// that `patch -p1` applies from the directory the files were compared in.
+// An empty local side with localMissing set creates the file. A non-empty
+// separator replaces the @@ hunk headers, which makes the patch readable
+// but no longer applicable.
+func unifiedPatch(path, local, remote string, localMissing bool, separator string) string {
+	edits := myersDiff(splitPatchLines(local), splitPatchLines(remote))
+	path = filepath.ToSlash(filepath.Clean(path))
+
+	var b strings.Builder
+	if localMissing {
+		fmt.Fprintf(&b, "--- /dev/null\n+++ b/%s\n", path)
+	} else {
+		fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", path, path)
+	}
+	for start := 0; start < len(edits); {
+		first := start
+		for first < len(edits) && edits[first].kind == editEqual {
+			first++
+		}
+		if first == len(edits) {
+			break
+		}
+		// Extend the hunk until the run of unchanged lines is too long to
+		// be shared as context by two changes.
+		end := first
+		for end < len(edits) {
+			next := end
+			for next < len(edits) && edits[next].kind != editEqual {
+				next++
+			}
+			equal := next
+			for equal < len(edits) && edits[equal].kind == editEqual {
+				equal++
+			}
+			end = next
+			if equal == len(edits) || equal-next > 2*patchContext {
+				break
+			}
+			end = equal
+		}
+		from := max(first-patchContext, start)
+		to := min(end+patchContext, len(edits))
+		writeHunk(&b, edits[from:to], separator)
+		start = to
+	}
+	return b.String()
+}
+
+func writeHunk(b *strings.Builder, hunk []edit, separator string) {
+	oldStart, newStart := hunk[0].oldPos, hunk[0].newPos
+	var oldCount, newCount int
+	for _, e := range hunk {
+		if e.kind != editInsert {
+			oldCount++
+		}
+		if e.kind != editDelete {
+			newCount++
+		}
+	}
+	if oldCount > 0 {
+		oldStart++
+	}
+	if newCount > 0 {
+		newStart++
+	}
+	if separator != "" {
+		b.WriteString(separator + "\n")
+	} else {
+		fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+	}
+	for _, e := range hunk {
+		switch e.kind {
+		case editEqual:
+			b.WriteByte(' ')
+		case editDelete:
+			b.WriteByte('-')
+		case editInsert:
+			b.WriteByte('+')
+		}
+		b.WriteString(e.line)
+		if !strings.HasSuffix(e.line, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// applyPatch applies patch with `patch -p1` in dir.
+func applyPatch(t *testing.T, dir, patch string) {
+	t.Helper()
+	if _, err := exec.LookPath("patch"); err != nil {
+		t.Skip("patch is not installed")
+	}
+	cmd := exec.Command("patch", "-p1", "--batch", "--silent")
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(patch)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("patch -p1: %v\n%s\npatch:\n%s", err, out, patch)
+	}
+}
+
+func numberedLines(n int, format string) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, format+"\n", i)
+	}
+	return b.String()
+}
+
+func TestUnifiedPatchRoundTrip(t *testing.T) {
+	long := numberedLines(40, "line %d")
+	tests := []struct {
+		name          string
+		local, remote string
+		localMissing  bool
+		maxCost       int
+	}{
+		{name: "changed line", local: "a\nb\nc\n", remote: "a\nB\nc\n"},
+		{name: "appended lines", local: "a\n", remote: "a\nb\nc\n"},
+		{name: "removed lines", local: "a\nb\nc\nd\n", remote: "a\nd\n"},
+		{name: "prepended line", local: "b\nc\n", remote: "a\nb\nc\n"},
+		{name: "remote without final newline", local: "a\nb\n", remote: "a\nb"},
+		{name: "local without final newline", local: "a\nb", remote: "a\nc\n"},
+		{
+			name:   "hunks far apart",
+			local:  long,
+			remote: strings.Replace(strings.Replace(long, "line 3\n", "line three\n", 1), "line 37\n", "", 1),
+		},
+		{
+			name:   "hunks sharing context",
+			local:  long,
+			remote: strings.Replace(strings.Replace(long, "line 10\n", "ten\n", 1), "line 14\n", "fourteen\n", 1),
+		},
+		{name: "emptied file", local: "a\nb\n", remote: ""},
+		{name: "new file", remote: "a\nb\n", localMissing: true},
+		{
+			name:    "positional fallback",
+			local:   long,
+			remote:  strings.Replace(strings.Replace(long, "line 3\n", "", 1), "line 37\n", "line 37\nextra\n", 1),
+			maxCost: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.maxCost > 0 {
+				saved := myersMaxCost
+				myersMaxCost = tt.maxCost
+				t.Cleanup(func() { myersMaxCost = saved })
+			}
+			dir := t.TempDir()
+			path := filepath.Join(dir, "conf", "app.yaml")
+			if !tt.localMissing {
+				writeFile(t, path, tt.local)
+			}
+			applyPatch(t, dir, unifiedPatch("conf/app.yaml", tt.local, tt.remote, tt.localMissing, ""))
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.remote {
+				t.Errorf("patched file = %q, want %q", got, tt.remote)
+			}
+		})
+	}
+}
+
+// checkEdits fails unless edits turn a into b with positions counting the
+// lines before each edit, and returns the number of changes.
+func checkEdits(t *testing.T, a, b []string, edits []edit) int {
+	t.Helper()
+	var old, new []string
+	changes := 0
+	for _, e := range edits {
+		if e.oldPos != len(old) || e.newPos != len(new) {
+			t.Fatalf("edit %+v at old %d, new %d", e, len(old), len(new))
+		}
+		if e.kind != editInsert {
+			old = append(old, e.line)
+		}
+		if e.kind != editDelete {
+			new = append(new, e.line)
+		}
+		if e.kind != editEqual {
+			changes++
+		}
+	}
+	if !slices.Equal(old, a) || !slices.Equal(new, b) {
+		t.Fatalf("edits turn %q into %q, want %q into %q", old, new, a, b)
+	}
+	return changes
+}
+
+// lcsLength is the length of the longest common subsequence of a and b.
+func lcsLength(a, b []string) int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func TestMyersDiffShortest(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func() []string {
+		lines := make([]string, rng.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		a, b := random(), random()
+		changes := checkEdits(t, a, b, myersDiff(a, b))
+		if want := len(a) + len(b) - 2*lcsLength(a, b); changes != want {
+			t.Fatalf("myersDiff(%q, %q) makes %d changes, want %d", a, b, changes, want)
+		}
+	}
+}
+
+func TestMyersDiffFallback(t *testing.T) {
+	rewrite := func(n int, format string) []string {
+		return strings.Split(strings.TrimSuffix(numberedLines(n, format), "\n"), "\n")
+	}
+	tests := []struct {
+		name     string
+		a, b     []string
+		maxCost  int
+		shortest bool
+	}{
+		{name: "within the cap", a: []string{"a", "b", "c"}, b: []string{"b", "c", "d"}, maxCost: 12, shortest: true},
+		{name: "over the cap", a: []string{"a", "b", "c"}, b: []string{"b", "c", "d"}, maxCost: 5},
+		// 40000 changes, which a trace of every step could not hold
+		{name: "large rewrite", a: rewrite(20000, "old %d"), b: rewrite(20000, "new %d")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.maxCost > 0 {
+				saved := myersMaxCost
+				myersMaxCost = tt.maxCost
+				t.Cleanup(func() { myersMaxCost = saved })
+			}
+			edits := myersDiff(tt.a, tt.b)
+			checkEdits(t, tt.a, tt.b, edits)
+			if shortest := !slices.Equal(edits, positionalEdits(tt.a, tt.b)); shortest != tt.shortest {
+				t.Errorf("shortest script = %v, want %v: %+v", shortest, tt.shortest, edits)
+			}
+		})
+	}
+}
+
+func TestFormatPatchApplies(t *testing.T) {
+	remote := map[string]string{
+		"conf/app.yaml":  "a: 1\nb: 2\nc: 3\n",
+		"conf/new.yaml":  "new: true\n",
+		"conf/same.yaml": "same\n",
+	}
+	local := map[string]string{
+		"conf/app.yaml":  "a: 1\nb: 20\nc: 3\nd: 4\n",
+		"conf/same.yaml": "same\n",
+	}
+	gh, dir := newCompareRepo(t, remote, local, "conf")
+
+	stdout, stderr, code := runMain(t, dir, gh.URL, "compare", "-no-state", "-format", "patch")
+	if code != 0 {
+		t.Fatalf("exit code %d\n%s", code, stderr)
+	}
+	applyPatch(t, dir, stdout)
+	for path, want := range remote {
+		got, err := os.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q after applying the patch, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+func writePRDescription(path string, pkgdef *Config, results []DiffResult) error {
+	ref := resolveRef(pkgdef)
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "## Upstream sync from `%s@%s`\n\n", pkgdef.Name, ref)
+	if len(results) == 0 {
+		builder.WriteString("No changed files.\n\n")
+	} else {
+		total := 0
+		builder.WriteString("| File | Changed lines |\n")
+		builder.WriteString("| --- | ---: |\n")
+		for _, result := range results {
+			fmt.Fprintf(&builder, "| `%s` | %d |\n", result.Path, result.Changes)
+			total += result.Changes
+		}
+		fmt.Fprintf(&builder, "\n%d files changed, %d changed lines.\n\n", len(results), total)
+	}
+	builder.WriteString("## Review checklist\n\n")
+	builder.WriteString("- [ ] Upstream changes reviewed for every file listed above\n")
+	builder.WriteString("- [ ] Local modifications that must be kept were re-applied\n")
+	builder.WriteString("- [ ] Build and tests pass with the synced files\n")
+
+	if err := os.WriteFile(path, []byte(builder.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write pr description: %w", err)
+	}
+	return nil
+}
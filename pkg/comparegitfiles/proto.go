@@ -0,0 +1,136 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bufbuild/protocompile/parser"
+	"github.com/bufbuild/protocompile/reporter"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func isProtoFile(path string) bool {
+	return strings.HasSuffix(path, ".proto")
+}
+
+func parseProto(src, filename string) (*descriptorpb.FileDescriptorProto, error) {
+	handler := reporter.NewHandler(nil)
+	file, err := parser.Parse(filename, strings.NewReader(src), handler)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	result, err := parser.ResultFromAST(file, true, handler)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	return result.FileDescriptorProto(), nil
+}
+
+func collectProtoMessages(prefix string, messages []*descriptorpb.DescriptorProto, out map[string]*descriptorpb.DescriptorProto) {
+	for _, message := range messages {
+		name := message.GetName()
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		out[name] = message
+		collectProtoMessages(name, message.GetNestedType(), out)
+	}
+}
+
+func protoFieldType(field *descriptorpb.FieldDescriptorProto) string {
+	if field.GetTypeName() != "" {
+		return strings.TrimPrefix(field.GetTypeName(), ".")
+	}
+	return strings.ToLower(strings.TrimPrefix(field.GetType().String(), "TYPE_"))
+}
+
+func protoFieldLabel(field *descriptorpb.FieldDescriptorProto, syntax string) string {
+	switch {
+	case field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED:
+		return "repeated"
+	case field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REQUIRED:
+		return "required"
+	case field.GetProto3Optional() || (syntax != "proto3" && syntax != "editions"):
+		return "optional"
+	}
+	return ""
+}
+
+func compareProto(local, remote, filename string) ([]string, error) {
+	before, err := parseProto(local, filename)
+	if err != nil {
+		return nil, err
+	}
+	after, err := parseProto(remote, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	oldMessages := make(map[string]*descriptorpb.DescriptorProto)
+	newMessages := make(map[string]*descriptorpb.DescriptorProto)
+	collectProtoMessages("", before.GetMessageType(), oldMessages)
+	collectProtoMessages("", after.GetMessageType(), newMessages)
+
+	names := make([]string, 0, len(oldMessages)+len(newMessages))
+	for name := range oldMessages {
+		names = append(names, name)
+	}
+	for name := range newMessages {
+		if _, ok := oldMessages[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []string
+	for _, name := range names {
+		oldMessage, hadMessage := oldMessages[name]
+		newMessage, hasMessage := newMessages[name]
+		switch {
+		case !hasMessage:
+			changes = append(changes, fmt.Sprintf("[BREAKING] message %s removed", name))
+			continue
+		case !hadMessage:
+			changes = append(changes, fmt.Sprintf("[ADDITIVE] new message %s added", name))
+			continue
+		}
+
+		oldFields := make(map[int32]*descriptorpb.FieldDescriptorProto)
+		for _, field := range oldMessage.GetField() {
+			oldFields[field.GetNumber()] = field
+		}
+		newFields := make(map[int32]*descriptorpb.FieldDescriptorProto)
+		for _, field := range newMessage.GetField() {
+			newFields[field.GetNumber()] = field
+		}
+
+		for _, field := range oldMessage.GetField() {
+			newField, ok := newFields[field.GetNumber()]
+			if !ok {
+				changes = append(changes, fmt.Sprintf("[BREAKING] field %s removed from message %s", field.GetName(), name))
+				continue
+			}
+			if field.GetName() != newField.GetName() {
+				changes = append(changes, fmt.Sprintf("[BREAKING] field %d renamed from %s to %s in message %s", field.GetNumber(), field.GetName(), newField.GetName(), name))
+			}
+			if oldType, newType := protoFieldType(field), protoFieldType(newField); oldType != newType {
+				changes = append(changes, fmt.Sprintf("[BREAKING] field %s changed type from %s to %s in message %s", newField.GetName(), oldType, newType, name))
+			}
+			if oldLabel, newLabel := protoFieldLabel(field, before.GetSyntax()), protoFieldLabel(newField, after.GetSyntax()); oldLabel != newLabel {
+				changes = append(changes, fmt.Sprintf("[BREAKING] field %s changed label from %q to %q in message %s", newField.GetName(), oldLabel, newLabel, name))
+			}
+		}
+		for _, field := range newMessage.GetField() {
+			if _, ok := oldFields[field.GetNumber()]; ok {
+				continue
+			}
+			kind := "field"
+			if label := protoFieldLabel(field, after.GetSyntax()); label != "" {
+				kind = label + " field"
+			}
+			changes = append(changes, fmt.Sprintf("[ADDITIVE] new %s %s added to message %s", kind, field.GetName(), name))
+		}
+	}
+	return changes, nil
+}
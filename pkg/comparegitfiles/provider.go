@@ -0,0 +1,383 @@
+package comparegitfiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var gitlabAPI = "https://gitlab.com/api/v4"
+
+// authType is the -auth-type scheme of the Authorization header sent to
+// github: token, bearer, basic or none.
+var authType = "token"
+
+type ContentProvider interface {
+	ListPath(path string) string
+	BlobURL(sha string) string
+	RawURL(path string) string
+	TagURL(tag string) string
+	// LastCommitURL lists the commits touching path, newest first.
+	LastCommitURL(path string) string
+	Authorize(req *http.Request)
+	// DecodeList decodes a page of ListPath and returns the url of the next
+	// page, if any.
+	DecodeList(resp *http.Response, data []byte) ([]GithubContent, string, error)
+	DecodeLastCommit(data []byte) (string, error)
+}
+
+func newContentProvider(pkgdef *Config, token string) (ContentProvider, error) {
+	switch providerName(pkgdef) {
+	case "github":
+		ref := resolveRef(pkgdef)
+		if pkgdef.Commit == "" && pkgdef.Tag != "" {
+			ref = "refs/tags/" + ref
+		}
+		return &GithubProvider{Name: pkgdef.Name, Ref: ref, Token: token, AuthType: authType}, nil
+	case "gitlab":
+		return &GitlabProvider{Name: pkgdef.Name, Ref: resolveRef(pkgdef), Token: token}, nil
+	case "bitbucket":
+		return &BitbucketProvider{Name: pkgdef.Name, Ref: resolveRef(pkgdef), Username: os.Getenv("BITBUCKET_USERNAME"), Token: token}, nil
+	default:
+		return nil, fmt.Errorf("unsupported provider: %s", pkgdef.Provider)
+	}
+}
+
+func resolveRef(pkgdef *Config) string {
+	if commit := strings.TrimSpace(pkgdef.Commit); commit != "" {
+		return commit
+	}
+	if tag := strings.TrimSpace(pkgdef.Tag); tag != "" {
+		return tag
+	}
+	return strings.TrimSpace(pkgdef.Branch)
+}
+
+func validateTag(pkgdef *Config, token string) error {
+	provider, err := newContentProvider(pkgdef, token)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("GET", provider.TagURL(pkgdef.Tag), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	provider.Authorize(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("tag %s does not exist on %s", pkgdef.Tag, pkgdef.Name)
+	default:
+		return fmt.Errorf("unexpected status code: %d -> tag %s", resp.StatusCode, pkgdef.Tag)
+	}
+}
+
+func providerName(pkgdef *Config) string {
+	name := strings.ToLower(strings.TrimSpace(pkgdef.Provider))
+	if name == "" {
+		return "github"
+	}
+	return name
+}
+
+func providerTokenEnv(pkgdef *Config) []string {
+	switch providerName(pkgdef) {
+	case "gitlab":
+		return []string{"GITLAB_TOKEN"}
+	case "bitbucket":
+		return []string{"BITBUCKET_USERNAME", "BITBUCKET_APP_PASSWORD"}
+	default:
+		return []string{"GITHUB_TOKEN"}
+	}
+}
+
+// tokenFlags are the command line sources of the token.
+type tokenFlags struct {
+	value string
+	file  string
+	env   string
+}
+
+// lookupToken returns the token and where it came from: -token, -token-file,
+// then the provider's environment variables, with the token variable
+// replaced by -token-env, and for github the os keychain.
+func lookupToken(pkgdef *Config, flags tokenFlags) (string, string, error) {
+	if flags.value != "" {
+		return flags.value, "-token flag", nil
+	}
+	if flags.file != "" {
+		data, err := os.ReadFile(flags.file)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read token file: %w", err)
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return "", "", fmt.Errorf("token file %s is empty", flags.file)
+		}
+		return token, "-token-file " + flags.file, nil
+	}
+	envs := providerTokenEnv(pkgdef)
+	if flags.env != "" {
+		envs[len(envs)-1] = flags.env
+	}
+	var value, source string
+	for _, env := range envs {
+		v, isSet := os.LookupEnv(env)
+		if !isSet {
+			if providerName(pkgdef) == "github" {
+				if token := readKeyringToken(); token != "" {
+					return token, "os keychain", nil
+				}
+			}
+			return "", "", fmt.Errorf("Missing %s token -> %s", providerName(pkgdef), env)
+		}
+		value, source = v, env+" environment variable"
+	}
+	return value, source, nil
+}
+
+type GithubProvider struct {
+	Name     string
+	Ref      string
+	Token    string
+	AuthType string
+}
+
+// ListPath lists path with the contents api, which returns a directory in a
+// single response of up to 1000 entries and ignores per_page.
+func (p *GithubProvider) ListPath(path string) string {
+	query := url.Values{}
+	if p.Ref != "" {
+		query.Set("ref", p.Ref)
+	}
+	return fmt.Sprintf("%s/repos/%s/contents/%s?%s", githubAPI, p.Name, path, query.Encode())
+}
+
+func (p *GithubProvider) BlobURL(sha string) string {
+	return fmt.Sprintf("%s/repos/%s/git/blobs/%s", githubAPI, p.Name, sha)
+}
+
+func (p *GithubProvider) RawURL(path string) string {
+	ref := p.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	return fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s", p.Name, ref, path)
+}
+
+func (p *GithubProvider) TagURL(tag string) string {
+	return fmt.Sprintf("%s/repos/%s/git/ref/tags/%s", githubAPI, p.Name, tag)
+}
+
+func (p *GithubProvider) LastCommitURL(path string) string {
+	query := url.Values{}
+	query.Set("path", path)
+	if p.Ref != "" {
+		query.Set("sha", p.Ref)
+	}
+	query.Set("per_page", "1")
+	return fmt.Sprintf("%s/repos/%s/commits?%s", githubAPI, p.Name, query.Encode())
+}
+
+func (p *GithubProvider) Authorize(req *http.Request) {
+	switch p.AuthType {
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	case "basic":
+		username, password, _ := strings.Cut(p.Token, ":")
+		req.SetBasicAuth(username, password)
+	case "none":
+	default:
+		req.Header.Set("Authorization", "token "+p.Token)
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+}
+
+func (p *GithubProvider) DecodeList(resp *http.Response, data []byte) ([]GithubContent, string, error) {
+	var contents []GithubContent
+	if err := json.Unmarshal(data, &contents); err != nil {
+		var content GithubContent
+		if err := json.Unmarshal(data, &content); err != nil {
+			return nil, "", fmt.Errorf("failed to decode response: %w", err)
+		}
+		contents = []GithubContent{content}
+	}
+	return contents, "", nil
+}
+
+func (p *GithubProvider) DecodeLastCommit(data []byte) (string, error) {
+	var commits []struct {
+		Sha string `json:"sha"`
+	}
+	if err := json.Unmarshal(data, &commits); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(commits) == 0 {
+		return "", errNoCommit
+	}
+	return commits[0].Sha, nil
+}
+
+type GitlabProvider struct {
+	Name  string
+	Ref   string
+	Token string
+}
+
+type gitlabTreeEntry struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Path string `json:"path"`
+	Mode string `json:"mode"`
+}
+
+// gitlabFile is the repository/files response for a single file.
+type gitlabFile struct {
+	FileName        string `json:"file_name"`
+	FilePath        string `json:"file_path"`
+	Size            int64  `json:"size"`
+	BlobID          string `json:"blob_id"`
+	ExecuteFilemode bool   `json:"execute_filemode"`
+}
+
+func (p *GitlabProvider) project() string {
+	return fmt.Sprintf("%s/projects/%s", gitlabAPI, url.PathEscape(p.Name))
+}
+
+func (p *GitlabProvider) ListPath(path string) string {
+	query := url.Values{}
+	query.Set("path", path)
+	if p.Ref != "" {
+		query.Set("ref", p.Ref)
+	}
+	query.Set("per_page", strconv.Itoa(apiPageSize))
+	return fmt.Sprintf("%s/repository/tree?%s", p.project(), query.Encode())
+}
+
+// fileURL is the repository/files url describing the file at path.
+func (p *GitlabProvider) fileURL(path string) string {
+	query := url.Values{}
+	if p.Ref != "" {
+		query.Set("ref", p.Ref)
+	} else {
+		query.Set("ref", "HEAD")
+	}
+	return fmt.Sprintf("%s/repository/files/%s?%s", p.project(), url.PathEscape(path), query.Encode())
+}
+
+func (p *GitlabProvider) BlobURL(sha string) string {
+	return fmt.Sprintf("%s/repository/blobs/%s", p.project(), sha)
+}
+
+func (p *GitlabProvider) RawURL(path string) string {
+	ref := p.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	return fmt.Sprintf("%s/repository/files/%s/raw?ref=%s", p.project(), url.PathEscape(path), url.QueryEscape(ref))
+}
+
+func (p *GitlabProvider) TagURL(tag string) string {
+	return fmt.Sprintf("%s/repository/tags/%s", p.project(), url.PathEscape(tag))
+}
+
+func (p *GitlabProvider) LastCommitURL(path string) string {
+	query := url.Values{}
+	query.Set("path", path)
+	if p.Ref != "" {
+		query.Set("ref_name", p.Ref)
+	}
+	query.Set("per_page", "1")
+	return fmt.Sprintf("%s/repository/commits?%s", p.project(), query.Encode())
+}
+
+func (p *GitlabProvider) Authorize(req *http.Request) {
+	if p.Token != "" {
+		req.Header.Set("PRIVATE-TOKEN", p.Token)
+	}
+	req.Header.Set("Accept", "application/json")
+}
+
+func (p *GitlabProvider) DecodeList(resp *http.Response, data []byte) ([]GithubContent, string, error) {
+	var entries []gitlabTreeEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		var file gitlabFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, "", fmt.Errorf("failed to decode response: %w", err)
+		}
+		mode := "100644"
+		if file.ExecuteFilemode {
+			mode = "100755"
+		}
+		return []GithubContent{{
+			Name:        file.FileName,
+			Path:        file.FilePath,
+			Type:        "file",
+			Sha:         file.BlobID,
+			Mode:        mode,
+			Size:        file.Size,
+			DownloadURL: p.RawURL(file.FilePath),
+		}}, "", nil
+	}
+
+	// repository/tree lists nothing for the path of a file, so look the path
+	// up as a file before concluding it is empty.
+	query := resp.Request.URL.Query()
+	if len(entries) == 0 && query.Get("page") == "" {
+		return nil, p.fileURL(query.Get("path")), nil
+	}
+	contents := make([]GithubContent, 0, len(entries))
+	for _, entry := range entries {
+		content := GithubContent{
+			Name: entry.Name,
+			Path: entry.Path,
+			Sha:  entry.ID,
+			Mode: entry.Mode,
+		}
+		switch entry.Type {
+		case "tree":
+			content.Type = "dir"
+		case "blob":
+			content.Type = "file"
+			content.DownloadURL = p.RawURL(entry.Path)
+		default:
+			content.Type = entry.Type
+		}
+		contents = append(contents, content)
+	}
+
+	page := resp.Header.Get("X-Next-Page")
+	if page == "" {
+		return contents, "", nil
+	}
+	next := *resp.Request.URL
+	query.Set("page", page)
+	next.RawQuery = query.Encode()
+	return contents, next.String(), nil
+}
+
+func (p *GitlabProvider) DecodeLastCommit(data []byte) (string, error) {
+	var commits []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &commits); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(commits) == 0 {
+		return "", errNoCommit
+	}
+	return commits[0].ID, nil
+}
@@ -0,0 +1,236 @@
+package comparegitfiles
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// useGitlab points the gitlab provider at a test server for the duration of
+// the test.
+func useGitlab(t *testing.T, handler http.Handler) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	saved := gitlabAPI
+	gitlabAPI = srv.URL
+	t.Cleanup(func() { gitlabAPI = saved })
+}
+
+func TestGitlabListContents(t *testing.T) {
+	tree := map[string][][]gitlabTreeEntry{
+		"src": {
+			{{ID: "a1", Name: "a.go", Type: "blob", Path: "src/a.go", Mode: "100644"}},
+			{{ID: "b1", Name: "b.go", Type: "blob", Path: "src/b.go", Mode: "100644"}},
+			{{ID: "d1", Name: "sub", Type: "tree", Path: "src/sub", Mode: "040000"}},
+		},
+	}
+	files := map[string]gitlabFile{
+		"Makefile": {FileName: "Makefile", FilePath: "Makefile", Size: 12, BlobID: "m1"},
+		"run.sh":   {FileName: "run.sh", FilePath: "run.sh", Size: 3, BlobID: "r1", ExecuteFilemode: true},
+	}
+	useGitlab(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		const project = "/projects/group%2Fproject/repository/"
+		path := r.URL.EscapedPath()
+		switch {
+		case path == project+"tree":
+			if r.URL.Query().Get("ref") != "main" {
+				t.Errorf("tree requested with ref %q", r.URL.Query().Get("ref"))
+			}
+			pages := tree[r.URL.Query().Get("path")]
+			page := 0
+			if p := r.URL.Query().Get("page"); p != "" {
+				page, _ = strconv.Atoi(p)
+				page--
+			}
+			if page+1 < len(pages) {
+				w.Header().Set("X-Next-Page", strconv.Itoa(page+2))
+			}
+			entries := []gitlabTreeEntry{}
+			if page < len(pages) {
+				entries = pages[page]
+			}
+			json.NewEncoder(w).Encode(entries)
+		case strings.HasPrefix(path, project+"files/"):
+			file, ok := files[r.URL.Path[strings.Index(r.URL.Path, "/files/")+len("/files/"):]]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			json.NewEncoder(w).Encode(file)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	pkg := &Config{Name: "group/project", Provider: "gitlab", Branch: "main"}
+	opts := &Options{Token: "secret"}
+	raw := func(path string) string {
+		return gitlabAPI + "/projects/group%2Fproject/repository/files/" + path + "/raw?ref=main"
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    []GithubContent
+		wantErr string
+	}{
+		{
+			name: "directory over several pages",
+			path: "src",
+			want: []GithubContent{
+				{Name: "a.go", Path: "src/a.go", Type: "file", Sha: "a1", Mode: "100644", DownloadURL: raw("src%2Fa.go")},
+				{Name: "b.go", Path: "src/b.go", Type: "file", Sha: "b1", Mode: "100644", DownloadURL: raw("src%2Fb.go")},
+				{Name: "sub", Path: "src/sub", Type: "dir", Sha: "d1", Mode: "040000"},
+			},
+		},
+		{
+			name: "single file",
+			path: "Makefile",
+			want: []GithubContent{
+				{Name: "Makefile", Path: "Makefile", Type: "file", Sha: "m1", Mode: "100644", Size: 12, DownloadURL: raw("Makefile")},
+			},
+		},
+		{
+			name: "executable file",
+			path: "run.sh",
+			want: []GithubContent{
+				{Name: "run.sh", Path: "run.sh", Type: "file", Sha: "r1", Mode: "100755", Size: 3, DownloadURL: raw("run.sh")},
+			},
+		},
+		{
+			name:    "missing path",
+			path:    "nope",
+			wantErr: "unexpected status code: 404",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := listContents(context.Background(), tt.path, opts, pkg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("listContents error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("listContents = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGithubContentsRef(t *testing.T) {
+	tests := []struct {
+		name string
+		pkg  Config
+		want string
+	}{
+		{name: "default branch", pkg: Config{}, want: ""},
+		{name: "branch", pkg: Config{Branch: "develop"}, want: "develop"},
+		{name: "commit", pkg: Config{Commit: "0123abc"}, want: "0123abc"},
+		{name: "commit wins over branch", pkg: Config{Branch: "develop", Commit: "0123abc"}, want: "0123abc"},
+		{name: "commit wins over tag", pkg: Config{Tag: "v1.2.3", Commit: "0123abc"}, want: "0123abc"},
+		{name: "tag wins over branch", pkg: Config{Branch: "develop", Tag: "v1.2.3"}, want: "refs/tags/v1.2.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gh := newFakeGitHub(t, map[string]string{"conf/app.yaml": "a: 1\n"})
+			pkg := tt.pkg
+			pkg.Name = "owner/repo"
+			if _, err := listContents(context.Background(), "conf", &Options{Token: "secret"}, &pkg); err != nil {
+				t.Fatal(err)
+			}
+			urls := gh.requested("/repos/owner/repo/contents/conf")
+			if len(urls) != 1 {
+				t.Fatalf("requested %v, want one contents request", urls)
+			}
+			query := urls[0].Query()
+			if got := query.Get("ref"); got != tt.want || query.Has("ref") != (tt.want != "") {
+				t.Errorf("ref = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTag(t *testing.T) {
+	tests := []struct {
+		name    string
+		tag     string
+		wantErr string
+	}{
+		{name: "existing tag", tag: "v1.2.3"},
+		{name: "missing tag", tag: "v9.9.9", wantErr: "tag v9.9.9 does not exist on owner/repo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gh := newFakeGitHub(t, map[string]string{"conf/app.yaml": "a: 1\n"}, "v1.2.3")
+			pkg := &Config{Name: "owner/repo", Tag: tt.tag}
+			err := validateTag(pkg, "secret")
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("validateTag = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if urls := gh.requested("/repos/owner/repo/git/ref/tags/" + tt.tag); len(urls) != 1 {
+				t.Errorf("requested %v, want the tag ref", gh.requests)
+			}
+
+			if _, err := listContents(context.Background(), "conf", &Options{Token: "secret"}, pkg); err != nil {
+				t.Fatal(err)
+			}
+			urls := gh.requested("/repos/owner/repo/contents/conf")
+			if got := urls[0].Query().Get("ref"); got != "refs/tags/"+tt.tag {
+				t.Errorf("ref = %q, want refs/tags/%s", got, tt.tag)
+			}
+		})
+	}
+}
+
+func TestListPathPageSize(t *testing.T) {
+	saved := apiPageSize
+	apiPageSize = 50
+	t.Cleanup(func() { apiPageSize = saved })
+
+	tests := []struct {
+		provider string
+		param    string
+		want     string
+	}{
+		{provider: "github", param: "per_page", want: ""},
+		{provider: "gitlab", param: "per_page", want: "50"},
+		{provider: "bitbucket", param: "format", want: "meta"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			provider, err := newContentProvider(&Config{Name: "owner/repo", Provider: tt.provider}, "secret")
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := url.Parse(provider.ListPath("conf"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := u.Query().Get(tt.param); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.param, got, tt.want)
+			}
+		})
+	}
+}
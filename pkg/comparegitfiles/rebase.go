@@ -0,0 +1,69 @@
+package comparegitfiles
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+)
+
+// git runs a git command in the current directory and returns its trimmed
+// output, with stderr in the error.
+func git(stdin string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// rebaseOnto checks out branch, applies every patch to the index and the
+// work tree and commits the ones that applied. Patches that do not apply
+// are reported as conflicts and fail the run after the commit.
+func rebaseOnto(branch string, pkgdef *Config, patches []filePatch) error {
+	if len(patches) == 0 {
+		slog.Info("no upstream changes to apply", "branch", branch)
+		return nil
+	}
+	current, err := git("", "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return err
+	}
+	if current != branch {
+		if _, err := git("", "checkout", branch); err != nil {
+			return err
+		}
+	}
+
+	var applied, conflicts []string
+	for _, patch := range patches {
+		// git apply resolves the paths relative to the current directory,
+		// like the patches are.
+		if _, err := git(patch.Patch, "apply", "--index", "-"); err != nil {
+			slog.Warn("conflict", "path", patch.Path, "err", err)
+			conflicts = append(conflicts, patch.Path)
+			continue
+		}
+		applied = append(applied, patch.Path)
+	}
+
+	if len(applied) > 0 {
+		ref := resolveRef(pkgdef)
+		if ref == "" {
+			ref = "HEAD"
+		}
+		message := fmt.Sprintf("sync: upstream changes from %s@%s", pkgdef.Name, ref)
+		if _, err := git("", "commit", "-m", message); err != nil {
+			return err
+		}
+		fmt.Fprintf(diffOut, "Committed %s on %s: %s\n", plural(len(applied), "file"), branch, message)
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("conflicts applying onto %s: %s", branch, strings.Join(conflicts, ", "))
+	}
+	return nil
+}
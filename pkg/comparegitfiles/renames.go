@@ -0,0 +1,164 @@
+package comparegitfiles
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const statusRenamed = "renamed"
+
+// renameSimilarity is the similarity ratio of two versions of a file used
+// by -detect-renames: 1 - (inserted + deleted lines) / (lines of both), the
+// edit distance of their shortest line diff normalized by their size. Two
+// empty files are identical.
+func renameSimilarity(local, remote []string) (ratio float64, added, removed int) {
+	for _, e := range myersDiff(local, remote) {
+		switch e.kind {
+		case editInsert:
+			added++
+		case editDelete:
+			removed++
+		}
+	}
+	total := len(local) + len(remote)
+	if total == 0 {
+		return 1, 0, 0
+	}
+	return 1 - float64(added+removed)/float64(total), added, removed
+}
+
+// renameCandidates returns the local files below the compared roots that
+// no result covers, so the remote does not list them, in path order.
+func renameCandidates(opts *Options, pkg *Config, results []DiffResult) ([]string, error) {
+	examined := make(map[string]bool, len(results))
+	for _, result := range results {
+		examined[result.Path] = true
+	}
+	roots := pkg.Files
+	if path := strings.TrimSpace(opts.Path); path != "" {
+		roots = []string{path}
+	}
+	var starts []string
+	for _, root := range roots {
+		root = filepath.Join(depsDir, root)
+		if !isGlobPattern(root) {
+			starts = append(starts, root)
+			continue
+		}
+		matches, err := filepath.Glob(root)
+		if err != nil {
+			return nil, err
+		}
+		starts = append(starts, matches...)
+	}
+
+	seen := make(map[string]bool)
+	var candidates []string
+	for _, start := range starts {
+		err := filepath.WalkDir(start, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() {
+				if d.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			path = filepath.ToSlash(filepath.Clean(path))
+			if !d.Type().IsRegular() || examined[path] || seen[path] || checkIgnore(path) {
+				return nil
+			}
+			seen[path] = true
+			candidates = append(candidates, path)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(candidates)
+	return candidates, nil
+}
+
+// detectRenames pairs every file missing locally with the local file the
+// remote does not list that is most similar to it, when their
+// renameSimilarity reaches opts.RenameSimilarity. Each local file is the
+// target of one rename at most.
+func detectRenames(ctx context.Context, opts *Options, pkg *Config) error {
+	results := compareResults.all()
+	var missing []DiffResult
+	for _, result := range results {
+		if result.Status == statusMissingLocal {
+			missing = append(missing, result)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	candidates, err := renameCandidates(opts, pkg, results)
+	if err != nil {
+		return fmt.Errorf("failed to list local files: %w", err)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	local := make(map[string][]string, len(candidates))
+	for _, candidate := range candidates {
+		data, err := os.ReadFile(filepath.FromSlash(candidate))
+		if err != nil {
+			return err
+		}
+		local[candidate] = splitDiffLines(string(data))
+	}
+
+	used := make(map[string]bool)
+	for _, result := range missing {
+		content, err := getContentGitSha(ctx, result.RemoteSha, opts.Token, pkg)
+		if err != nil {
+			return fmt.Errorf("failed to read remote %s: %w", result.Path, err)
+		}
+		remote := splitDiffLines(content)
+		best, bestRatio := "", -1.0
+		var added, removed int
+		for _, candidate := range candidates {
+			if used[candidate] {
+				continue
+			}
+			ratio, a, r := renameSimilarity(local[candidate], remote)
+			if ratio >= opts.RenameSimilarity && ratio > bestRatio {
+				best, bestRatio, added, removed = candidate, ratio, a, r
+			}
+		}
+		if best == "" {
+			continue
+		}
+		used[best] = true
+		localSha, err := calculateLocalSHA(filepath.FromSlash(best))
+		if err != nil {
+			return err
+		}
+		slog.Info("renamed", "path", result.Path, "to", best, "similarity", fmt.Sprintf("%.2f", bestRatio))
+		compareResults.rename(result.Path, DiffResult{
+			Path:       result.Path,
+			RenamedTo:  best,
+			LocalSha:   localSha,
+			RemoteSha:  result.RemoteSha,
+			Changes:    added + removed,
+			Added:      added,
+			Removed:    removed,
+			Similarity: 100 * bestRatio,
+			Status:     statusRenamed,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package comparegitfiles
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestRenameSimilarity(t *testing.T) {
+	tests := []struct {
+		name           string
+		local, remote  string
+		want           float64
+		added, removed int
+	}{
+		{name: "identical", local: "a\nb\n", remote: "a\nb\n", want: 1},
+		{name: "both empty", want: 1},
+		{name: "one line of five changed", local: "a\nb\nc\nd\ne\n", remote: "a\nb\nc\nd\nE\n", want: 0.8, added: 1, removed: 1},
+		{name: "inserted line", local: "a\nb\nc\n", remote: "a\nx\nb\nc\n", want: 1 - 1.0/7, added: 1},
+		{name: "unrelated", local: "a\nb\n", remote: "c\nd\n", want: 0, added: 2, removed: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, added, removed := renameSimilarity(splitDiffLines(tt.local), splitDiffLines(tt.remote))
+			if math.Abs(got-tt.want) > 1e-9 || added != tt.added || removed != tt.removed {
+				t.Errorf("renameSimilarity = %v +%d -%d, want %v +%d -%d", got, added, removed, tt.want, tt.added, tt.removed)
+			}
+		})
+	}
+}
+
+func TestDetectRenames(t *testing.T) {
+	remote := map[string]string{
+		"conf/app.yaml": "a: 1\n",
+		"conf/old.yaml": "a: 1\nb: 2\nc: 3\nd: 4\ne: 5\n",
+	}
+	local := map[string]string{
+		"conf/app.yaml":   "a: 1\n",
+		"conf/new.yaml":   "a: 1\nb: 2\nc: 3\nd: 4\ne: 50\n",
+		"conf/other.yaml": "x: 1\n",
+	}
+	tests := []struct {
+		name    string
+		args    []string
+		renamed map[string]string
+	}{
+		{name: "default threshold", args: []string{"-detect-renames"}, renamed: map[string]string{"conf/old.yaml": "conf/new.yaml"}},
+		{name: "stricter threshold", args: []string{"-detect-renames", "-rename-similarity", "0.9"}},
+		{name: "looser threshold", args: []string{"-detect-renames", "-rename-similarity", "0.5"}, renamed: map[string]string{"conf/old.yaml": "conf/new.yaml"}},
+		{name: "disabled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gh, dir := newCompareRepo(t, remote, local, "conf")
+			args := append([]string{"compare", "-no-state", "-format", "json"}, tt.args...)
+			stdout, stderr, code := runMain(t, dir, gh.URL, args...)
+			if code != 0 {
+				t.Fatalf("exit code %d\n%s", code, stderr)
+			}
+			var results []DiffResult
+			if err := json.Unmarshal([]byte(stdout), &results); err != nil {
+				t.Fatalf("%v:\n%s", err, stdout)
+			}
+			renamed := make(map[string]string)
+			for _, result := range results {
+				if result.RenamedTo != "" {
+					renamed[result.Path] = result.RenamedTo
+					if result.Similarity != 80 || result.Added != 1 || result.Removed != 1 {
+						t.Errorf("rename %+v, want 80%% similar with +1 -1", result)
+					}
+				}
+			}
+			if len(renamed) != len(tt.renamed) {
+				t.Fatalf("renamed = %v, want %v", renamed, tt.renamed)
+			}
+			for path, to := range tt.renamed {
+				if renamed[path] != to {
+					t.Errorf("%s renamed to %q, want %q", path, renamed[path], to)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectRenamesDiffStat(t *testing.T) {
+	gh, dir := newCompareRepo(t, map[string]string{"conf/old.yaml": "a\nb\n"}, map[string]string{"conf/new.yaml": "a\nb\n"}, "conf")
+	stdout, stderr, code := runMain(t, dir, gh.URL, "compare", "-no-state", "-detect-renames")
+	if code != 0 {
+		t.Fatalf("exit code %d\n%s", code, stderr)
+	}
+	if want := " conf/old.yaml => conf/new.yaml | 0 \n"; !strings.Contains(stdout, want) {
+		t.Errorf("stdout does not contain %q:\n%s", want, stdout)
+	}
+
+	if _, stderr, code := runMain(t, dir, gh.URL, "compare", "-no-state", "-detect-renames", "-rename-similarity", "80"); code != 1 || !strings.Contains(stderr, "invalid -rename-similarity") {
+		t.Errorf("-rename-similarity 80: exit code %d\n%s", code, stderr)
+	}
+}
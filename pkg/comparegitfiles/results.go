@@ -0,0 +1,232 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var compareResults = &resultCollector{}
+
+type DiffResult struct {
+	Path      string `json:"path"`
+	LocalSha  string `json:"local_sha"`
+	RemoteSha string `json:"remote_sha"`
+	Changes   int    `json:"changes"`
+	Diff      string `json:"diff,omitempty"`
+	Added     int    `json:"added"`
+	Removed   int    `json:"removed"`
+	// Similarity is the percentage of lines both sides have in common.
+	Similarity float64 `json:"similarity"`
+	// RenamedTo is the local file -detect-renames found for a file missing
+	// locally.
+	RenamedTo string `json:"renamed_to,omitempty"`
+	Status    string `json:"-"`
+}
+
+const diffStatWidth = 40
+
+// Summary totals the changed files of a run.
+type Summary struct {
+	Files   int
+	Added   int
+	Removed int
+}
+
+func summarize(results []DiffResult) Summary {
+	var summary Summary
+	for _, result := range results {
+		summary.Files++
+		summary.Added += result.Added
+		summary.Removed += result.Removed
+	}
+	return summary
+}
+
+// writeDiffStat prints a git style stat line per changed file followed by
+// the totals, scaling the +/- bars when a file has more changes than fit.
+func writeDiffStat(w io.Writer, results []DiffResult) {
+	if len(results) == 0 {
+		return
+	}
+	pathWidth, maxChanges := 0, 0
+	for _, result := range results {
+		pathWidth = max(pathWidth, len(statPath(result)))
+		maxChanges = max(maxChanges, result.Added+result.Removed)
+	}
+	countWidth := len(strconv.Itoa(max(maxChanges, 1)))
+	for _, result := range results {
+		added, removed := result.Added, result.Removed
+		if maxChanges > diffStatWidth {
+			added = (added*diffStatWidth + maxChanges - 1) / maxChanges
+			removed = (removed*diffStatWidth + maxChanges - 1) / maxChanges
+		}
+		fmt.Fprintf(w, " %-*s | %*d %s%s\n", pathWidth, statPath(result), countWidth, result.Changes,
+			strings.Repeat("+", added), strings.Repeat("-", removed))
+	}
+	summary := summarize(results)
+	fmt.Fprintf(w, " %s changed, %s(+), %s(-)\n",
+		plural(summary.Files, "file"), plural(summary.Added, "insertion"), plural(summary.Removed, "deletion"))
+}
+
+// statPath is the path of result in the diff stat, old => new for a rename.
+func statPath(result DiffResult) string {
+	if result.RenamedTo != "" {
+		return result.Path + " => " + result.RenamedTo
+	}
+	return result.Path
+}
+
+func countLines(content string) int {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return 0
+	}
+	return strings.Count(content, "\n") + 1
+}
+
+// similarity returns 1 - changes / (local lines + remote lines) as a
+// percentage.
+func similarity(local, remote string, changes int) float64 {
+	return lineSimilarity(countLines(local), countLines(remote), changes)
+}
+
+// lineSimilarity is similarity for files already counted in lines.
+func lineSimilarity(localLines, remoteLines, changes int) float64 {
+	total := localLines + remoteLines
+	if total == 0 {
+		return 100
+	}
+	return max(0, 100*(1-float64(changes)/float64(total)))
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+// linesDiffer is plural with the verb agreeing, as in "1 line differs".
+func linesDiffer(n int) string {
+	if n == 1 {
+		return "1 line differs"
+	}
+	return fmt.Sprintf("%d lines differ", n)
+}
+
+const (
+	statusIdentical    = "identical"
+	statusChanged      = "changed"
+	statusMissingLocal = "missing_local"
+)
+
+type resultCollector struct {
+	mu      sync.Mutex
+	results []DiffResult
+}
+
+func (c *resultCollector) add(result DiffResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = append(c.results, result)
+}
+
+// remove drops the results recorded for path, before it is compared again.
+func (c *resultCollector) remove(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kept := c.results[:0]
+	for _, result := range c.results {
+		if result.Path != path {
+			kept = append(kept, result)
+		}
+	}
+	c.results = kept
+}
+
+// rename replaces the result recorded for path with the renamed one.
+func (c *resultCollector) rename(path string, renamed DiffResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.results {
+		if c.results[i].Path == path {
+			c.results[i] = renamed
+		}
+	}
+}
+
+// sorted returns the changed and renamed files ordered by path.
+func (c *resultCollector) sorted() []DiffResult {
+	var changed []DiffResult
+	for _, result := range c.all() {
+		if result.Status == statusChanged || result.Status == statusRenamed {
+			changed = append(changed, result)
+		}
+	}
+	return changed
+}
+
+// all returns every examined file ordered by path, including identical files
+// and files missing locally.
+func (c *resultCollector) all() []DiffResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	results := make([]DiffResult, len(c.results))
+	copy(results, c.results)
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Path < results[j].Path
+	})
+	return results
+}
+
+func recordEqual(filePath, localSha, remoteSha string) {
+	fileGraph.setStatus(filePath, "equal")
+	compareResults.add(DiffResult{
+		Path:      filepath.ToSlash(filepath.Clean(filePath)),
+		LocalSha:  localSha,
+		RemoteSha: remoteSha,
+		Status:    statusIdentical,
+	})
+}
+
+func recordMissing(filePath, remoteSha string) {
+	compareResults.add(DiffResult{
+		Path:      filepath.ToSlash(filepath.Clean(filePath)),
+		RemoteSha: remoteSha,
+		Status:    statusMissingLocal,
+	})
+}
+
+func recordDifference(filePath, localSha, remoteSha string, changes int, diff string) {
+	fileGraph.setStatus(filePath, "changed")
+	compareResults.add(DiffResult{
+		Path:      filepath.ToSlash(filepath.Clean(filePath)),
+		LocalSha:  localSha,
+		RemoteSha: remoteSha,
+		Changes:   changes,
+		Diff:      diff,
+		Status:    statusChanged,
+	})
+}
+
+// recordLineDifference records a text diff together with its added and
+// removed line counts and the similarity of both sides.
+func recordLineDifference(filePath, localSha, remoteSha string, added, removed int, similarity float64, diff string) {
+	fileGraph.setStatus(filePath, "changed")
+	compareResults.add(DiffResult{
+		Path:       filepath.ToSlash(filepath.Clean(filePath)),
+		LocalSha:   localSha,
+		RemoteSha:  remoteSha,
+		Changes:    added + removed,
+		Diff:       diff,
+		Added:      added,
+		Removed:    removed,
+		Similarity: similarity,
+		Status:     statusChanged,
+	})
+}
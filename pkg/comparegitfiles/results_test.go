@@ -0,0 +1,103 @@
+package comparegitfiles
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteDiffStat(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []DiffResult
+		want    string
+	}{
+		{name: "no changes"},
+		{
+			name: "mixed",
+			results: []DiffResult{
+				{Path: "src/main.go", Changes: 12, Added: 5, Removed: 7},
+				{Path: "go.mod", Changes: 1, Added: 1},
+			},
+			want: "" +
+				" src/main.go | 12 +++++-------\n" +
+				" go.mod      |  1 +\n" +
+				" 2 files changed, 6 insertions(+), 7 deletions(-)\n",
+		},
+		{
+			name:    "only deletions",
+			results: []DiffResult{{Path: "a.txt", Changes: 2, Removed: 2}},
+			want:    " a.txt | 2 --\n 1 file changed, 0 insertions(+), 2 deletions(-)\n",
+		},
+		{
+			name:    "scaled bars",
+			results: []DiffResult{{Path: "big.txt", Changes: 400, Added: 300, Removed: 100}},
+			want:    " big.txt | 400 " + strings.Repeat("+", 30) + strings.Repeat("-", 10) + "\n 1 file changed, 300 insertions(+), 100 deletions(-)\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			writeDiffStat(&b, tt.results)
+			if b.String() != tt.want {
+				t.Errorf("writeDiffStat =\n%s\nwant\n%s", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		name          string
+		local, remote string
+		want          float64
+	}{
+		{name: "identical", local: "a\nb\n", remote: "a\nb\n", want: 100},
+		{name: "both empty", want: 100},
+		{name: "half", local: "a\nb\n", remote: "a\nc\n", want: 50},
+		{name: "rewritten", local: "a\nb\n", remote: "c\nd\n", want: 0},
+		{name: "emptied", local: "a\nb\n", remote: "", want: 0},
+		{name: "quarter changed", local: "a\nb\nc\nd\n", remote: "a\nb\nc\ne\n", want: 75},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := LineDiffer{}.Diff(tt.local, tt.remote).Stats
+			if got := similarity(tt.local, tt.remote, stats.Added+stats.Removed); got != tt.want {
+				t.Errorf("similarity = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilarityShown(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max float64
+		shown    map[float64]bool
+	}{
+		{name: "default", min: 0, max: 100, shown: map[float64]bool{0: true, 50: true, 100: true}},
+		{name: "min", min: 50, max: 100, shown: map[float64]bool{0: false, 49.9: false, 50: true, 100: true}},
+		{name: "max", min: 0, max: 50, shown: map[float64]bool{0: true, 50: true, 50.1: false, 100: false}},
+		{name: "range", min: 40, max: 60, shown: map[float64]bool{30: false, 50: true, 70: false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &Options{MinSimilarity: tt.min, MaxSimilarity: tt.max}
+			for similarity, want := range tt.shown {
+				if got := opts.similarityShown(similarity); got != want {
+					t.Errorf("similarityShown(%v) = %v, want %v", similarity, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSimilarityLogged(t *testing.T) {
+	gh, dir := newCompareRepo(t, driftRemote, driftLocal, "conf")
+	_, stderr, code := runMain(t, dir, gh.URL, "compare", "-no-state", "-verbose")
+	if code != 0 {
+		t.Fatalf("exit code %d\n%s", code, stderr)
+	}
+	if want := "level=DEBUG msg=similarity path=conf/app.yaml similarity=50% changes=2"; !strings.Contains(stderr, want) {
+		t.Errorf("stderr does not contain %q:\n%s", want, stderr)
+	}
+}
@@ -0,0 +1,107 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRetryStatuses = "429,500,502,503,504"
+	maxRetries           = 3
+	retryBaseDelay       = time.Second
+)
+
+// backoff computes the sleep before retry attempt n (counting from 0):
+//
+//	delay = min(retryBaseDelay * factor^n, maxDelay)
+//	sleep = delay + jitter * delay * random[0, 1)
+type backoff struct {
+	factor   float64
+	maxDelay time.Duration
+	jitter   float64
+}
+
+var defaultBackoff = backoff{factor: 2, maxDelay: time.Minute, jitter: 0.1}
+
+func (b backoff) validate() error {
+	if b.factor < 1 {
+		return fmt.Errorf("backoff factor %v must be at least 1", b.factor)
+	}
+	if b.maxDelay <= 0 {
+		return fmt.Errorf("max delay %s must be positive", b.maxDelay)
+	}
+	if b.jitter < 0 {
+		return fmt.Errorf("jitter factor %v must not be negative", b.jitter)
+	}
+	return nil
+}
+
+func (b backoff) delay(attempt int) time.Duration {
+	delay := float64(retryBaseDelay)
+	for i := 0; i < attempt && delay < float64(b.maxDelay); i++ {
+		delay *= b.factor
+	}
+	delay = min(delay, float64(b.maxDelay))
+	return time.Duration(delay + b.jitter*delay*rand.Float64())
+}
+
+func parseStatusCodes(value string) (map[int]bool, error) {
+	codes := make(map[int]bool)
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		code, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status code %q: %w", field, err)
+		}
+		if code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid status code %d: must be between 100 and 599", code)
+		}
+		codes[code] = true
+	}
+	return codes, nil
+}
+
+type retryTransport struct {
+	next     http.RoundTripper
+	statuses map[int]bool
+	backoff  backoff
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if err != nil || !t.statuses[resp.StatusCode] || attempt >= maxRetries {
+			return resp, err
+		}
+		if req.Body != nil {
+			if req.GetBody == nil {
+				return resp, nil
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return resp, nil
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		wait := t.backoff.delay(attempt)
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			wait = time.Duration(seconds) * time.Second
+		}
+		resp.Body.Close()
+
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package comparegitfiles
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// redactedToken is masked in every log record once the token is known.
+var redactedToken string
+
+// sanitize replaces token, also in its URL-escaped form, with ***.
+func sanitize(s, token string) string {
+	if token == "" {
+		return s
+	}
+	s = strings.ReplaceAll(s, token, "***")
+	if escaped := url.QueryEscape(token); escaped != token {
+		s = strings.ReplaceAll(s, escaped, "***")
+	}
+	return s
+}
+
+// sanitizingHandler masks redactedToken in the message and the string and
+// error attributes of every record before passing it on.
+type sanitizingHandler struct {
+	next slog.Handler
+}
+
+func (h sanitizingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h sanitizingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if redactedToken == "" {
+		return h.next.Handle(ctx, r)
+	}
+	clean := slog.NewRecord(r.Time, r.Level, sanitize(r.Message, redactedToken), r.PC)
+	r.Attrs(func(attr slog.Attr) bool {
+		clean.AddAttrs(sanitizeAttr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, clean)
+}
+
+func (h sanitizingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clean := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		clean[i] = sanitizeAttr(attr)
+	}
+	return sanitizingHandler{h.next.WithAttrs(clean)}
+}
+
+func (h sanitizingHandler) WithGroup(name string) slog.Handler {
+	return sanitizingHandler{h.next.WithGroup(name)}
+}
+
+func sanitizeAttr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, sanitize(value.String(), redactedToken))
+	case slog.KindGroup:
+		group := value.Group()
+		clean := make([]any, len(group))
+		for i, member := range group {
+			clean[i] = sanitizeAttr(member)
+		}
+		return slog.Group(attr.Key, clean...)
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			return slog.String(attr.Key, sanitize(err.Error(), redactedToken))
+		}
+	}
+	return attr
+}
@@ -0,0 +1,159 @@
+package comparegitfiles
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testToken contains characters that url query escaping changes.
+const testToken = "ghp_t0k3n/with+special=chars"
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name  string
+		s     string
+		token string
+		want  string
+	}{
+		{name: "no token", s: "token ghp_abc", want: "token ghp_abc"},
+		{name: "header", s: "Authorization: token " + testToken, token: testToken, want: "Authorization: token ***"},
+		{name: "repeated", s: testToken + " " + testToken, token: testToken, want: "*** ***"},
+		{name: "query escaped", s: "https://api.github.com/x?access_token=" + url.QueryEscape(testToken), token: testToken, want: "https://api.github.com/x?access_token=***"},
+		{name: "absent", s: "unexpected status code: 404 -> conf", token: testToken, want: "unexpected status code: 404 -> conf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitize(tt.s, tt.token); got != tt.want {
+				t.Errorf("sanitize(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizingHandler(t *testing.T) {
+	saved := redactedToken
+	redactedToken = testToken
+	t.Cleanup(func() { redactedToken = saved })
+
+	tests := []struct {
+		name string
+		log  func(logger *slog.Logger)
+	}{
+		{name: "message", log: func(l *slog.Logger) { l.Warn("token " + testToken) }},
+		{name: "string attr", log: func(l *slog.Logger) { l.Warn("request", "url", "https://x/?t="+url.QueryEscape(testToken)) }},
+		{name: "error attr", log: func(l *slog.Logger) { l.Error("failed", "err", fmt.Errorf("bad credentials %s", testToken)) }},
+		{name: "wrapped error", log: func(l *slog.Logger) {
+			l.Error("failed", "err", fmt.Errorf("fetch: %w", errors.New(testToken)))
+		}},
+		{name: "group", log: func(l *slog.Logger) { l.Warn("request", slog.Group("header", "authorization", "token "+testToken)) }},
+		{name: "with attrs", log: func(l *slog.Logger) { l.With("token", testToken).Warn("request") }},
+		{name: "with group", log: func(l *slog.Logger) { l.WithGroup("auth").Warn("request", "token", testToken) }},
+	}
+	for _, format := range []string{"text", "json"} {
+		for _, tt := range tests {
+			t.Run(format+" "+tt.name, func(t *testing.T) {
+				out := useLogger(t, format, "debug", false)
+				tt.log(slog.Default())
+				if out.Len() == 0 {
+					t.Fatal("nothing logged")
+				}
+				if strings.Contains(out.String(), testToken) || strings.Contains(out.String(), url.QueryEscape(testToken)) {
+					t.Errorf("log output contains the token:\n%s", out)
+				}
+				if !strings.Contains(out.String(), "***") {
+					t.Errorf("log output does not contain the mask:\n%s", out)
+				}
+			})
+		}
+	}
+}
+
+func TestDumpTransportMasksToken(t *testing.T) {
+	// the server echoes the credentials back, as some error pages do
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Echo", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintf(w, `{"message":"bad credentials %s","url":%q}`, r.Header.Get("Authorization"), r.URL.String())
+	}))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name      string
+		authorize func(req *http.Request)
+	}{
+		{name: "token header", authorize: func(req *http.Request) { req.Header.Set("Authorization", "token "+testToken) }},
+		{name: "bearer header", authorize: func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+testToken) }},
+		{name: "gitlab header", authorize: func(req *http.Request) { req.Header.Set("Private-Token", testToken) }},
+		{name: "basic auth", authorize: func(req *http.Request) { req.SetBasicAuth("user", testToken) }},
+		{name: "query", authorize: func(req *http.Request) { req.URL.RawQuery = "access_token=" + url.QueryEscape(testToken) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dump bytes.Buffer
+			transport := &dumpTransport{next: http.DefaultTransport, w: &dump, token: testToken}
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/repos/owner/repo/contents/conf", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.authorize(req)
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+
+			if dump.Len() == 0 {
+				t.Fatal("nothing dumped")
+			}
+			if strings.Contains(dump.String(), testToken) || strings.Contains(dump.String(), url.QueryEscape(testToken)) {
+				t.Errorf("dump contains the token:\n%s", dump.String())
+			}
+			if user, password, ok := req.BasicAuth(); ok && strings.Contains(dump.String(), req.Header.Get("Authorization")) {
+				t.Errorf("dump contains the basic auth credentials of %s:%s:\n%s", user, password, dump.String())
+			}
+		})
+	}
+}
+
+func TestTokenNeverInOutput(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "compare", args: []string{"compare", "-no-state"}},
+		{name: "failed compare", args: []string{"compare", "-no-state", "-path", "missing"}},
+		{name: "sync", args: []string{"sync"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gh, dir := newCompareRepo(t, driftRemote, driftLocal, "conf")
+			debugLog := filepath.Join(t.TempDir(), "http.log")
+			args := append(tt.args, "-token", testToken, "-log-level", "debug", "-http-debug", debugLog, "-http-dump-bodies")
+			stdout, stderr, _ := runMain(t, dir, gh.URL, args...)
+			dump, err := os.ReadFile(debugLog)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(dump), "Authorization: token ***") {
+				t.Errorf("http debug log does not contain the masked header:\n%s", dump)
+			}
+			outputs := map[string]string{"stdout": stdout, "stderr": stderr, "http debug log": string(dump)}
+			for name, output := range outputs {
+				if strings.Contains(output, testToken) || strings.Contains(output, url.QueryEscape(testToken)) {
+					t.Errorf("%s contains the token:\n%s", name, output)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package comparegitfiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	sarifRuleID  = "file-drift"
+)
+
+// SARIFReport is the subset of a SARIF 2.1.0 log that code scanning reads.
+type SARIFReport struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []SARIFRun `json:"runs"`
+}
+
+type SARIFRun struct {
+	Tool    SARIFTool     `json:"tool"`
+	Results []SARIFResult `json:"results"`
+}
+
+type SARIFTool struct {
+	Driver SARIFDriver `json:"driver"`
+}
+
+type SARIFDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []SARIFRule `json:"rules"`
+}
+
+type SARIFRule struct {
+	ID               string       `json:"id"`
+	ShortDescription SARIFMessage `json:"shortDescription"`
+}
+
+type SARIFMessage struct {
+	Text string `json:"text"`
+}
+
+type SARIFResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   SARIFMessage    `json:"message"`
+	Locations []SARIFLocation `json:"locations"`
+}
+
+type SARIFLocation struct {
+	PhysicalLocation SARIFPhysicalLocation `json:"physicalLocation"`
+}
+
+type SARIFPhysicalLocation struct {
+	ArtifactLocation SARIFArtifactLocation `json:"artifactLocation"`
+	Region           SARIFRegion           `json:"region"`
+}
+
+type SARIFArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type SARIFRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+func newSARIFReport(results []DiffResult) SARIFReport {
+	run := SARIFRun{
+		Tool: SARIFTool{Driver: SARIFDriver{
+			Name:           "comparegitfiles",
+			Version:        Version,
+			InformationURI: "https://github.com/adriangitvitz/comparegitfiles",
+			Rules: []SARIFRule{{
+				ID:               sarifRuleID,
+				ShortDescription: SARIFMessage{Text: "Local file differs from the remote repository"},
+			}},
+		}},
+		Results: []SARIFResult{},
+	}
+	for _, result := range results {
+		run.Results = append(run.Results, SARIFResult{
+			RuleID:  sarifRuleID,
+			Level:   "warning",
+			Message: SARIFMessage{Text: annotationMessage(result)},
+			Locations: []SARIFLocation{{PhysicalLocation: SARIFPhysicalLocation{
+				ArtifactLocation: SARIFArtifactLocation{URI: filepath.ToSlash(filepath.Clean(result.Path)), URIBaseID: "%SRCROOT%"},
+				Region:           SARIFRegion{StartLine: 1},
+			}}},
+		})
+	}
+	return SARIFReport{Schema: sarifSchema, Version: sarifVersion, Runs: []SARIFRun{run}}
+}
+
+func writeSARIFResults(w io.Writer, results []DiffResult) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(newSARIFReport(results)); err != nil {
+		return fmt.Errorf("failed to write sarif results: %w", err)
+	}
+	return nil
+}
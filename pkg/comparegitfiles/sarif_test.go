@@ -0,0 +1,107 @@
+package comparegitfiles
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"testing"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+//go:embed testdata/sarif-2.1.0-subset.json
+var sarifSchemaSubset []byte
+
+// validateSARIF validates data against the subset of the SARIF 2.1.0
+// schema in testdata.
+func validateSARIF(t *testing.T, data []byte) {
+	t.Helper()
+	result, err := gojsonschema.Validate(
+		gojsonschema.NewBytesLoader(sarifSchemaSubset),
+		gojsonschema.NewBytesLoader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range result.Errors() {
+		t.Errorf("sarif: %s", e)
+	}
+}
+
+func TestWriteSARIFResults(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []DiffResult
+		uris    []string
+		texts   []string
+	}{
+		{name: "no results"},
+		{
+			name: "changed files",
+			results: []DiffResult{
+				{Path: "conf/app.yaml", RemoteSha: "abc123", Changes: 2},
+				{Path: "./conf/../Makefile", RemoteSha: "def456", Changes: 1},
+			},
+			uris:  []string{"conf/app.yaml", "Makefile"},
+			texts: []string{"2 lines differ from remote (abc123)", "1 line differs from remote (def456)"},
+		},
+		{
+			name:    "missing locally",
+			results: []DiffResult{{Path: "conf/new.yaml", RemoteSha: "abc123", Status: statusMissingLocal}},
+			uris:    []string{"conf/new.yaml"},
+			texts:   []string{"missing locally (abc123)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := writeSARIFResults(&out, tt.results); err != nil {
+				t.Fatal(err)
+			}
+			validateSARIF(t, out.Bytes())
+
+			var report SARIFReport
+			if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Runs) != 1 {
+				t.Fatalf("got %d runs, want 1", len(report.Runs))
+			}
+			run := report.Runs[0]
+			if len(run.Tool.Driver.Rules) != 1 || run.Tool.Driver.Rules[0].ID != sarifRuleID {
+				t.Errorf("rules = %+v, want only %s", run.Tool.Driver.Rules, sarifRuleID)
+			}
+			if len(run.Results) != len(tt.uris) {
+				t.Fatalf("got %d results, want %d", len(run.Results), len(tt.uris))
+			}
+			for i, result := range run.Results {
+				if result.RuleID != sarifRuleID || result.Level != "warning" {
+					t.Errorf("result %d: rule %q level %q, want %s warning", i, result.RuleID, result.Level, sarifRuleID)
+				}
+				if result.Message.Text != tt.texts[i] {
+					t.Errorf("result %d: message %q, want %q", i, result.Message.Text, tt.texts[i])
+				}
+				location := result.Locations[0].PhysicalLocation
+				if location.ArtifactLocation.URI != tt.uris[i] || location.ArtifactLocation.URIBaseID != "%SRCROOT%" {
+					t.Errorf("result %d: location %+v, want %s relative to %%SRCROOT%%", i, location.ArtifactLocation, tt.uris[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSARIFFormat(t *testing.T) {
+	gh, dir := newCompareRepo(t, driftRemote, driftLocal, "conf")
+	stdout, stderr, code := runMain(t, dir, gh.URL, "compare", "-no-state", "-format", "sarif")
+	if code != 0 {
+		t.Fatalf("exit code %d\n%s", code, stderr)
+	}
+	validateSARIF(t, []byte(stdout))
+
+	var report SARIFReport
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("stdout is not a sarif document: %v\n%s", err, stdout)
+	}
+	if results := report.Runs[0].Results; len(results) != 1 || results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI != "conf/app.yaml" {
+		t.Errorf("results = %+v, want conf/app.yaml only", results)
+	}
+}
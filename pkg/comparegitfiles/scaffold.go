@@ -0,0 +1,33 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var createDirsMu sync.Mutex
+
+// createDirs creates dir and any missing parents, logging each directory
+// that did not exist yet.
+func createDirs(dir string) error {
+	createDirsMu.Lock()
+	defer createDirsMu.Unlock()
+
+	var missing []string
+	for d := dir; d != "." && d != string(filepath.Separator); d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil {
+			break
+		}
+		missing = append(missing, d)
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := os.Mkdir(missing[i], 0755); err != nil && !os.IsExist(err) {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		slog.Debug("created directory", "path", missing[i])
+	}
+	return nil
+}
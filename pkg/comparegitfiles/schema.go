@@ -0,0 +1,42 @@
+package comparegitfiles
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+	"github.com/xeipuuv/gojsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed diffs.schema.json
+var configSchema []byte
+
+// validateConfigSchema checks the raw config against the bundled JSON Schema
+// and returns one message per violation, prefixed with the field path.
+func validateConfigSchema(data []byte, filename string) ([]string, error) {
+	document := gojsonschema.NewBytesLoader(data)
+	switch configFormat(filename) {
+	case "yaml":
+		var value interface{}
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+		}
+		document = gojsonschema.NewGoLoader(value)
+	case "toml":
+		var value map[string]interface{}
+		if err := toml.Unmarshal(data, &value); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+		}
+		document = gojsonschema.NewGoLoader(value)
+	}
+	result, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(configSchema), document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate config schema: %w", err)
+	}
+	var violations []string
+	for _, violation := range result.Errors() {
+		violations = append(violations, fmt.Sprintf("%s: %s", violation.Field(), violation.Description()))
+	}
+	return violations, nil
+}
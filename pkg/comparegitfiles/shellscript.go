@@ -0,0 +1,64 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// shellQuote wraps s in single quotes for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellAuth returns the curl and wget arguments that authorize a download
+// the same way the provider does, reading the credentials from the same
+// environment variables as comparegitfiles instead of embedding them.
+func shellAuth(pkgdef *Config) (curl, wget string) {
+	switch providerName(pkgdef) {
+	case "gitlab":
+		return `-H "PRIVATE-TOKEN: ${GITLAB_TOKEN}"`, `--header="PRIVATE-TOKEN: ${GITLAB_TOKEN}"`
+	case "bitbucket":
+		return `-u "${BITBUCKET_USERNAME}:${BITBUCKET_APP_PASSWORD}"`, `--user="${BITBUCKET_USERNAME}" --password="${BITBUCKET_APP_PASSWORD}"`
+	default:
+		return `-H "Authorization: token ${GITHUB_TOKEN}"`, `--header="Authorization: token ${GITHUB_TOKEN}"`
+	}
+}
+
+// writeShellScript writes a POSIX sh script that downloads files with curl,
+// or wget when curl is missing, so that the sync can be repeated without
+// comparegitfiles.
+func writeShellScript(path string, pkgdef *Config, files []syncedFile) error {
+	ref := resolveRef(pkgdef)
+	if ref == "" {
+		ref = "HEAD"
+	}
+	curlAuth, wgetAuth := shellAuth(pkgdef)
+
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&b, "# Generated by comparegitfiles -write-shell-script for %s@%s.\n", pkgdef.Name, ref)
+	b.WriteString("set -eu\n\n")
+	for _, env := range providerTokenEnv(pkgdef) {
+		fmt.Fprintf(&b, ": \"${%s:?%s must be set}\"\n", env, env)
+	}
+	b.WriteString("\nif command -v curl >/dev/null 2>&1; then\n")
+	fmt.Fprintf(&b, "\tfetch() { mkdir -p \"$(dirname \"$2\")\" && curl -fsSL %s -o \"$2\" \"$1\"; }\n", curlAuth)
+	b.WriteString("elif command -v wget >/dev/null 2>&1; then\n")
+	fmt.Fprintf(&b, "\tfetch() { mkdir -p \"$(dirname \"$2\")\" && wget -q %s -O \"$2\" \"$1\"; }\n", wgetAuth)
+	b.WriteString("else\n\techo \"curl or wget is required\" >&2\n\texit 1\nfi\n\n")
+	for _, file := range files {
+		if file.URL == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "fetch %s %s\n", shellQuote(file.URL), shellQuote(filepath.ToSlash(filepath.Clean(file.Path))))
+	}
+
+	if err := os.WriteFile(path, []byte(b.String()), 0755); err != nil {
+		return fmt.Errorf("failed to write shell script: %w", err)
+	}
+	slog.Info("shell script written", "path", path)
+	return nil
+}
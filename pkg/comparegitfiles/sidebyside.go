@@ -0,0 +1,116 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+const (
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiDim   = "\x1b[2m"
+	ansiReset = "\x1b[0m"
+
+	defaultDiffWidth = 80
+)
+
+// sideBySideWidth returns -width when set, the terminal width when diffs go
+// to a terminal and defaultDiffWidth otherwise.
+func sideBySideWidth(opts *Options) int {
+	if opts.Width > 0 {
+		return opts.Width
+	}
+	if diffOut == io.Writer(os.Stdout) {
+		if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+			return width
+		}
+	}
+	return defaultDiffWidth
+}
+
+// fitColumn expands tabs, then truncates s with an ellipsis or pads it with
+// spaces to exactly width runes.
+func fitColumn(s string, width int) string {
+	s = strings.ReplaceAll(s, "\t", "    ")
+	if n := utf8.RuneCountInString(s); n <= width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	if width <= 0 {
+		return ""
+	}
+	return string([]rune(s)[:width-1]) + "…"
+}
+
+func colorize(s, color string, noColor bool) string {
+	if noColor {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// renderSideBySide prints removed lines on the left and added lines on the
+// right. Within a block of changes the n-th removed line is paired with the
+// n-th added one and marked with "|"; unpaired lines get "<" or ">". With
+// -line-numbers each column starts with a gutter holding its line number.
+func renderSideBySide(lines []DiffLine, opts *Options) error {
+	column := (sideBySideWidth(opts) - 3) / 2
+	gutter := 0
+	if opts.LineNumbers {
+		for _, line := range lines {
+			gutter = max(gutter, len(strconv.Itoa(max(line.OldLine, line.NewLine))))
+		}
+		column -= gutter + 1
+	}
+	side := func(line *DiffLine, color string) string {
+		text, number := "", ""
+		if line != nil {
+			text = line.Text
+			number = strconv.Itoa(max(line.OldLine, line.NewLine))
+		}
+		cell := colorize(fitColumn(text, column), color, opts.NoColor)
+		if gutter == 0 {
+			return cell
+		}
+		return colorize(fmt.Sprintf("%*s ", gutter, number), ansiDim, opts.NoColor) + cell
+	}
+
+	var removed, added []DiffLine
+	flush := func() {
+		for i := 0; i < max(len(removed), len(added)); i++ {
+			var left, right *DiffLine
+			indicator := "|"
+			switch {
+			case i >= len(added):
+				left, indicator = &removed[i], "<"
+			case i >= len(removed):
+				right, indicator = &added[i], ">"
+			default:
+				left, right = &removed[i], &added[i]
+				if left.Indent && right.Indent {
+					indicator = "~"
+				}
+			}
+			fmt.Fprintf(diffOut, "%s %s %s\n", side(left, ansiRed), indicator, strings.TrimRight(side(right, ansiGreen), " "))
+		}
+		removed, added = nil, nil
+	}
+	for _, line := range lines {
+		switch line.Kind {
+		case '-':
+			if len(added) > 0 {
+				flush()
+			}
+			removed = append(removed, line)
+		case '+':
+			added = append(added, line)
+		}
+	}
+	flush()
+	return nil
+}
@@ -0,0 +1,87 @@
+package comparegitfiles
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const diffSQLiteSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	run_timestamp TEXT NOT NULL,
+	repository    TEXT NOT NULL,
+	ref           TEXT NOT NULL,
+	files_changed INTEGER NOT NULL,
+	added         INTEGER NOT NULL,
+	removed       INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS file_diffs (
+	run_id        INTEGER NOT NULL REFERENCES runs(id),
+	file_path     TEXT NOT NULL,
+	status        TEXT NOT NULL,
+	added         INTEGER NOT NULL,
+	removed       INTEGER NOT NULL,
+	local_sha     TEXT NOT NULL,
+	remote_sha    TEXT NOT NULL,
+	run_timestamp TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS file_diffs_path ON file_diffs(file_path, run_timestamp);
+`
+
+// writeDiffSQLite appends the run and one row per examined file to the
+// SQLite database at path, creating the tables on first use.
+func writeDiffSQLite(path string, pkgdef *Config, results []DiffResult) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(diffSQLiteSchema); err != nil {
+		return fmt.Errorf("failed to create tables in %s: %w", path, err)
+	}
+
+	ref := resolveRef(pkgdef)
+	if ref == "" {
+		ref = "HEAD"
+	}
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	var changed []DiffResult
+	for _, result := range results {
+		if result.Status != statusIdentical {
+			changed = append(changed, result)
+		}
+	}
+	summary := summarize(changed)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`INSERT INTO runs (run_timestamp, repository, ref, files_changed, added, removed) VALUES (?, ?, ?, ?, ?, ?)`,
+		timestamp, pkgdef.Name, ref, summary.Files, summary.Added, summary.Removed)
+	if err != nil {
+		return fmt.Errorf("failed to write run to %s: %w", path, err)
+	}
+	runID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT INTO file_diffs (run_id, file_path, status, added, removed, local_sha, remote_sha, run_timestamp) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer stmt.Close()
+	for _, result := range results {
+		if _, err := stmt.Exec(runID, result.Path, result.Status, result.Added, result.Removed, result.LocalSha, result.RemoteSha, timestamp); err != nil {
+			return fmt.Errorf("failed to write %s to %s: %w", result.Path, path, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
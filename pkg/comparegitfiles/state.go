@@ -0,0 +1,103 @@
+package comparegitfiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const defaultStateFile = ".comparegitfiles.state.json"
+
+var compareState = &runState{}
+
+type stateEntry struct {
+	LocalSha  string `json:"local_sha"`
+	RemoteSha string `json:"remote_sha"`
+	Identical bool   `json:"identical,omitempty"`
+}
+
+type runState struct {
+	mu       sync.Mutex
+	enabled  bool
+	previous map[string]stateEntry
+}
+
+func loadState(path string) error {
+	compareState.mu.Lock()
+	defer compareState.mu.Unlock()
+	compareState.enabled = true
+	compareState.previous = make(map[string]stateEntry)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+	if err := json.Unmarshal(data, &compareState.previous); err != nil {
+		return fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	return nil
+}
+
+// unchanged reports whether filePath compared identical in the last run
+// with the same local and remote blobs, so that it is identical again
+// without fetching the remote content. Files that differed are compared on
+// every run, so that their drift keeps being reported.
+func (s *runState) unchanged(filePath, localSha, remoteSha string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.enabled {
+		return false
+	}
+	entry, ok := s.previous[filepath.ToSlash(filepath.Clean(filePath))]
+	return ok && entry.Identical && entry.LocalSha == localSha && entry.RemoteSha == remoteSha
+}
+
+// save writes the results of this run over the entries of the last one.
+func (s *runState) save(path string, results []DiffResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.enabled {
+		return nil
+	}
+	merged := make(map[string]stateEntry, len(s.previous)+len(results))
+	for key, entry := range s.previous {
+		merged[key] = entry
+	}
+	for _, result := range results {
+		if result.Status == statusMissingLocal || result.Status == statusRenamed {
+			delete(merged, result.Path)
+			continue
+		}
+		merged[result.Path] = stateEntry{
+			LocalSha:  result.LocalSha,
+			RemoteSha: result.RemoteSha,
+			Identical: result.Status == statusIdentical,
+		}
+	}
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".comparegitfiles.state-*")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package comparegitfiles
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// useState loads the state file at path into a fresh compareState until the
+// test ends.
+func useState(t *testing.T, path string) {
+	t.Helper()
+	saved := compareState
+	compareState = &runState{}
+	t.Cleanup(func() { compareState = saved })
+	if err := loadState(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStateUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	writeFile(t, path, `{
+		"same.txt":    {"local_sha": "l1", "remote_sha": "r1", "identical": true},
+		"changed.txt": {"local_sha": "l2", "remote_sha": "r2"},
+		"legacy.txt":  {"local_sha": "l3", "remote_sha": "r3"}
+	}`)
+	useState(t, path)
+
+	tests := []struct {
+		name                string
+		path, local, remote string
+		want                bool
+	}{
+		{name: "identical last run", path: "same.txt", local: "l1", remote: "r1", want: true},
+		{name: "identical but local changed", path: "same.txt", local: "l9", remote: "r1"},
+		{name: "identical but remote changed", path: "same.txt", local: "l1", remote: "r9"},
+		{name: "changed last run", path: "changed.txt", local: "l2", remote: "r2"},
+		{name: "entry without result", path: "legacy.txt", local: "l3", remote: "r3"},
+		{name: "not compared last run", path: "new.txt", local: "l4", remote: "r4"},
+		{name: "uncleaned path", path: "./same.txt", local: "l1", remote: "r1", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareState.unchanged(tt.path, tt.local, tt.remote); got != tt.want {
+				t.Errorf("unchanged(%s, %s, %s) = %v, want %v", tt.path, tt.local, tt.remote, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStateSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	writeFile(t, path, `{
+		"kept.txt": {"local_sha": "l0", "remote_sha": "r0", "identical": true},
+		"gone.txt": {"local_sha": "l5", "remote_sha": "r5", "identical": true}
+	}`)
+	useState(t, path)
+
+	err := compareState.save(path, []DiffResult{
+		{Path: "same.txt", LocalSha: "l1", RemoteSha: "r1", Status: statusIdentical},
+		{Path: "changed.txt", LocalSha: "l2", RemoteSha: "r2", Changes: 3, Status: statusChanged},
+		{Path: "gone.txt", RemoteSha: "r5", Status: statusMissingLocal},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]stateEntry
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]stateEntry{
+		"kept.txt":    {LocalSha: "l0", RemoteSha: "r0", Identical: true},
+		"same.txt":    {LocalSha: "l1", RemoteSha: "r1", Identical: true},
+		"changed.txt": {LocalSha: "l2", RemoteSha: "r2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("state = %+v, want %+v", got, want)
+	}
+}
+
+func TestStateKeepsReportingDrift(t *testing.T) {
+	remote := map[string]string{"conf/app.yaml": "a: 1\nb: 2\n"}
+	newFakeGitHub(t, remote)
+	dir := localRepo(t, map[string]string{"conf/app.yaml": "a: 1\nb: 3\n"})
+	path := filepath.Join(dir, defaultStateFile)
+	pkg := &Config{Name: "owner/repo"}
+	opts := &Options{Compare: true, Token: "secret", Format: "pretty", MaxSimilarity: 100}
+
+	for run := 1; run <= 2; run++ {
+		resetRunState()
+		useState(t, path)
+		err := compareFile(context.Background(), "conf/app.yaml", blobSha([]byte(remote["conf/app.yaml"])), opts, pkg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results := compareResults.sorted()
+		if len(results) != 1 || results[0].Changes != 2 {
+			t.Fatalf("run %d: changed files = %+v, want conf/app.yaml with 2 changes", run, results)
+		}
+		if err := compareState.save(path, compareResults.all()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package comparegitfiles
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"unicode"
+)
+
+func exceedsStreamThreshold(filePath string, opts *Options) bool {
+	if opts.StreamThreshold <= 0 {
+		return false
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return false
+	}
+	return info.Size() > opts.StreamThreshold
+}
+
+func streamBlobToFile(ctx context.Context, sha, token string, pkgdef *Config, dst *os.File) error {
+	if cached, ok := blobCache.Load(sha); ok {
+		_, err := io.WriteString(dst, cached.(string))
+		return err
+	}
+	provider, err := newContentProvider(pkgdef, token)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", provider.BlobURL(sha), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	provider.Authorize(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	r := bufio.NewReader(resp.Body)
+	if err := seekJSONString(r, "content"); err != nil {
+		return fmt.Errorf("failed to locate blob content: %w", err)
+	}
+	decoder := base64.NewDecoder(base64.StdEncoding, &jsonStringReader{r: r})
+	if _, err := io.Copy(dst, decoder); err != nil {
+		return fmt.Errorf("failed to stream blob: %w", err)
+	}
+	return nil
+}
+
+// seekJSONString advances r past the opening quote of the string value
+// stored under key, without buffering the rest of the document.
+func seekJSONString(r *bufio.Reader, key string) error {
+	pattern := []byte(`"` + key + `"`)
+	matched := 0
+	for matched < len(pattern) {
+		c, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch {
+		case c == pattern[matched]:
+			matched++
+		case c == pattern[0]:
+			matched = 1
+		default:
+			matched = 0
+		}
+	}
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n', ':':
+			continue
+		case '"':
+			return nil
+		default:
+			return fmt.Errorf("unexpected character %q after %s", c, key)
+		}
+	}
+}
+
+// jsonStringReader yields the unescaped bytes of a JSON string value and
+// returns io.EOF at its closing quote.
+type jsonStringReader struct {
+	r    *bufio.Reader
+	done bool
+}
+
+func (j *jsonStringReader) Read(p []byte) (int, error) {
+	if j.done {
+		return 0, io.EOF
+	}
+	n := 0
+	for n < len(p) {
+		c, err := j.r.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		}
+		switch c {
+		case '"':
+			j.done = true
+			if n == 0 {
+				return 0, io.EOF
+			}
+			return n, nil
+		case '\\':
+			esc, err := j.r.ReadByte()
+			if err != nil {
+				return n, io.ErrUnexpectedEOF
+			}
+			switch esc {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'u':
+				return n, errors.New("unsupported unicode escape in blob content")
+			default:
+				c = esc
+			}
+		}
+		p[n] = c
+		n++
+	}
+	return n, nil
+}
+
+// lineScanner reads the lines of a file one at a time, skipping the blank
+// lines strings.TrimSpace would drop from its start.
+type lineScanner struct {
+	s       *bufio.Scanner
+	skipped int
+	read    int
+	lines   int
+}
+
+func newLineScanner(r io.Reader) *lineScanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return &lineScanner{s: s}
+}
+
+// next returns the next line, or false once the file is exhausted.
+func (l *lineScanner) next() (string, bool) {
+	for l.s.Scan() {
+		line := l.s.Text()
+		if l.read == 0 {
+			if strings.TrimSpace(line) == "" {
+				l.skipped++
+				continue
+			}
+			line = strings.TrimLeftFunc(line, unicode.IsSpace)
+		}
+		l.read++
+		if strings.TrimSpace(line) != "" {
+			l.lines = l.read
+		}
+		return line, true
+	}
+	return "", false
+}
+
+// walkDiffReaders is walkDiffLines over two readers, holding one line of
+// each at a time. It returns the line counts countLines would report.
+func walkDiffReaders(r1, r2 io.Reader, indent bool, fn func(DiffLine) error) (lines1, lines2 int, err error) {
+	a, b := newLineScanner(r1), newLineScanner(r2)
+	for i := 0; ; i++ {
+		raw1, ok1 := a.next()
+		raw2, ok2 := b.next()
+		if !ok1 && !ok2 {
+			break
+		}
+		if err := walkLinePair(raw1, raw2, a.skipped+i+1, b.skipped+i+1, indent, fn); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := a.s.Err(); err != nil {
+		return 0, 0, err
+	}
+	if err := b.s.Err(); err != nil {
+		return 0, 0, err
+	}
+	return a.lines, b.lines, nil
+}
+
+// streamDiff compares a file above -stream-threshold without holding either
+// version in memory. The blob is streamed to a temporary file and the diff
+// lines to another one, which is queued as the Spill of a diffBlock.
+func streamDiff(ctx context.Context, filePath, localsha, gitsha string, opts *Options, pkgdef *Config) error {
+	blob, err := os.CreateTemp("", "comparegitfiles-blob-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(blob.Name())
+	defer blob.Close()
+
+	if err := streamBlobToFile(ctx, gitsha, opts.Token, pkgdef, blob); err != nil {
+		return err
+	}
+	if _, err := blob.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	local, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer local.Close()
+
+	diff, err := os.CreateTemp("", "comparegitfiles-diff-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	queued := false
+	defer func() {
+		diff.Close()
+		if !queued {
+			os.Remove(diff.Name())
+		}
+	}()
+
+	w := bufio.NewWriter(diff)
+	encoder := json.NewEncoder(w)
+	var added, removed, lines int
+	localLines, remoteLines, err := walkDiffReaders(bufio.NewReader(local), bufio.NewReader(blob), opts.IgnoreLeadingSpaces, func(line DiffLine) error {
+		lines++
+		switch {
+		case line.Indent:
+		case line.Kind == '+':
+			added++
+		default:
+			removed++
+		}
+		return encoder.Encode(line)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write diff: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write diff: %w", err)
+	}
+
+	block := diffBlock{Path: filePath, LocalSha: localsha, RemoteSha: gitsha, Spill: diff.Name(), Changes: lines}
+	totalDiffs := added + removed
+	if totalDiffs == 0 {
+		recordEqual(filePath, localsha, gitsha)
+		if lines > 0 {
+			queued = true
+			pendingDiffs.add(block)
+		}
+		return nil
+	}
+	similar := lineSimilarity(localLines, remoteLines, totalDiffs)
+	recordLineDifference(filePath, localsha, gitsha, added, removed, similar, "")
+	if !opts.similarityShown(similar) {
+		return nil
+	}
+	slog.Info("differences", "path", filePath, "changes", totalDiffs)
+	slog.Debug("similarity", "path", filePath, "similarity", fmt.Sprintf("%.0f%%", similar), "changes", totalDiffs)
+	queued = true
+	pendingDiffs.add(block)
+	return nil
+}
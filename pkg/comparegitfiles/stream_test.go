@@ -0,0 +1,144 @@
+package comparegitfiles
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWalkDiffReadersMatchesWalkDiffLines(t *testing.T) {
+	tests := []struct {
+		name          string
+		local, remote string
+		indent        bool
+	}{
+		{name: "identical", local: "a\nb\n", remote: "a\nb\n"},
+		{name: "changed line", local: "a\nb\nc\n", remote: "a\nB\nc\n"},
+		{name: "remote longer", local: "a\n", remote: "a\nb\nc\n"},
+		{name: "local longer", local: "a\nb\nc\n", remote: "a\n"},
+		{name: "leading blank lines", local: "\n\n  a\nb\n", remote: "a\nc\n"},
+		{name: "trailing blank lines", local: "a\nb\n\n\n", remote: "a\nb\nc\n"},
+		{name: "crlf", local: "a\r\nb\r\n", remote: "a\nb\n"},
+		{name: "indent only", local: "a:\n  b\n", remote: "a:\n    b\n", indent: true},
+		{name: "indent ignored", local: "a:\n  b\n", remote: "a:\n    b\n"},
+		{name: "empty", local: "", remote: "x\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want []DiffLine
+			if err := walkDiffLines(tt.local, tt.remote, tt.indent, func(l DiffLine) error {
+				want = append(want, l)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []DiffLine
+			localLines, remoteLines, err := walkDiffReaders(strings.NewReader(tt.local), strings.NewReader(tt.remote), tt.indent, func(l DiffLine) error {
+				got = append(got, l)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("walkDiffReaders = %v, want %v", got, want)
+			}
+			if localLines != countLines(tt.local) || remoteLines != countLines(tt.remote) {
+				t.Errorf("line counts = %d, %d, want %d, %d", localLines, remoteLines, countLines(tt.local), countLines(tt.remote))
+			}
+		})
+	}
+}
+
+func TestStreamDiff(t *testing.T) {
+	remote := map[string]string{
+		"conf/b.yaml":      "y: 1\nz: 2\n",
+		"conf/a.yaml":      "x: 1\n",
+		"conf/spaces.yaml": "same\n",
+	}
+	local := map[string]string{
+		"conf/b.yaml":      "y: 1\nz: 3\n",
+		"conf/a.yaml":      "x: 2\n",
+		"conf/spaces.yaml": "  same\n",
+	}
+	tests := []struct {
+		name    string
+		args    []string
+		ordered []string
+		absent  []string
+	}{
+		{
+			name:    "no color",
+			args:    []string{"-no-color"},
+			ordered: []string{"### conf/a.yaml (2 changes,", "-x: 2", "+x: 1", "### conf/b.yaml (2 changes,", "-z: 3", "+z: 2"},
+			absent:  []string{"\x1b[", "conf/spaces.yaml ("},
+		},
+		{
+			name:    "line numbers",
+			args:    []string{"-line-numbers", "-no-color"},
+			ordered: []string{"### conf/a.yaml", "L1: -x: 2", "L1: +x: 1", "### conf/b.yaml", "L2: -z: 3", "L2: +z: 2"},
+		},
+		{
+			name:    "stream output",
+			args:    []string{"-stream-output", "-no-color", "-concurrency", "1"},
+			ordered: []string{"### conf/a.yaml", "-x: 2", "+x: 1"},
+		},
+		{
+			name:    "min similarity",
+			args:    []string{"-no-color", "-min-similarity", "40"},
+			ordered: []string{"### conf/b.yaml", "-z: 3"},
+			absent:  []string{"### conf/a.yaml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gh, dir := newCompareRepo(t, remote, local, "conf")
+			args := append([]string{"compare", "-no-state", "-verbose", "-stream-threshold", "1"}, tt.args...)
+			stdout, stderr, code := runMain(t, dir, gh.URL, args...)
+			if code != 0 {
+				t.Fatalf("exit code %d\n%s", code, stderr)
+			}
+			rest := stdout
+			for _, want := range tt.ordered {
+				i := strings.Index(rest, want)
+				if i < 0 {
+					t.Fatalf("stdout does not contain %q after the previous lines:\n%s", want, stdout)
+				}
+				rest = rest[i+len(want):]
+			}
+			for _, absent := range tt.absent {
+				if strings.Contains(stdout, absent) {
+					t.Errorf("stdout contains %q:\n%s", absent, stdout)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamDiffResults(t *testing.T) {
+	gh, dir := newCompareRepo(t, map[string]string{"conf/spaces.yaml": "same\n", "conf/app.yaml": "a: 1\n"}, map[string]string{"conf/spaces.yaml": "  same\n", "conf/app.yaml": "a: 2\n"}, "conf")
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	stdout, stderr, code := runMain(t, dir, gh.URL, "compare", "-no-state", "-verbose", "-stream-threshold", "1", "-format", "json")
+	if code != 0 {
+		t.Fatalf("exit code %d\n%s", code, stderr)
+	}
+	var results []DiffResult
+	if err := json.Unmarshal([]byte(stdout), &results); err != nil {
+		t.Fatalf("%v:\n%s", err, stdout)
+	}
+	// conf/spaces.yaml only differs in surrounding whitespace
+	got := make(map[string]int)
+	for _, result := range results {
+		got[result.Path] = result.Changes
+	}
+	if want := map[string]int{"conf/app.yaml": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+	if temps, _ := filepath.Glob(filepath.Join(tmp, "comparegitfiles-*")); len(temps) != 0 {
+		t.Errorf("streamed diffs left behind: %v", temps)
+	}
+}
@@ -0,0 +1,77 @@
+package comparegitfiles
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+func isSymlink(content GithubContent) bool {
+	return content.Type == "symlink" || content.Mode == "120000"
+}
+
+func isLocalSymlink(filePath string) bool {
+	info, err := os.Lstat(filePath)
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
+
+func describeLink(target string, link bool) string {
+	if link {
+		return "Symlink target: " + target
+	}
+	return "Regular file"
+}
+
+// compareSymlink compares link targets instead of the content they point
+// to. A symlink blob stores its target, so the remote side falls back to the
+// blob when the api did not report the target.
+func compareSymlink(ctx context.Context, content GithubContent, filePath string, opts *Options, pkgdef *Config) error {
+	if err := sem.Acquire(ctx, 1); err != nil {
+		return fmt.Errorf("failed to acquire semaphore: %w", err)
+	}
+	defer sem.Release(1)
+
+	info, err := os.Lstat(filePath)
+	if os.IsNotExist(err) {
+		recordMissing(filePath, content.Sha)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	remoteLink := isSymlink(content)
+	remoteTarget := content.Target
+	if remoteLink && remoteTarget == "" {
+		if remoteTarget, err = getContentGitSha(ctx, content.Sha, opts.Token, pkgdef); err != nil {
+			return err
+		}
+	}
+	localLink := info.Mode()&os.ModeSymlink != 0
+	var localTarget, localSha string
+	if localLink {
+		if localTarget, err = os.Readlink(filePath); err != nil {
+			return err
+		}
+		localSha = gitObjectSHA("blob", []byte(localTarget))
+	} else if localSha, err = calculateLocalSHA(filePath); err != nil {
+		return err
+	}
+
+	if localLink == remoteLink && localTarget == remoteTarget {
+		recordEqual(filePath, localSha, content.Sha)
+		return nil
+	}
+	lines := []DiffLine{
+		{Kind: '-', Text: describeLink(localTarget, localLink), OldLine: 1},
+		{Kind: '+', Text: describeLink(remoteTarget, remoteLink), NewLine: 1},
+	}
+	diff := formatDiff(lines)
+	recordLineDifference(filePath, localSha, content.Sha, 1, 1, 0, diff)
+	slog.Info("differences", "path", filePath, "changes", 2)
+	if opts.showsDiffs() {
+		pendingDiffs.add(diffBlock{Path: filePath, LocalSha: localSha, RemoteSha: content.Sha, Lines: lines})
+	}
+	return nil
+}
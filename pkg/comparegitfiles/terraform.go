@@ -0,0 +1,87 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+func isTerraformFile(path string) bool {
+	return strings.HasSuffix(path, ".tf")
+}
+
+func canonicalizeTerraform(src, filename string) (string, error) {
+	file, diags := hclsyntax.ParseConfig([]byte(src), filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return "", fmt.Errorf("failed to parse %s: %s", filename, diags.Error())
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return "", fmt.Errorf("unexpected body type in %s", filename)
+	}
+	var builder strings.Builder
+	writeTerraformBody(&builder, body, file.Bytes, 0)
+	return builder.String(), nil
+}
+
+func writeTerraformBody(builder *strings.Builder, body *hclsyntax.Body, src []byte, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	names := make([]string, 0, len(body.Attributes))
+	for name := range body.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		expr := terraformExpression(body.Attributes[name].Expr, src)
+		fmt.Fprintf(builder, "%s%s = %s\n", indent, name, expr)
+	}
+
+	blocks := make([]string, 0, len(body.Blocks))
+	for _, block := range body.Blocks {
+		var blockBuilder strings.Builder
+		fmt.Fprintf(&blockBuilder, "%s%s", indent, block.Type)
+		for _, label := range block.Labels {
+			fmt.Fprintf(&blockBuilder, " %q", label)
+		}
+		blockBuilder.WriteString(" {\n")
+		writeTerraformBody(&blockBuilder, block.Body, src, depth+1)
+		fmt.Fprintf(&blockBuilder, "%s}\n", indent)
+		blocks = append(blocks, blockBuilder.String())
+	}
+	sort.Strings(blocks)
+	for _, block := range blocks {
+		builder.WriteString(block)
+	}
+}
+
+// terraformExpression renders an expression without its comments and with
+// normalized spacing, so only changes to its tokens are reported.
+func terraformExpression(expr hclsyntax.Expression, src []byte) string {
+	rng := expr.Range()
+	tokens, diags := hclsyntax.LexExpression(src[rng.Start.Byte:rng.End.Byte], rng.Filename, rng.Start)
+	if diags.HasErrors() {
+		return strings.TrimSpace(string(src[rng.Start.Byte:rng.End.Byte]))
+	}
+	var out []byte
+	prevEnd := -1
+	for _, token := range tokens {
+		switch token.Type {
+		case hclsyntax.TokenEOF:
+			continue
+		case hclsyntax.TokenComment, hclsyntax.TokenNewline:
+			prevEnd = -1
+			continue
+		}
+		if len(out) > 0 && token.Range.Start.Byte != prevEnd {
+			out = append(out, ' ')
+		}
+		out = append(out, token.Bytes...)
+		prevEnd = token.Range.End.Byte
+	}
+	return strings.TrimSpace(string(hclwrite.Format(out)))
+}
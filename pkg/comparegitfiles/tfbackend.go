@@ -0,0 +1,47 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// writeTerraformBackend writes an http backend block whose address is the
+// raw url of a synced .tfstate file. Terraform allows one backend per
+// configuration, so any further state files are listed as comments to
+// switch to.
+func writeTerraformBackend(path string, pkgdef *Config, files []syncedFile) error {
+	var states []syncedFile
+	for _, file := range files {
+		if strings.HasSuffix(file.Path, ".tfstate") && file.URL != "" {
+			states = append(states, file)
+		}
+	}
+	if len(states) == 0 {
+		return fmt.Errorf("no .tfstate files were synced from %s", pkgdef.Name)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by comparegitfiles -write-terraform-backend for %s.\n", pkgdef.Name)
+	b.WriteString("# Raw content urls are read only, set TF_HTTP_USERNAME and TF_HTTP_PASSWORD if the repository is private.\n")
+	b.WriteString("terraform {\n")
+	b.WriteString("  backend \"http\" {\n")
+	fmt.Fprintf(&b, "    address = %s\n", strconv.Quote(states[0].URL))
+	b.WriteString("  }\n")
+	b.WriteString("}\n")
+	if len(states) > 1 {
+		b.WriteString("\n# Other synced state files:\n")
+		for _, state := range states[1:] {
+			fmt.Fprintf(&b, "#   %s: address = %s\n", filepath.ToSlash(filepath.Clean(state.Path)), strconv.Quote(state.URL))
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write terraform backend: %w", err)
+	}
+	slog.Info("terraform backend written", "path", path)
+	return nil
+}
@@ -0,0 +1,129 @@
+package comparegitfiles
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowHandler delays the headers or, with afterHeaders, the body of the
+// responses of next to requests with a path containing slowPath.
+func slowHandler(next http.Handler, slowPath string, delay time.Duration, afterHeaders bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Path, slowPath) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if afterHeaders {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+		}
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestTimeoutTransport(t *testing.T) {
+	body := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "content") })
+	tests := []struct {
+		name        string
+		handler     http.Handler
+		timeout     time.Duration
+		parent      time.Duration
+		wantErr     bool
+		wantBodyErr bool
+	}{
+		{name: "fast response", handler: body, timeout: time.Second},
+		{name: "slow headers", handler: slowHandler(body, "/", 5*time.Second, false), timeout: 50 * time.Millisecond, wantErr: true},
+		{name: "slow body", handler: slowHandler(body, "/", 5*time.Second, true), timeout: 50 * time.Millisecond, wantBodyErr: true},
+		{name: "within timeout", handler: slowHandler(body, "/", 20*time.Millisecond, false), timeout: 5 * time.Second},
+		{name: "parent deadline first", handler: slowHandler(body, "/", 5*time.Second, false), timeout: 5 * time.Second, parent: 50 * time.Millisecond, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			t.Cleanup(server.Close)
+			ctx := context.Background()
+			if tt.parent > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.parent)
+				defer cancel()
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			resp, err := (&timeoutTransport{next: http.DefaultTransport, timeout: tt.timeout}).RoundTrip(req)
+			if tt.wantErr {
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("RoundTrip error = %v, want a deadline exceeded error", err)
+				}
+				if elapsed := time.Since(start); elapsed > 2*time.Second {
+					t.Errorf("RoundTrip returned after %s", elapsed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if tt.wantBodyErr {
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("reading the body: %v, want a deadline exceeded error", err)
+				}
+				return
+			}
+			if err != nil || string(data) != "content" {
+				t.Errorf("body = %q, %v, want content", data, err)
+			}
+		})
+	}
+}
+
+func TestRunTimeouts(t *testing.T) {
+	tests := []struct {
+		name     string
+		slowPath string
+		args     []string
+		stderr   string
+	}{
+		{name: "request timeout", slowPath: "/git/blobs/", args: []string{"-request-timeout", "100ms"}, stderr: "context deadline exceeded"},
+		{name: "total timeout", slowPath: "/git/blobs/", args: []string{"-request-timeout", "0", "-total-timeout", "100ms"}, stderr: "within -total-timeout 100ms"},
+		{
+			name: "total timeout after the comparison", slowPath: "/commits",
+			args:   []string{"-request-timeout", "0", "-total-timeout", "1s", "-write-versions-file", "versions.json"},
+			stderr: "context deadline exceeded",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gh, dir := newCompareRepo(t, driftRemote, driftLocal, "conf")
+			slow := httptest.NewServer(slowHandler(gh, tt.slowPath, 10*time.Second, false))
+			t.Cleanup(slow.Close)
+
+			start := time.Now()
+			_, stderr, code := runMain(t, dir, slow.URL, append([]string{"compare", "-no-state"}, tt.args...)...)
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("run took %s, the timeout did not stop it", elapsed)
+			}
+			if code != 1 {
+				t.Errorf("exit code = %d, want 1\n%s", code, stderr)
+			}
+			if !strings.Contains(stderr, tt.stderr) {
+				t.Errorf("stderr does not contain %q:\n%s", tt.stderr, stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package comparegitfiles
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// buildTLSConfig assembles the tls.Config for the -tls-* flags, or returns
+// nil to keep the transport defaults when none is given. The CA bundle is
+// trusted in addition to the system roots.
+func buildTLSConfig(opts *Options) (*tls.Config, error) {
+	if opts.TLSCACert == "" && opts.TLSCert == "" && opts.TLSKey == "" && !opts.TLSSkipVerify {
+		return nil, nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opts.TLSCACert != "" {
+		pem, err := os.ReadFile(opts.TLSCACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", opts.TLSCACert)
+		}
+		config.RootCAs = pool
+	}
+	if opts.TLSCert != "" || opts.TLSKey != "" {
+		if opts.TLSCert == "" || opts.TLSKey == "" {
+			return nil, fmt.Errorf("-tls-cert and -tls-key must be given together")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.TLSCert, opts.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if opts.TLSSkipVerify {
+		slog.Warn("TLS CERTIFICATE VERIFICATION IS DISABLED: -tls-skip-verify accepts any certificate, so the token and every response can be intercepted. Prefer -tls-ca-cert")
+		config.InsecureSkipVerify = true
+	}
+	return config, nil
+}
@@ -0,0 +1,266 @@
+package comparegitfiles
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA issues certificates for the tls tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "comparegitfiles test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))}
+}
+
+// issue returns a certificate signed by the CA for usage, with its PEM
+// encoded certificate and key.
+func (ca *testCA) issue(t *testing.T, usage x509.ExtKeyUsage) (tls.Certificate, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, string(certPEM), string(keyPEM)
+}
+
+// newTLSServer serves handler with a certificate of ca and, when clientCA
+// is set, requires a client certificate it signed.
+func newTLSServer(t *testing.T, handler http.Handler, ca, clientCA *testCA) *httptest.Server {
+	t.Helper()
+	cert, _, _ := ca.issue(t, x509.ExtKeyUsageServerAuth)
+	srv := httptest.NewUnstartedServer(handler)
+	// rejected handshakes are expected
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	if clientCA != nil {
+		pool := x509.NewCertPool()
+		pool.AddCert(clientCA.cert)
+		srv.TLS.ClientCAs = pool
+		srv.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestBuildTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	_, certPEM, keyPEM := ca.issue(t, x509.ExtKeyUsageClientAuth)
+	files := map[string]string{"ca.pem": ca.pem, "client.pem": certPEM, "client.key": keyPEM, "empty.pem": "not a certificate\n"}
+	for name, content := range files {
+		writeFile(t, filepath.Join(dir, name), content)
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	tests := []struct {
+		name    string
+		opts    Options
+		wantNil bool
+		check   func(t *testing.T, config *tls.Config)
+		wantErr string
+	}{
+		{name: "no flags", wantNil: true},
+		{
+			name: "ca bundle",
+			opts: Options{TLSCACert: path("ca.pem")},
+			check: func(t *testing.T, config *tls.Config) {
+				if config.RootCAs == nil || config.InsecureSkipVerify {
+					t.Errorf("config = %+v, want the CA pool and verification", config)
+				}
+			},
+		},
+		{
+			name: "client certificate",
+			opts: Options{TLSCert: path("client.pem"), TLSKey: path("client.key")},
+			check: func(t *testing.T, config *tls.Config) {
+				if len(config.Certificates) != 1 || config.RootCAs != nil {
+					t.Errorf("config = %+v, want one client certificate and the system roots", config)
+				}
+			},
+		},
+		{
+			name: "skip verify",
+			opts: Options{TLSSkipVerify: true},
+			check: func(t *testing.T, config *tls.Config) {
+				if !config.InsecureSkipVerify {
+					t.Error("InsecureSkipVerify not set")
+				}
+			},
+		},
+		{name: "missing ca bundle", opts: Options{TLSCACert: path("missing.pem")}, wantErr: "failed to read CA bundle"},
+		{name: "ca bundle without certificates", opts: Options{TLSCACert: path("empty.pem")}, wantErr: "no PEM certificates found"},
+		{name: "cert without key", opts: Options{TLSCert: path("client.pem")}, wantErr: "-tls-cert and -tls-key must be given together"},
+		{name: "key without cert", opts: Options{TLSKey: path("client.key")}, wantErr: "-tls-cert and -tls-key must be given together"},
+		{name: "mismatched key pair", opts: Options{TLSCert: path("client.pem"), TLSKey: path("ca.pem")}, wantErr: "failed to load client certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logged := useLogger(t, "text", "", false)
+			config, err := buildTLSConfig(&tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("buildTLSConfig = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantNil {
+				if config != nil {
+					t.Errorf("config = %+v, want nil", config)
+				}
+				return
+			}
+			if config.MinVersion != tls.VersionTLS12 {
+				t.Errorf("MinVersion = %x, want TLS 1.2", config.MinVersion)
+			}
+			tt.check(t, config)
+			warned := strings.Contains(logged.String(), "level=WARN") && strings.Contains(logged.String(), "TLS CERTIFICATE VERIFICATION IS DISABLED")
+			if warned != tt.opts.TLSSkipVerify {
+				t.Errorf("warning logged = %v, want %v:\n%s", warned, tt.opts.TLSSkipVerify, logged)
+			}
+		})
+	}
+}
+
+func TestTLSConnections(t *testing.T) {
+	dir := t.TempDir()
+	serverCA, clientCA, otherCA := newTestCA(t), newTestCA(t), newTestCA(t)
+	_, clientPEM, clientKey := clientCA.issue(t, x509.ExtKeyUsageClientAuth)
+	_, otherPEM, otherKey := otherCA.issue(t, x509.ExtKeyUsageClientAuth)
+	files := map[string]string{
+		"ca.pem": serverCA.pem, "other-ca.pem": otherCA.pem,
+		"client.pem": clientPEM, "client.key": clientKey,
+		"other.pem": otherPEM, "other.key": otherKey,
+	}
+	for name, content := range files {
+		writeFile(t, filepath.Join(dir, name), content)
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+	server := newTLSServer(t, ok, serverCA, nil)
+	mutualServer := newTLSServer(t, ok, serverCA, clientCA)
+
+	tests := []struct {
+		name    string
+		server  *httptest.Server
+		opts    Options
+		wantErr string
+	}{
+		{name: "custom ca", server: server, opts: Options{TLSCACert: path("ca.pem")}},
+		{name: "system roots only", server: server, wantErr: "certificate signed by unknown authority"},
+		{name: "other ca", server: server, opts: Options{TLSCACert: path("other-ca.pem")}, wantErr: "certificate signed by unknown authority"},
+		{name: "skip verify", server: server, opts: Options{TLSSkipVerify: true}},
+		{name: "mutual tls", server: mutualServer, opts: Options{TLSCACert: path("ca.pem"), TLSCert: path("client.pem"), TLSKey: path("client.key")}},
+		{name: "mutual tls without client certificate", server: mutualServer, opts: Options{TLSCACert: path("ca.pem")}, wantErr: "certificate"},
+		{name: "mutual tls with untrusted client certificate", server: mutualServer, opts: Options{TLSCACert: path("ca.pem"), TLSCert: path("other.pem"), TLSKey: path("other.key")}, wantErr: "certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useLogger(t, "text", "", false)
+			config, err := buildTLSConfig(&tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			httpConfig := defaultHTTPClientConfig()
+			httpConfig.TLSConfig = config
+			resp, err := NewHTTPClient(httpConfig).Get(tt.server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("request failed: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("request error = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompareOverTLS(t *testing.T) {
+	gh, dir := newCompareRepo(t, driftRemote, driftLocal, "conf")
+	ca := newTestCA(t)
+	server := newTLSServer(t, gh, ca, nil)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeFile(t, caFile, ca.pem)
+
+	if _, stderr, code := runMain(t, dir, server.URL, "compare", "-no-state"); code == 0 || !strings.Contains(stderr, "certificate") {
+		t.Errorf("compare without -tls-ca-cert: exit code %d, want a certificate error\n%s", code, stderr)
+	}
+	stdout, stderr, code := runMain(t, dir, server.URL, "compare", "-no-state", "-tls-ca-cert", caFile)
+	if code != 0 {
+		t.Fatalf("exit code %d\n%s", code, stderr)
+	}
+	if !strings.Contains(stdout, "conf/app.yaml | 2 +-") {
+		t.Errorf("stdout does not contain the diff stat:\n%s", stdout)
+	}
+}
@@ -0,0 +1,315 @@
+package comparegitfiles
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const githubContentsLimit = 1000
+
+var treeSHAs sync.Map
+
+type gitTree struct {
+	Sha       string         `json:"sha"`
+	Tree      []gitTreeEntry `json:"tree"`
+	Truncated bool           `json:"truncated"`
+}
+
+type gitTreeEntry struct {
+	Path string `json:"path"`
+	Mode string `json:"mode"`
+	Type string `json:"type"`
+	Sha  string `json:"sha"`
+	Size int64  `json:"size"`
+}
+
+func cacheTreeSHAs(contents []GithubContent) {
+	for _, content := range contents {
+		if content.Type == "dir" && content.Sha != "" {
+			treeSHAs.Store(content.Path, content.Sha)
+		}
+	}
+}
+
+func shouldUseTreesAPI(path string, contents []GithubContent, opts *Options, pkgdef *Config) bool {
+	if providerName(pkgdef) != "github" {
+		return false
+	}
+	if len(contents) == 1 && contents[0].Type == "file" && contents[0].Path == strings.Trim(path, "/") {
+		return false
+	}
+	return opts.UseTreesAPI || len(contents) >= githubContentsLimit
+}
+
+func lookupTreeSHA(ctx context.Context, dir string, opts *Options, pkgdef *Config) (string, error) {
+	dir = strings.Trim(dir, "/")
+	if sha, ok := treeSHAs.Load(dir); ok {
+		return sha.(string), nil
+	}
+	if dir == "" || dir == "." {
+		ref := resolveRef(pkgdef)
+		if ref == "" {
+			ref = "HEAD"
+		}
+		return ref, nil
+	}
+	parent := path.Dir(dir)
+	if parent == "." {
+		parent = ""
+	}
+	if _, err := listContents(ctx, parent, opts, pkgdef); err != nil {
+		return "", err
+	}
+	if sha, ok := treeSHAs.Load(dir); ok {
+		return sha.(string), nil
+	}
+	return "", fmt.Errorf("failed to find tree sha for %s", dir)
+}
+
+// listTreeRecursive returns every file and directory below treeSHA, with
+// paths prefixed by dir. When the recursive listing is truncated, which the
+// api does above 100,000 entries or 7 MB, the tree is walked one directory
+// at a time instead.
+func listTreeRecursive(ctx context.Context, treeSHA, dir string, opts *Options, pkgdef *Config) ([]GithubContent, error) {
+	provider, err := newContentProvider(pkgdef, opts.Token)
+	if err != nil {
+		return nil, err
+	}
+	dir = strings.Trim(dir, "/")
+	tree, err := getTree(ctx, provider, treeSHA, dir, true, pkgdef)
+	if err != nil {
+		return nil, err
+	}
+	if tree.Truncated {
+		slog.Info("tree listing was truncated by the github api, listing each directory", "dir", dir)
+		return walkTree(ctx, provider, treeSHA, dir, pkgdef)
+	}
+	return treeContents(provider, dir, tree.Tree), nil
+}
+
+// walkTree lists treeSHA and each of its subtrees without recursive=1.
+func walkTree(ctx context.Context, provider ContentProvider, treeSHA, dir string, pkgdef *Config) ([]GithubContent, error) {
+	tree, err := getTree(ctx, provider, treeSHA, dir, false, pkgdef)
+	if err != nil {
+		return nil, err
+	}
+	if tree.Truncated {
+		return nil, fmt.Errorf("tree listing of %s was truncated by the github api", dir)
+	}
+	contents := treeContents(provider, dir, tree.Tree)
+	for _, content := range contents {
+		if content.Type != "dir" {
+			continue
+		}
+		children, err := walkTree(ctx, provider, content.Sha, content.Path, pkgdef)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, children...)
+	}
+	return contents, nil
+}
+
+func getTree(ctx context.Context, provider ContentProvider, treeSHA, dir string, recursive bool, pkgdef *Config) (*gitTree, error) {
+	url := fmt.Sprintf("%s/repos/%s/git/trees/%s", githubAPI, pkgdef.Name, treeSHA)
+	if recursive {
+		url += "?recursive=1"
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	provider.Authorize(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d -> %s", resp.StatusCode, dir)
+	}
+
+	var tree gitTree
+	if err := json.NewDecoder(resp.Body).Decode(&tree); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &tree, nil
+}
+
+// treeContents converts the entries of a tree listed at dir, skipping
+// submodules.
+func treeContents(provider ContentProvider, dir string, entries []gitTreeEntry) []GithubContent {
+	contents := make([]GithubContent, 0, len(entries))
+	for _, entry := range entries {
+		fullPath := path.Join(dir, entry.Path)
+		content := GithubContent{
+			Name: path.Base(entry.Path),
+			Path: fullPath,
+			Sha:  entry.Sha,
+			Mode: entry.Mode,
+			Size: entry.Size,
+		}
+		switch entry.Type {
+		case "tree":
+			content.Type = "dir"
+		case "blob":
+			content.Type = "file"
+			content.DownloadURL = provider.RawURL(fullPath)
+		default:
+			continue
+		}
+		contents = append(contents, content)
+	}
+	return contents
+}
+
+func fetchTreeRecursive(ctx context.Context, treeSHA, dir, baseDir string, opts *Options, pkgdef *Config) error {
+	contents, err := listTreeRecursive(ctx, treeSHA, dir, opts, pkgdef)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(contents))
+
+	for _, content := range contents {
+		if checkIgnore(content.Path) {
+			continue
+		}
+		fileGraph.addChildren(path.Dir(content.Path), []GithubContent{content})
+		if content.Type != "file" {
+			continue
+		}
+
+		wg.Add(1)
+		go func(content GithubContent) {
+			defer wg.Done()
+			if err := fetchFile(ctx, content, baseDir, opts, pkgdef); err != nil {
+				errs <- err
+			}
+		}(content)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fetchWithTreesAPI(ctx context.Context, dir, baseDir string, opts *Options, pkgdef *Config) error {
+	treeSHA, err := lookupTreeSHA(ctx, dir, opts, pkgdef)
+	if err != nil {
+		return err
+	}
+	return fetchTreeRecursive(ctx, treeSHA, dir, baseDir, opts, pkgdef)
+}
+
+// calculateLocalTreeSHA hashes dir the way git hashes a tree object, so that
+// it can be compared with the sha the api reports for a directory. Empty
+// directories are skipped like git does.
+func calculateLocalTreeSHA(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	type treeEntry struct {
+		mode, name, sortKey string
+		sha                 []byte
+	}
+	var tree []treeEntry
+	for _, entry := range entries {
+		full := filepath.Join(dir, entry.Name())
+		info, err := os.Lstat(full)
+		if err != nil {
+			return "", err
+		}
+		var mode, sha string
+		switch {
+		case info.IsDir():
+			if entry.Name() == ".git" {
+				continue
+			}
+			mode = "40000"
+			sha, err = calculateLocalTreeSHA(full)
+			if sha == "" && err == nil {
+				continue
+			}
+		case info.Mode()&os.ModeSymlink != 0:
+			mode = "120000"
+			var target string
+			if target, err = os.Readlink(full); err == nil {
+				sha = gitObjectSHA("blob", []byte(target))
+			}
+		default:
+			mode = "100644"
+			if info.Mode()&0111 != 0 {
+				mode = "100755"
+			}
+			sha, err = calculateLocalSHA(full)
+		}
+		if err != nil {
+			return "", err
+		}
+		raw, err := hex.DecodeString(sha)
+		if err != nil {
+			return "", err
+		}
+		sortKey := entry.Name()
+		if mode == "40000" {
+			sortKey += "/"
+		}
+		tree = append(tree, treeEntry{mode, entry.Name(), sortKey, raw})
+	}
+	if len(tree) == 0 {
+		return "", nil
+	}
+	sort.Slice(tree, func(i, j int) bool {
+		return tree[i].sortKey < tree[j].sortKey
+	})
+	var buf bytes.Buffer
+	for _, entry := range tree {
+		fmt.Fprintf(&buf, "%s %s\x00", entry.mode, entry.name)
+		buf.Write(entry.sha)
+	}
+	return gitObjectSHA("tree", buf.Bytes()), nil
+}
+
+func gitObjectSHA(kind string, content []byte) string {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "%s %d\x00", kind, len(content))
+	hash.Write(content)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// unchangedDir reports whether -diff-only-changed-dirs can skip content
+// because the local directory hashes to the tree sha the api reported.
+func unchangedDir(content GithubContent, baseDir string, opts *Options) bool {
+	if !opts.DiffOnlyChangedDirs || !opts.Compare || content.Sha == "" {
+		return false
+	}
+	localSha, err := calculateLocalTreeSHA(filepath.Join(baseDir, content.Path))
+	if err != nil || localSha != content.Sha {
+		return false
+	}
+	slog.Debug("skipping unchanged directory", "path", content.Path)
+	return true
+}
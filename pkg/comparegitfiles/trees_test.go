@@ -0,0 +1,90 @@
+package comparegitfiles
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestListTreeRecursive(t *testing.T) {
+	// trees maps a tree sha to its entries, as listed without recursive=1
+	trees := map[string][]gitTreeEntry{
+		"root": {
+			{Path: "a.txt", Mode: "100644", Type: "blob", Sha: "a1", Size: 1},
+			{Path: "sub", Mode: "040000", Type: "tree", Sha: "sub1"},
+			{Path: "module", Mode: "160000", Type: "commit", Sha: "c1"},
+		},
+		"sub1": {
+			{Path: "b.txt", Mode: "100644", Type: "blob", Sha: "b1", Size: 2},
+		},
+	}
+	recursive := []gitTreeEntry{
+		{Path: "a.txt", Mode: "100644", Type: "blob", Sha: "a1", Size: 1},
+		{Path: "sub", Mode: "040000", Type: "tree", Sha: "sub1"},
+		{Path: "sub/b.txt", Mode: "100644", Type: "blob", Sha: "b1", Size: 2},
+	}
+
+	tests := []struct {
+		name      string
+		truncated map[string]bool
+		wantWalk  bool
+		wantErr   string
+	}{
+		{name: "complete listing"},
+		{name: "truncated listing", truncated: map[string]bool{"recursive": true}, wantWalk: true},
+		{name: "truncated directory", truncated: map[string]bool{"recursive": true, "sub1": true}, wantErr: "tree listing of conf/sub was truncated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var walked []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				sha, ok := strings.CutPrefix(r.URL.Path, "/repos/owner/repo/git/trees/")
+				if !ok {
+					http.NotFound(w, r)
+					return
+				}
+				if r.URL.Query().Get("recursive") == "1" {
+					json.NewEncoder(w).Encode(gitTree{Sha: sha, Tree: recursive, Truncated: tt.truncated["recursive"]})
+					return
+				}
+				walked = append(walked, sha)
+				json.NewEncoder(w).Encode(gitTree{Sha: sha, Tree: trees[sha], Truncated: tt.truncated[sha]})
+			}))
+			t.Cleanup(srv.Close)
+			saved := githubAPI
+			githubAPI = srv.URL
+			t.Cleanup(func() { githubAPI = saved })
+
+			pkg := &Config{Name: "owner/repo"}
+			got, err := listTreeRecursive(context.Background(), "root", "conf", &Options{Token: "secret"}, pkg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("listTreeRecursive error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			provider, err := newContentProvider(pkg, "secret")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []GithubContent{
+				{Name: "a.txt", Path: "conf/a.txt", Type: "file", Sha: "a1", Mode: "100644", Size: 1, DownloadURL: provider.RawURL("conf/a.txt")},
+				{Name: "sub", Path: "conf/sub", Type: "dir", Sha: "sub1", Mode: "040000"},
+				{Name: "b.txt", Path: "conf/sub/b.txt", Type: "file", Sha: "b1", Mode: "100644", Size: 2, DownloadURL: provider.RawURL("conf/sub/b.txt")},
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("listTreeRecursive = %+v, want %+v", got, want)
+			}
+			if got := len(walked) > 0; got != tt.wantWalk {
+				t.Errorf("walked the tree = %v, want %v", got, tt.wantWalk)
+			}
+		})
+	}
+}
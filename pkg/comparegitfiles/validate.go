@@ -0,0 +1,80 @@
+package comparegitfiles
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+var errNoFiles = errors.New("files must list at least one path to compare, e.g. \"files\": [\"src\"]")
+
+// ValidateConfig checks a parsed diffs.json and returns every problem found
+// rather than stopping at the first one.
+func ValidateConfig(pkg *Config) []error {
+	var errs []error
+	if pkg == nil {
+		return []error{errors.New("config is empty, expected an object with at least \"name\" and \"files\"")}
+	}
+
+	parts := strings.Split(pkg.Name, "/")
+	validName := len(parts) == 2 || (providerName(pkg) == "gitlab" && len(parts) > 2)
+	for _, part := range parts {
+		if part == "" || strings.ContainsAny(part, " \t") {
+			validName = false
+		}
+	}
+	switch {
+	case pkg.Name == "":
+		errs = append(errs, errors.New("name is required, set it to 'owner/repo'"))
+	case !validName:
+		errs = append(errs, fmt.Errorf("name must be in 'owner/repo' format, got '%s'", pkg.Name))
+	}
+
+	if len(pkg.Files) == 0 {
+		errs = append(errs, errNoFiles)
+	}
+	for i, file := range pkg.Files {
+		if strings.TrimSpace(file) == "" {
+			errs = append(errs, fmt.Errorf("files[%d] is empty, remove it or set a repository path", i))
+		} else if isGlobPattern(file) && !doublestar.ValidatePattern(file) {
+			errs = append(errs, fmt.Errorf("files[%d] '%s' is not a valid glob pattern", i, file))
+		}
+	}
+
+	if pkg.Branch != "" {
+		if err := validateRefName(pkg.Branch); err != nil {
+			errs = append(errs, fmt.Errorf("branch '%s' is not a valid git ref name: %w", pkg.Branch, err))
+		}
+	}
+
+	_, patternErrs := compileIgnoreRules(pkg.Ignore, "ignore", false)
+	errs = append(errs, patternErrs...)
+
+	if _, err := newContentProvider(pkg, ""); err != nil {
+		errs = append(errs, fmt.Errorf("%w, use 'github', 'gitlab' or 'bitbucket'", err))
+	}
+	return errs
+}
+
+// validateRefName applies the rules of git check-ref-format to a branch name.
+func validateRefName(ref string) error {
+	switch {
+	case strings.ContainsAny(ref, " \t~^:?*[\\"):
+		return errors.New("it must not contain spaces or any of ~ ^ : ? * [ \\")
+	case strings.Contains(ref, ".."):
+		return errors.New("it must not contain '..'")
+	case strings.Contains(ref, "@{"):
+		return errors.New("it must not contain '@{'")
+	case strings.Contains(ref, "//"):
+		return errors.New("it must not contain '//'")
+	case strings.HasPrefix(ref, "/") || strings.HasSuffix(ref, "/"):
+		return errors.New("it must not start or end with '/'")
+	case strings.HasPrefix(ref, ".") || strings.HasSuffix(ref, "."):
+		return errors.New("it must not start or end with '.'")
+	case strings.HasSuffix(ref, ".lock"):
+		return errors.New("it must not end with '.lock'")
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package comparegitfiles
+
+import "fmt"
+
+var (
+	Version   = "dev"
+	Commit    = "none"
+	BuildDate = "unknown"
+)
+
+func versionString() string {
+	return fmt.Sprintf("comparegitfiles %s (%s, %s)", Version, Commit, BuildDate)
+}
@@ -0,0 +1,32 @@
+package comparegitfiles
+
+import (
+	"regexp"
+	"strings"
+)
+
+var defaultVersionCommentPatterns = []string{
+	`(?i)copyright|\(c\)|©`,
+	`(?i)^\W*version\b`,
+	`(?i)auto-?generated|code generated|do not edit`,
+}
+
+// blankVersionComments empties comment lines among the first maxLines lines
+// that match one of patterns. Lines are blanked rather than removed so that
+// the rest of the file stays aligned for the line-by-line diff.
+func blankVersionComments(content string, patterns []*regexp.Regexp, maxLines int) string {
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(lines) && i < maxLines; i++ {
+		line := strings.TrimSpace(lines[i])
+		if !hasCommentPrefix(line) {
+			continue
+		}
+		for _, pattern := range patterns {
+			if pattern.MatchString(line) {
+				lines[i] = ""
+				break
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
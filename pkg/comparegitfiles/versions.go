@@ -0,0 +1,99 @@
+package comparegitfiles
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var errNoCommit = errors.New("no commit found")
+
+// lastCommit returns the sha of the newest commit on the configured ref
+// that touched path.
+func lastCommit(ctx context.Context, path string, opts *Options, pkgdef *Config) (string, error) {
+	if err := sem.Acquire(ctx, 1); err != nil {
+		return "", fmt.Errorf("failed to acquire semaphore: %w", err)
+	}
+	defer sem.Release(1)
+
+	provider, err := newContentProvider(pkgdef, opts.Token)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", provider.LastCommitURL(path), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	provider.Authorize(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code: %d -> %s", resp.StatusCode, path)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	sha, err := provider.DecodeLastCommit(body)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return sha, nil
+}
+
+// writeVersionsFile writes a JSON object that maps every file to the remote
+// commit that last touched it, one commits api request per file.
+func writeVersionsFile(ctx context.Context, output string, files []string, opts *Options, pkgdef *Config) error {
+	versions := make(map[string]string, len(files))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(chan error, len(files))
+
+	for _, file := range files {
+		wg.Add(1)
+		go func(file string) {
+			defer wg.Done()
+			path := filepath.ToSlash(filepath.Clean(file))
+			sha, err := lastCommit(ctx, path, opts, pkgdef)
+			if err != nil {
+				errs <- err
+				return
+			}
+			mu.Lock()
+			versions[path] = sha
+			mu.Unlock()
+		}(file)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return fmt.Errorf("failed to look up file versions: %w", err)
+		}
+	}
+
+	// encoding/json sorts the keys
+	data, err := json.MarshalIndent(versions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode versions file: %w", err)
+	}
+	if err := os.WriteFile(output, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write versions file: %w", err)
+	}
+	slog.Info("versions file written", "path", output, "files", len(versions))
+	return nil
+}
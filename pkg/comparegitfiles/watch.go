@@ -0,0 +1,76 @@
+package comparegitfiles
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// watchLoop calls run forever, sleeping interval plus a random delay of up
+// to jitter between calls so that several instances do not poll in step.
+// Errors are logged and the next iteration still runs.
+func watchLoop(interval, jitter time.Duration, run func() error) {
+	for {
+		if err := run(); err != nil {
+			slog.Error("watch run failed", "err", err)
+		}
+		delay := interval
+		if jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(jitter)))
+		}
+		time.Sleep(delay)
+	}
+}
+
+// resetRunState clears what a previous run collected so each watch
+// iteration reflects the current remote.
+func resetRunState() {
+	fileGraph = &dotGraph{
+		nodes: make(map[string]string),
+		edges: make(map[[2]string]bool),
+	}
+	noiseReport = &noiseAnalyzer{}
+	compareResults = &resultCollector{}
+	syncedFiles = &syncedFileSet{}
+	patches = &patchSet{}
+	pendingDiffs = &diffQueue{}
+	blobCache.Clear()
+	treeSHAs.Clear()
+	downloadedSHAs.Clear()
+	remoteTree.once = sync.Once{}
+	remoteTree.contents, remoteTree.err = nil, nil
+}
+
+// runOnChange runs command through the shell after a watch iteration that
+// found differences, describing them in CHANGED_FILES (colon-separated),
+// REPO, BRANCH and DIFF_COUNT, the total number of changed lines.
+func runOnChange(command string, pkgdef *Config, results []DiffResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	var paths []string
+	changes := 0
+	for _, result := range results {
+		paths = append(paths, result.Path)
+		changes += result.Changes
+	}
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"CHANGED_FILES="+strings.Join(paths, ":"),
+		"REPO="+pkgdef.Name,
+		"BRANCH="+resolveRef(pkgdef),
+		"DIFF_COUNT="+strconv.Itoa(changes),
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("-watch-on-change command failed: %w", err)
+	}
+	return nil
+}
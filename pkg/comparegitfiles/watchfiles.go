@@ -0,0 +1,152 @@
+package comparegitfiles
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/fsnotify/fsnotify"
+)
+
+const watchDebounce = 500 * time.Millisecond
+
+// watchRoots returns the local directories that hold the configured files:
+// each directory entry, the parent of each file entry and the static prefix
+// of each glob.
+func watchRoots(pkg *Config, opts *Options) []string {
+	entries := pkg.Files
+	if path := strings.TrimSpace(opts.Path); path != "" {
+		entries = []string{path}
+	}
+	var roots []string
+	for _, entry := range entries {
+		if isGlobPattern(entry) {
+			entry, _ = doublestar.SplitPattern(entry)
+		}
+		local := filepath.Join(depsDir, entry)
+		if info, err := os.Stat(local); err == nil && !info.IsDir() {
+			local = filepath.Dir(local)
+		}
+		roots = append(roots, local)
+	}
+	return roots
+}
+
+func addWatchDirs(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
+
+// watchLocalChanges runs the comparison once and then compares every
+// tracked file again as soon as it is written locally, debounced by
+// watchDebounce. The full comparison still repeats every interval so that
+// remote changes are picked up. It only returns when file notifications are
+// not available, in which case the caller falls back to polling.
+func watchLocalChanges(pkg *Config, opts *Options, interval, jitter time.Duration, rerun func() error) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	for _, root := range watchRoots(pkg, opts) {
+		if err := addWatchDirs(watcher, root); err != nil {
+			return err
+		}
+	}
+
+	if err := rerun(); err != nil {
+		slog.Error("watch run failed", "err", err)
+	}
+	remoteSHAs := trackedSHAs()
+	due := make(chan string)
+	timers := make(map[string]*time.Timer)
+	nextRun := func() <-chan time.Time {
+		delay := interval
+		if jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(jitter)))
+		}
+		return time.After(delay)
+	}
+	tick := nextRun()
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					addWatchDirs(watcher, event.Name)
+					continue
+				}
+			}
+			if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+				continue
+			}
+			path := filepath.ToSlash(filepath.Clean(event.Name))
+			if _, tracked := remoteSHAs[path]; !tracked {
+				continue
+			}
+			if timer, ok := timers[path]; ok {
+				timer.Reset(watchDebounce)
+				continue
+			}
+			timers[path] = time.AfterFunc(watchDebounce, func() { due <- path })
+		case path := <-due:
+			delete(timers, path)
+			if err := recheckFile(path, remoteSHAs[path], pkg, opts); err != nil {
+				slog.Error("compare failed", "path", path, "err", err)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Warn("file watcher error", "err", err)
+		case <-tick:
+			if err := rerun(); err != nil {
+				slog.Error("watch run failed", "err", err)
+			}
+			remoteSHAs = trackedSHAs()
+			tick = nextRun()
+		}
+	}
+}
+
+// trackedSHAs maps every file examined by the last run to its remote sha.
+func trackedSHAs() map[string]string {
+	shas := make(map[string]string)
+	for _, result := range compareResults.all() {
+		shas[result.Path] = result.RemoteSha
+	}
+	return shas
+}
+
+func recheckFile(path, remoteSha string, pkg *Config, opts *Options) error {
+	fmt.Fprintf(diffOut, "[changed] %s\n", path)
+	compareResults.remove(path)
+	if err := compareFile(context.Background(), filepath.FromSlash(path), remoteSha, opts, pkg); err != nil {
+		return err
+	}
+	runMetrics.record(compareResults.all())
+	return pendingDiffs.render(opts)
+}